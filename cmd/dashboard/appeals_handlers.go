@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/alghurabi0/rehla/internal/models"
+	"github.com/alghurabi0/rehla/internal/notifications"
+)
+
+// appealsPage lists the grade appeals, correctors only see the appeals of
+// answers they graded.
+func (app *application) appealsPage(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status != "" && !slices.Contains(models.AppealStatuses, status) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	user, err := app.getUser(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	corrector := ""
+	if !app.isAdminCheck(r) {
+		corrector = user.Username
+	}
+	appeals, err := app.appeal.GetAll(context.Background(), status, corrector)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Appeals = appeals
+	data.Status = status
+	app.render(w, http.StatusOK, "appeals.tmpl.html", data)
+}
+
+// decideAppeal upholds the grade or regrades the answer, the regraded grade
+// is before the late penalty like any correction.
+func (app *application) decideAppeal(w http.ResponseWriter, r *http.Request) {
+	appealId := r.PathValue("appealId")
+	if appealId == "" {
+		app.notFound(w)
+		return
+	}
+	user, err := app.getUser(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	ctx := context.Background()
+	appeal, err := app.appeal.Get(ctx, appealId)
+	if err != nil {
+		app.errorLog.Println(err)
+		app.notFound(w)
+		return
+	}
+	if !app.isAdminCheck(r) && !slices.Contains(appeal.Correctors, user.Username) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+	if !appeal.IsOpen() {
+		http.Error(w, models.ErrAppealDecided.Error(), http.StatusConflict)
+		return
+	}
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	status := r.PostForm.Get("status")
+	if status != models.AppealUpheld && status != models.AppealRegraded {
+		http.Error(w, "invalid outcome", http.StatusBadRequest)
+		return
+	}
+	response := r.PostForm.Get("response")
+
+	newGrade, maxGrade := appeal.OldGrade, appeal.MaxGrade
+	var rule string
+	var regrade func(*models.Answer)
+	if status == models.AppealRegraded {
+		exam, err := app.exam.Get(ctx, appeal.CourseId, appeal.ExamId)
+		if err != nil {
+			app.errorLog.Println(err)
+			app.notFound(w)
+			return
+		}
+		grade, err := strconv.Atoi(r.PostForm.Get("grade"))
+		if err != nil || grade < 0 {
+			http.Error(w, "invalid grade number format", http.StatusBadRequest)
+			return
+		}
+		if exam.CheckGrade(grade) != nil {
+			http.Error(w, fmt.Sprintf("grade can't be bigger than %d", exam.FullMarks()), http.StatusBadRequest)
+			return
+		}
+		rule = exam.CountingRule
+		regrade = func(a *models.Answer) {
+			a.Grade = models.ApplyPenalty(grade, a.Penalty)
+			// the breakdown no longer adds up to the new grade
+			a.Scores = nil
+			newGrade, maxGrade = a.Grade, a.MaxGrade
+		}
+	}
+	// the grade changes with the decision so two correctors can't both
+	// regrade the appeal
+	err = app.appeal.Decide(ctx, appealId, status, rule, regrade, response, user.Username, time.Now())
+	if errors.Is(err, models.ErrAppealDecided) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.notify(appeal.UserId, notifications.AppealDecided(appeal.CourseId, appeal.ExamId, appeal.ExamTitle, status, newGrade, maxGrade))
+
+	http.Redirect(w, r, "/appeals?status=open", http.StatusSeeOther)
+}
+
+// appealReport shows how often each corrector's grades are appealed.
+func (app *application) appealReport(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	appeals, err := app.appeal.GetAll(ctx, "", "")
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	corrected, err := app.answer.CorrectedCounts(ctx)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	rates := models.AppealRates(*appeals, corrected)
+
+	data := app.newTemplateData(r)
+	data.AppealRates = &rates
+	app.render(w, http.StatusOK, "appealReport.tmpl.html", data)
+}
@@ -176,3 +176,13 @@ func (app *application) updateMatsCache(w http.ResponseWriter, r *http.Request)
 		app.redis.Del(ctx, fmt.Sprintf("course:%s:mats", courseId))
 	}
 }
+
+func (app *application) updateSectionsCache(w http.ResponseWriter, r *http.Request) {
+	courseId := r.PathValue("courseId")
+	if courseId == "" {
+		app.notFound(w)
+		return
+	}
+	app.cacheSections(context.Background(), courseId)
+	http.Redirect(w, r, "/cache", http.StatusOK)
+}
@@ -2,11 +2,17 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"slices"
 	"strconv"
+	"time"
 
+	"cloud.google.com/go/storage"
 	"github.com/alghurabi0/rehla/internal/models"
+	"github.com/alghurabi0/rehla/internal/notifications"
 )
 
 func (app *application) correctExams(w http.ResponseWriter, r *http.Request) {
@@ -25,6 +31,9 @@ func (app *application) correctExams(w http.ResponseWriter, r *http.Request) {
 	app.render(w, http.StatusOK, "exams.tmpl.html", data)
 }
 
+// how many answers the answers page lists at a time
+const answersPageSize = 50
+
 func (app *application) correctAnswers(w http.ResponseWriter, r *http.Request) {
 	courseId := r.PathValue("courseId")
 	if courseId == "" {
@@ -37,29 +46,99 @@ func (app *application) correctAnswers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	ctx := context.Background()
-	userIds, err := app.storage.GetAnswers(ctx, courseId, examId)
+	exam, err := app.exam.Get(ctx, courseId, examId)
+	if err != nil {
+		app.errorLog.Println(err)
+		app.notFound(w)
+		return
+	}
+	query := r.URL.Query()
+	status := query.Get("status")
+	if status != "" && !slices.Contains(models.AnswerStatuses, status) {
+		http.Error(w, "invalid status", http.StatusBadRequest)
+		return
+	}
+	sort := query.Get("sort")
+	if sort != "" && sort != "newest" {
+		http.Error(w, "invalid sort", http.StatusBadRequest)
+		return
+	}
+	corrector, err := app.getUser(r)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
-	var correctedAnswers []models.Answer
-	var uncorrectedAnswers []models.Answer
-	for _, userId := range userIds {
-		answer, err := app.answer.Get(ctx, userId, courseId, examId)
-		if err != nil {
-			app.serverError(w, err)
-			return
-		}
-		if answer.Corrected {
-			correctedAnswers = append(correctedAnswers, *answer)
-		} else {
-			uncorrectedAnswers = append(uncorrectedAnswers, *answer)
+	queue, err := app.queue.GetExam(ctx, courseId, exam.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	queued := map[string]models.QueueItem{}
+	for _, item := range *queue {
+		queued[item.UserId] = item
+	}
+	// answers other correctors are working on aren't listed, and correctors
+	// only see what's assigned to them or to anyone
+	isAdmin := app.isAdminCheck(r)
+	now := time.Now()
+	// one answer per student, it's only corrected once all their attempts are
+	page, err := app.answer.ListExamAnswers(ctx, models.AnswerQuery{
+		CourseId:  courseId,
+		ExamId:    exam.ID,
+		Status:    status,
+		Corrector: query.Get("corrector"),
+		Newest:    sort == "newest",
+		After:     query.Get("after"),
+		Limit:     answersPageSize,
+		Skip: func(answer models.Answer) bool {
+			item, ok := queued[answer.UserId]
+			if !ok {
+				return false
+			}
+			if item.ClaimedByOther(corrector.ID, now) {
+				return true
+			}
+			return !isAdmin && item.AssignedTo != "" && item.AssignedTo != corrector.ID
+		},
+	})
+	if errors.Is(err, models.ErrInvalidCursor) {
+		http.Error(w, "invalid page", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	answers := page.Answers
+	staff, err := app.dashboardUser.GetAll(ctx)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	matches, err := app.similarity.GetExamMatches(ctx, courseId, exam.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	for i := range answers {
+		for _, match := range *matches {
+			if slices.Contains(match.UserIds, answers[i].UserId) {
+				answers[i].Similar = append(answers[i].Similar, match)
+			}
 		}
 	}
 
 	data := app.newTemplateData(r)
-	data.CorrectedAnswers = &correctedAnswers
-	data.UncorrectedAnswers = &uncorrectedAnswers
+	data.Exam = exam
+	data.Answers = &answers
+	data.Staff = staff
+	data.Status = status
+	data.Corrector = query.Get("corrector")
+	data.Sort = sort
+	if page.Next != "" {
+		query.Set("after", page.Next)
+		data.NextPage = fmt.Sprintf("/correct/%s/%s?%s", courseId, exam.ID, query.Encode())
+	}
 	app.render(w, http.StatusOK, "answers.tmpl.html", data)
 }
 
@@ -98,17 +177,42 @@ func (app *application) correctAnswer(w http.ResponseWriter, r *http.Request) {
 		app.notFound(w)
 		return
 	}
-	answer, err := app.answer.Get(ctx, user.ID, course.ID, exam.ID)
+	attempts, err := app.answer.GetAttempts(ctx, user.ID, course.ID, exam.ID)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("%v", err), http.StatusBadRequest)
 		return
 	}
+	answer, err := pickAttempt(r, *attempts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	claim, ok := app.claimAnswer(w, r, course.ID, exam.ID, user.ID)
+	if !ok {
+		return
+	}
+
+	corrector, err := app.getUser(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 
 	data := app.newTemplateData(r)
+	data.Claim = claim
+	// markers only see their own mark
+	data.Mark = answer.MarkBy(corrector.ID)
+	// correctors grading an anonymous exam only get the student's id
+	if exam.Anonymous && !app.isAdminCheck(r) {
+		user = &models.User{ID: user.ID}
+		data.Pseudonym = models.Pseudonym(exam.ID, user.ID)
+	}
+	data.Exam = exam
 	data.Answer = answer
+	data.Answers = attempts
 	data.User = user
 	data.HxMethod = "patch"
-	data.HxRoute = fmt.Sprintf("/correct/%s/%s/%s", courseId, examId, userId)
+	data.HxRoute = fmt.Sprintf("/correct/%s/%s/%s?attempt=%d", courseId, examId, userId, answer.Attempt)
 	app.render(w, http.StatusOK, "answer.tmpl.html", data)
 }
 
@@ -133,23 +237,42 @@ func (app *application) editAnswer(w http.ResponseWriter, r *http.Request) {
 		app.serverError(w, err)
 		return
 	}
-	fmt.Println(user)
 	ctx := context.Background()
-	_, err = app.answer.Get(ctx, userId, courseId, examId)
+	exam, err := app.exam.Get(ctx, courseId, examId)
+	if err != nil {
+		app.errorLog.Println(err)
+		app.notFound(w)
+		return
+	}
+	attempts, err := app.answer.GetAttempts(ctx, userId, courseId, examId)
 	if err != nil {
 		app.clientError(w, http.StatusBadRequest)
 		app.errorLog.Print(err)
 		return
 	}
-	err = r.ParseForm()
+	answer, err := pickAttempt(r, *attempts)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	_, ok := app.claimAnswer(w, r, courseId, examId, userId)
+	if !ok {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 2*maxFeedbackSize+(1<<20))
+	err = r.ParseMultipartForm(10 << 20)
+	if err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		http.Error(w, "Error parsing form data", http.StatusBadRequest)
 		return
 	}
-	ans := &models.Answer{}
+	grade := answer.Grade
+	maxGrade := answer.MaxGrade
+	scores := answer.Scores
+	// the grade the corrector gave before any penalty, -1 if they gave none
+	given := -1
 	gradeStr := r.FormValue("grade")
 	if gradeStr != "" {
-		grade, err := strconv.Atoi(gradeStr)
+		grade, err = strconv.Atoi(gradeStr)
 		if err != nil {
 			http.Error(w, "invalid grade number format", http.StatusBadRequest)
 			return
@@ -158,21 +281,335 @@ func (app *application) editAnswer(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "grade can't be smaller than 0", http.StatusBadRequest)
 			return
 		}
-		ans.Grade = grade
+		if exam.CheckGrade(grade) != nil {
+			http.Error(w, fmt.Sprintf("grade can't be bigger than %d", exam.FullMarks()), http.StatusBadRequest)
+			return
+		}
+		given = grade
+		grade = models.ApplyPenalty(grade, answer.Penalty)
+		maxGrade = exam.FullMarks()
 	}
 	notes := r.FormValue("notes")
-	if notes != "" {
-		ans.Notes = notes
+
+	// with a rubric the grade is the total of the criteria
+	if len(answer.Items) == 0 && len(exam.Rubric) > 0 {
+		awarded := make([]int, len(exam.Rubric))
+		for i, c := range exam.Rubric {
+			awarded[i], err = strconv.Atoi(r.FormValue(fmt.Sprintf("criterion_%d", i)))
+			if err != nil || awarded[i] < 0 || awarded[i] > c.Points {
+				http.Error(w, fmt.Sprintf("%s must be scored between 0 and %d", c.Title, c.Points), http.StatusBadRequest)
+				return
+			}
+		}
+		var total int
+		scores, total, err = exam.ScoreRubric(awarded)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("the total can't be bigger than %d", exam.FullMarks()), http.StatusBadRequest)
+			return
+		}
+		given = total
+		grade = models.ApplyPenalty(total, answer.Penalty)
+		maxGrade = exam.FullMarks()
 	}
-	ans.Corrected = true
-	ans.Corrector = user.Username
 
-	updates := app.createFirestoreUpdateArr(ans, true)
-	err = app.answer.Update(ctx, userId, courseId, examId, updates)
+	// double marked answers collect each corrector's mark, they're corrected
+	// once two marks agree or a moderator settles them
+	doubleMarked := exam.DoubleMarking && len(answer.Items) == 0
+	if doubleMarked {
+		if answer.Corrected {
+			http.Error(w, "the marks of this answer were already settled", http.StatusConflict)
+			return
+		}
+		if given < 0 {
+			http.Error(w, "grade is required", http.StatusBadRequest)
+			return
+		}
+		if answer.MarkBy(user.ID) == nil && len(answer.Marks) >= models.MarksNeeded {
+			http.Error(w, "this answer was already marked by two correctors", http.StatusConflict)
+			return
+		}
+	}
+
+	// auto graded answers are corrected per question, their grade is the
+	// sum of the items. Late penalties apply to the grade in both cases.
+	items := answer.Items
+	if len(items) > 0 {
+		for i, item := range items {
+			awardedStr := r.FormValue(fmt.Sprintf("awarded_%s", item.QuestionId))
+			if awardedStr == "" {
+				continue
+			}
+			awarded, err := strconv.Atoi(awardedStr)
+			if err != nil {
+				http.Error(w, "invalid points number format", http.StatusBadRequest)
+				return
+			}
+			if awarded < 0 || awarded > item.Points {
+				http.Error(w, fmt.Sprintf("points must be between 0 and %d", item.Points), http.StatusBadRequest)
+				return
+			}
+			if awarded != item.Awarded {
+				items[i].Awarded = awarded
+				items[i].Overridden = true
+			}
+		}
+		sum, _ := models.SumItems(items)
+		grade = models.ApplyPenalty(sum, answer.Penalty)
+	}
+
+	// feedback files sit next to the answer, a new upload replaces the old one
+	prefix := fmt.Sprintf("courses/%s/exams/%s/answers/%s/%d", courseId, examId, userId, answer.Attempt)
+	marked, err := app.uploadFeedback(ctx, r, "marked_file", markedTypes, prefix+"_marked")
 	if err != nil {
-		app.serverError(w, err)
+		app.feedbackError(w, err)
+		return
+	}
+	audio, err := app.uploadFeedback(ctx, r, "audio_file", audioTypes, prefix+"_audio")
+	if err != nil {
+		if marked != nil {
+			marked.object.Delete(ctx)
+		}
+		app.feedbackError(w, err)
 		return
 	}
+	var replaced []string
+	if (marked != nil || r.FormValue("remove_marked") != "") && answer.MarkedPath != "" {
+		replaced = append(replaced, answer.MarkedPath)
+	}
+	if (audio != nil || r.FormValue("remove_audio") != "") && answer.AudioPath != "" {
+		replaced = append(replaced, answer.AudioPath)
+	}
+
+	corrected := !doubleMarked
+	var markErr error
+	err = app.answer.UpdateAttempt(ctx, userId, courseId, examId, answer.Attempt, exam.CountingRule, func(a *models.Answer) {
+		if doubleMarked {
+			corrected, markErr = a.ApplyMark(models.Mark{
+				CorrectorId: user.ID,
+				Corrector:   user.Username,
+				Grade:       given,
+				Scores:      scores,
+				Notes:       notes,
+				MarkedAt:    time.Now(),
+			}, exam.MarkThreshold)
+			if markErr != nil {
+				return
+			}
+			grade, notes = a.Grade, a.Notes
+		} else {
+			a.Grade = grade
+			if notes != "" {
+				a.Notes = notes
+			}
+			a.Items = items
+			a.Scores = scores
+			a.Corrected = true
+			a.CorrectedAt = time.Now()
+			a.Corrector = user.Username
+		}
+		a.MaxGrade = maxGrade
+		a.Withheld = exam.WithholdsGrades()
+		if marked != nil {
+			a.MarkedURL, a.MarkedPath = marked.url, marked.path
+		} else if r.FormValue("remove_marked") != "" {
+			a.MarkedURL, a.MarkedPath = "", ""
+		}
+		if audio != nil {
+			a.AudioURL, a.AudioPath = audio.url, audio.path
+		} else if r.FormValue("remove_audio") != "" {
+			a.AudioURL, a.AudioPath = "", ""
+		}
+	})
+	if err != nil || markErr != nil {
+		for _, f := range []*feedbackFile{marked, audio} {
+			if f != nil {
+				f.object.Delete(ctx)
+			}
+		}
+		if errors.Is(markErr, models.ErrFullyMarked) {
+			http.Error(w, "this answer was already marked by two correctors", http.StatusConflict)
+			return
+		}
+		app.serverError(w, errors.Join(err, markErr))
+		return
+	}
+	if len(replaced) > 0 {
+		app.deleteFiles(replaced...)
+	}
+	if !corrected {
+		err = app.queue.AddMarker(ctx, courseId, examId, userId, user.ID)
+		if err != nil {
+			app.errorLog.Println(err)
+		}
+	}
+	// held grades are announced when they're released
+	if corrected && !exam.WithholdsGrades() {
+		app.notify(userId, notifications.ExamCorrected(courseId, examId, answer.ExamTitle, grade, maxGrade, notes))
+	}
 
 	http.Redirect(w, r, fmt.Sprintf("/correct/%s/%s", courseId, examId), http.StatusSeeOther)
 }
+
+// nextAnswer claims the next answer waiting in the exam's queue for the
+// corrector and opens it, ?skip= releases the student's answer first.
+func (app *application) nextAnswer(w http.ResponseWriter, r *http.Request) {
+	courseId := r.PathValue("courseId")
+	if courseId == "" {
+		app.notFound(w)
+		return
+	}
+	examId := r.PathValue("examId")
+	if examId == "" {
+		app.notFound(w)
+		return
+	}
+	corrector, err := app.getUser(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	ctx := context.Background()
+	// skipping an answer gives it back to the others
+	skip := r.URL.Query().Get("skip")
+	if skip != "" {
+		err = app.queue.Release(ctx, courseId, examId, skip, corrector.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+	item, err := app.queue.ClaimNext(ctx, courseId, examId, skip, corrector.ID, corrector.Username, time.Now())
+	if errors.Is(err, models.ErrQueueEmpty) {
+		http.Redirect(w, r, fmt.Sprintf("/correct/%s/%s", courseId, examId), http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/correct/%s/%s/%s", courseId, examId, item.UserId), http.StatusSeeOther)
+}
+
+// claimAnswer locks the student's answer for the corrector, answers that
+// aren't queued (corrected or submitted before the queue) need no lock. It
+// writes the error and returns false when the answer can't be claimed.
+func (app *application) claimAnswer(w http.ResponseWriter, r *http.Request, courseId, examId, userId string) (*models.QueueItem, bool) {
+	corrector, err := app.getUser(r)
+	if err != nil {
+		app.serverError(w, err)
+		return nil, false
+	}
+	claim, err := app.queue.Claim(context.Background(), courseId, examId, userId, corrector.ID, corrector.Username, time.Now())
+	if errors.Is(err, models.ErrClaimed) {
+		http.Error(w, "another corrector is working on this answer", http.StatusConflict)
+		return nil, false
+	}
+	if errors.Is(err, models.ErrNotQueued) {
+		return nil, true
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return nil, false
+	}
+	return claim, true
+}
+
+// pickAttempt returns the attempt asked for in the query string, otherwise
+// the oldest one waiting for correction or the latest.
+func pickAttempt(r *http.Request, attempts []models.Answer) (*models.Answer, error) {
+	if len(attempts) == 0 {
+		return nil, models.ErrNoAttempt
+	}
+	attemptStr := r.URL.Query().Get("attempt")
+	if attemptStr != "" {
+		n, err := strconv.Atoi(attemptStr)
+		if err != nil {
+			return nil, errors.New("invalid attempt number")
+		}
+		for i := range attempts {
+			if attempts[i].Attempt == n {
+				return &attempts[i], nil
+			}
+		}
+		return nil, models.ErrNoAttempt
+	}
+	for i := range attempts {
+		if !attempts[i].Corrected {
+			return &attempts[i], nil
+		}
+	}
+	return &attempts[len(attempts)-1], nil
+}
+
+// feedback uploads, the detected type picks the file extension.
+const maxFeedbackSize = 20 << 20
+
+var markedTypes = map[string]string{
+	"application/pdf": ".pdf",
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+}
+
+var audioTypes = map[string]string{
+	"audio/mpeg":      ".mp3",
+	"audio/wave":      ".wav",
+	"audio/mp4":       ".m4a",
+	"video/mp4":       ".m4a",
+	"application/ogg": ".ogg",
+	"video/webm":      ".webm",
+}
+
+var errInvalidFeedback = errors.New("invalid feedback file")
+
+type feedbackFile struct {
+	url    string
+	path   string
+	object *storage.ObjectHandle
+}
+
+// uploadFeedback stores the file sent in field at path with the extension
+// of its type, it returns nil when no file was sent.
+func (app *application) uploadFeedback(ctx context.Context, r *http.Request, field string, types map[string]string, path string) (*feedbackFile, error) {
+	if r.MultipartForm == nil {
+		return nil, nil
+	}
+	file, handler, err := r.FormFile(field)
+	if err != nil {
+		if errors.Is(err, http.ErrMissingFile) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+	if handler.Size > maxFeedbackSize {
+		return nil, fmt.Errorf("%w: %s must be %dMB or less", errInvalidFeedback, handler.Filename, maxFeedbackSize>>20)
+	}
+	buf := make([]byte, 512)
+	n, err := file.Read(buf)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	ext, ok := types[http.DetectContentType(buf[:n])]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s has a file type that is not allowed", errInvalidFeedback, handler.Filename)
+	}
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	path = fmt.Sprintf("%s_%d%s", path, time.Now().Unix(), ext)
+	url, object, err := app.storage.UploadFile(ctx, file, *handler, path)
+	if err != nil {
+		return nil, err
+	}
+	return &feedbackFile{url: url, path: path, object: object}, nil
+}
+
+func (app *application) feedbackError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errInvalidFeedback) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	app.serverError(w, err)
+}
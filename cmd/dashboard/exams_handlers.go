@@ -5,10 +5,17 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
+	"cloud.google.com/go/firestore"
 	"cloud.google.com/go/storage"
+	"github.com/alghurabi0/rehla/internal/jobs"
 	"github.com/alghurabi0/rehla/internal/models"
+	"github.com/alghurabi0/rehla/internal/notifications"
 )
 
 func (app *application) examsPage(w http.ResponseWriter, r *http.Request) {
@@ -23,7 +30,10 @@ func (app *application) examsPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	sort.SliceStable(*exams, func(i, j int) bool { return (*exams)[i].Order < (*exams)[j].Order })
+
 	data := app.newTemplateData(r)
+	data.Course = &models.Course{ID: courseId}
 	data.Exams = exams
 	data.HxRoute = fmt.Sprintf("/courses/%s/exam", courseId)
 	app.render(w, http.StatusOK, "exams.tmpl.html", data)
@@ -46,6 +56,29 @@ func (app *application) examPage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data := app.newTemplateData(r)
+	if exam.IsOnline() {
+		questions, err := app.question.GetAll(ctx, courseId)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		data.Questions = questions
+	}
+	data.Sections, err = app.section.GetAll(ctx, courseId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	data.Lecs, err = app.lec.GetAll(ctx, courseId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	data.Exams, err = app.exam.GetAll(ctx, courseId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 	data.Exam = exam
 	data.HxMethod = "patch"
 	data.HxRoute = fmt.Sprintf("/courses/%s/exams/%s", courseId, examId)
@@ -82,37 +115,67 @@ func (app *application) createExam(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "order can't be less than 1", http.StatusBadRequest)
 		return
 	}
-	file, handler, err := r.FormFile("exam_file")
-	if err != nil {
-		if err == http.ErrMissingFile {
-			http.Error(w, "must provide exam file", http.StatusBadRequest)
-			return
-		}
-		app.errorLog.Printf("%v\n", err)
-		http.Error(w, "error with getting file from form", http.StatusBadRequest)
+	examType := r.FormValue("type")
+	if examType == "" {
+		examType = models.ExamUpload
+	}
+	if examType != models.ExamUpload && examType != models.ExamOnline {
+		http.Error(w, "invalid exam type", http.StatusBadRequest)
 		return
 	}
-
-	defer file.Close()
-	examId := app.GenerateRandomID()
-	path := fmt.Sprintf("courses/%s/exams/%s/%s", courseId, examId, handler.Filename)
 	ctx := context.Background()
-	file_url, object, err := app.storage.UploadFile(ctx, file, *handler, path)
+	sectionId, err := app.sectionFromForm(ctx, r, courseId)
 	if err != nil {
-		app.serverError(w, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-
 	exam := &models.Exam{
-		Title:    title,
-		Order:    order,
-		URL:      file_url,
-		FilePath: path,
+		Title:     title,
+		Order:     order,
+		SectionId: sectionId,
+		Type:      examType,
+	}
+	err = examSettingsFromForm(r, exam)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	ctx = context.Background()
+	exam.Prerequisites, err = app.prerequisitesFromForm(ctx, r, courseId, models.PrerequisiteExam, "")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	examId := app.GenerateRandomID()
+	// online exams are answered from the question bank, they have no paper
+	var object *storage.ObjectHandle
+	if examType == models.ExamUpload {
+		file, handler, err := r.FormFile("exam_file")
+		if err != nil {
+			if err == http.ErrMissingFile {
+				http.Error(w, "must provide exam file", http.StatusBadRequest)
+				return
+			}
+			app.errorLog.Printf("%v\n", err)
+			http.Error(w, "error with getting file from form", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		path := fmt.Sprintf("courses/%s/exams/%s/%s", courseId, examId, handler.Filename)
+		file_url, obj, err := app.storage.UploadFile(ctx, file, *handler, path)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		object = obj
+		exam.URL = file_url
+		exam.FilePath = path
+	}
+
 	id, err := app.exam.Create(ctx, courseId, examId, exam)
 	if err != nil {
-		object.Delete(ctx)
+		if object != nil {
+			object.Delete(ctx)
+		}
 		app.serverError(w, err)
 		return
 	}
@@ -120,6 +183,9 @@ func (app *application) createExam(w http.ResponseWriter, r *http.Request) {
 		app.serverError(w, errors.New("got empty exam id from firestore"))
 		return
 	}
+	// the web app reads firestore for the exams until they're published
+	app.dropCached(ctx, fmt.Sprintf("course:%s:exams", courseId))
+	app.publish(courseId, jobs.ContentExam, id, exam.PublishAt)
 
 	http.Redirect(w, r, fmt.Sprintf("/courses/%s/exams/%s", courseId, id), http.StatusSeeOther)
 }
@@ -139,6 +205,16 @@ func (app *application) editExam(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Error parsing form data (max 10 mb)", http.StatusBadRequest)
 		return
 	}
+	old, err := app.exam.Get(context.Background(), courseId, examId)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+	prereqs, err := app.prerequisitesFromForm(context.Background(), r, courseId, models.PrerequisiteExam, examId)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	exam := &models.Exam{}
 	title := r.FormValue("title")
 	if title != "" {
@@ -157,6 +233,14 @@ func (app *application) editExam(w http.ResponseWriter, r *http.Request) {
 		}
 		exam.Order = order
 	}
+	examType := r.FormValue("type")
+	if examType != "" {
+		if examType != models.ExamUpload && examType != models.ExamOnline {
+			http.Error(w, "invalid exam type", http.StatusBadRequest)
+			return
+		}
+		exam.Type = examType
+	}
 	file, handler, err := r.FormFile("exam_file")
 	var object *storage.ObjectHandle
 	if err != nil {
@@ -183,14 +267,67 @@ func (app *application) editExam(w http.ResponseWriter, r *http.Request) {
 		exam.FilePath = path
 	}
 
+	// the form is sent with the current settings, empty fields clear them
+	settings := &models.Exam{}
+	err = examSettingsFromForm(r, settings)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sectionId, err := app.sectionFromForm(context.Background(), r, courseId)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	updates := app.createFirestoreUpdateArr(exam, true)
+	updates = append(updates,
+		firestore.Update{Path: "publish_at", Value: settings.PublishAt},
+		firestore.Update{Path: "opens_at", Value: settings.OpensAt},
+		firestore.Update{Path: "closes_at", Value: settings.ClosesAt},
+		firestore.Update{Path: "time_limit", Value: settings.TimeLimit},
+		firestore.Update{Path: "late_policy", Value: settings.LatePolicy},
+		firestore.Update{Path: "penalty_percent", Value: settings.PenaltyPercent},
+		firestore.Update{Path: "max_attempts", Value: settings.MaxAttempts},
+		firestore.Update{Path: "counting_rule", Value: settings.CountingRule},
+		firestore.Update{Path: "max_marks", Value: settings.MaxMarks},
+		firestore.Update{Path: "rubric", Value: settings.Rubric},
+		firestore.Update{Path: "anonymous", Value: settings.Anonymous},
+		firestore.Update{Path: "hold_grades", Value: settings.HoldGrades},
+		firestore.Update{Path: "double_marking", Value: settings.DoubleMarking},
+		firestore.Update{Path: "mark_threshold", Value: settings.MarkThreshold},
+		firestore.Update{Path: "appeal_days", Value: settings.AppealDays},
+		firestore.Update{Path: "weight", Value: settings.Weight},
+		firestore.Update{Path: "tags", Value: settings.Tags},
+	)
+	if r.Form.Has("section_id") {
+		updates = append(updates, firestore.Update{Path: "section_id", Value: sectionId})
+	}
+	if r.Form.Has("prerequisites") {
+		updates = append(updates, firestore.Update{Path: "prerequisites", Value: prereqs})
+	}
 	ctx := context.Background()
 	err = app.exam.Update(ctx, courseId, examId, updates)
 	if err != nil {
-		object.Delete(ctx)
+		if object != nil {
+			object.Delete(ctx)
+		}
 		app.serverError(w, err)
 		return
 	}
+	// online exams read the grade hold from the cached exam
+	err = app.redis.Del(ctx, fmt.Sprintf("course:%s:exam:%s", courseId, examId)).Err()
+	if err != nil {
+		app.errorLog.Println(err)
+	}
+	if republish(old.PublishAt, settings.PublishAt) {
+		app.dropCached(ctx, fmt.Sprintf("course:%s:exams", courseId))
+		app.publish(courseId, jobs.ContentExam, examId, settings.PublishAt)
+	}
+	// the course page reads the prerequisites from the cached exams
+	if r.Form.Has("prerequisites") {
+		app.dropCached(ctx, fmt.Sprintf("course:%s:exams", courseId))
+	}
 
 	http.Redirect(w, r, fmt.Sprintf("/courses/%s/exams/%s", courseId, examId), http.StatusSeeOther)
 }
@@ -210,13 +347,25 @@ func (app *application) deleteExam(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("exam with id %s doesn't exist", examId), http.StatusBadRequest)
 		return
 	}
-	err = app.storage.DeleteFile(ctx, exam.FilePath)
+	if exam.FilePath != "" {
+		err = app.storage.DeleteFile(ctx, exam.FilePath)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	err = app.exam.Delete(ctx, courseId, examId)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
-
-	err = app.exam.Delete(ctx, courseId, examId)
+	err = app.queue.DeleteExam(ctx, courseId, examId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	err = app.similarity.DeleteExam(ctx, courseId, examId)
 	if err != nil {
 		app.serverError(w, err)
 		return
@@ -230,15 +379,299 @@ func (app *application) deleteExam(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, fmt.Sprintf("/courses/%s/exams", courseId), http.StatusSeeOther)
 }
 
+// reorderExams takes the course's exams ids in their new order, sent by
+// dragging them around the exams page.
+func (app *application) reorderExams(w http.ResponseWriter, r *http.Request) {
+	courseId := r.PathValue("courseId")
+	if courseId == "" {
+		app.notFound(w)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+	ids := r.Form["ids"]
+	ctx := context.Background()
+	err = app.exam.Reorder(ctx, courseId, ids)
+	if err != nil {
+		if errors.Is(err, models.ErrBadOrder) {
+			http.Error(w, "the exams changed, reload the page and try again", http.StatusConflict)
+			return
+		}
+		app.serverError(w, err)
+		return
+	}
+	keys := []string{fmt.Sprintf("course:%s:exams", courseId)}
+	for _, id := range ids {
+		keys = append(keys, fmt.Sprintf("course:%s:exam:%s", courseId, id))
+	}
+	app.dropCached(ctx, keys...)
+
+	http.Redirect(w, r, fmt.Sprintf("/courses/%s/exams", courseId), http.StatusSeeOther)
+}
+
+// editExamQuestions sets the questions of an online exam, in the order of
+// the question bank.
+func (app *application) editExamQuestions(w http.ResponseWriter, r *http.Request) {
+	courseId := r.PathValue("courseId")
+	if courseId == "" {
+		app.notFound(w)
+		return
+	}
+	examId := r.PathValue("examId")
+	if examId == "" {
+		app.notFound(w)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	ctx := context.Background()
+	exam, err := app.exam.Get(ctx, courseId, examId)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("exam with id %s doesn't exist", examId), http.StatusBadRequest)
+		return
+	}
+	if !exam.IsOnline() {
+		http.Error(w, "only online exams have questions", http.StatusBadRequest)
+		return
+	}
+	questions, err := app.question.GetAll(ctx, courseId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	selected := make(map[string]bool)
+	for _, id := range r.PostForm["question_ids"] {
+		selected[id] = true
+	}
+	questionIds := []string{}
+	for _, q := range *questions {
+		if selected[q.ID] {
+			questionIds = append(questionIds, q.ID)
+		}
+	}
+
+	err = app.exam.Update(ctx, courseId, examId, []firestore.Update{{Path: "question_ids", Value: questionIds}})
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	err = app.redis.Del(ctx, fmt.Sprintf("course:%s:exam:%s", courseId, examId)).Err()
+	if err != nil {
+		app.errorLog.Println(err)
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/courses/%s/exams/%s", courseId, examId), http.StatusSeeOther)
+}
+
+// releaseGrades shows the exam's held grades to the students and notifies
+// them, grades given afterwards aren't held anymore.
+func (app *application) releaseGrades(w http.ResponseWriter, r *http.Request) {
+	courseId := r.PathValue("courseId")
+	if courseId == "" {
+		app.notFound(w)
+		return
+	}
+	examId := r.PathValue("examId")
+	if examId == "" {
+		app.notFound(w)
+		return
+	}
+	ctx := context.Background()
+	exam, err := app.exam.Get(ctx, courseId, examId)
+	if err != nil {
+		app.errorLog.Println(err)
+		app.notFound(w)
+		return
+	}
+	// stop holding first so grades given while releasing aren't left behind
+	err = app.exam.Update(ctx, courseId, examId, []firestore.Update{{Path: "grades_released_at", Value: time.Now()}})
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	err = app.redis.Del(ctx, fmt.Sprintf("course:%s:exam:%s", courseId, examId)).Err()
+	if err != nil {
+		app.errorLog.Println(err)
+	}
+	released, err := app.answer.ReleaseGrades(ctx, courseId, examId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	for _, a := range released {
+		app.notify(a.UserId, notifications.ExamCorrected(courseId, examId, exam.Title, a.Grade, a.MaxGrade, a.Notes))
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/courses/%s/exams/%s", courseId, examId), http.StatusSeeOther)
+}
+
 func (app *application) createExamPage(w http.ResponseWriter, r *http.Request) {
 	courseId := r.PathValue("courseId")
 	if courseId == "" {
 		app.notFound(w)
 	}
+	ctx := context.Background()
+	sections, err := app.section.GetAll(ctx, courseId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 	exam := &models.Exam{}
 	data := app.newTemplateData(r)
+	data.Lecs, err = app.lec.GetAll(ctx, courseId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	data.Exams, err = app.exam.GetAll(ctx, courseId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 	data.HxMethod = "post"
 	data.HxRoute = fmt.Sprintf("/courses/%s/exams", courseId)
 	data.Exam = exam
+	data.Sections = sections
 	app.render(w, http.StatusOK, "createExamPage.tmpl.html", data)
 }
+
+// datetimeLayout is the format of datetime-local inputs.
+const datetimeLayout = "2006-01-02T15:04"
+
+// examSettingsFromForm reads the publish time, window, time limit, late
+// policy and attempts of the exam form into exam.
+func examSettingsFromForm(r *http.Request, exam *models.Exam) error {
+	var err error
+	exam.PublishAt, err = publishAtFromForm(r)
+	if err != nil {
+		return err
+	}
+	opensAt := r.FormValue("opens_at")
+	if opensAt != "" {
+		exam.OpensAt, err = time.ParseInLocation(datetimeLayout, opensAt, models.ExamTimezone)
+		if err != nil {
+			return errors.New("invalid opening time")
+		}
+	}
+	closesAt := r.FormValue("closes_at")
+	if closesAt != "" {
+		exam.ClosesAt, err = time.ParseInLocation(datetimeLayout, closesAt, models.ExamTimezone)
+		if err != nil {
+			return errors.New("invalid closing time")
+		}
+	}
+	if !exam.OpensAt.IsZero() && !exam.ClosesAt.IsZero() && !exam.ClosesAt.After(exam.OpensAt) {
+		return errors.New("the exam must close after it opens")
+	}
+	timeLimit := r.FormValue("time_limit")
+	if timeLimit != "" {
+		exam.TimeLimit, err = strconv.Atoi(timeLimit)
+		if err != nil || exam.TimeLimit < 0 {
+			return errors.New("time limit must be a number of minutes")
+		}
+	}
+	exam.LatePolicy = r.FormValue("late_policy")
+	if exam.LatePolicy == "" {
+		exam.LatePolicy = models.LatePolicyReject
+	}
+	if !slices.Contains(models.LatePolicies, exam.LatePolicy) {
+		return errors.New("invalid late policy")
+	}
+	maxAttempts := r.FormValue("max_attempts")
+	if maxAttempts != "" {
+		exam.MaxAttempts, err = strconv.Atoi(maxAttempts)
+		if err != nil || exam.MaxAttempts < 0 {
+			return errors.New("max attempts must be a positive number, 0 for unlimited")
+		}
+	}
+	exam.CountingRule = r.FormValue("counting_rule")
+	if exam.CountingRule == "" {
+		exam.CountingRule = models.CountLatest
+	}
+	if !slices.Contains(models.CountingRules, exam.CountingRule) {
+		return errors.New("invalid counting rule")
+	}
+	if exam.LatePolicy == models.LatePolicyPenalty {
+		exam.PenaltyPercent, err = strconv.Atoi(r.FormValue("penalty_percent"))
+		if err != nil || exam.PenaltyPercent < 1 || exam.PenaltyPercent > 100 {
+			return errors.New("penalty must be a percentage between 1 and 100")
+		}
+	}
+	maxMarks := r.FormValue("max_marks")
+	if maxMarks != "" {
+		exam.MaxMarks, err = strconv.Atoi(maxMarks)
+		if err != nil || exam.MaxMarks < 0 {
+			return errors.New("max marks must be a positive number, 0 for the rubric total")
+		}
+	}
+	exam.Rubric, err = rubricFromForm(r.FormValue("rubric"))
+	if err != nil {
+		return err
+	}
+	exam.Anonymous = r.FormValue("anonymous") != ""
+	exam.HoldGrades = r.FormValue("hold_grades") != ""
+	exam.DoubleMarking = r.FormValue("double_marking") != ""
+	markThreshold := r.FormValue("mark_threshold")
+	if markThreshold != "" {
+		exam.MarkThreshold, err = strconv.Atoi(markThreshold)
+		if err != nil || exam.MarkThreshold < 0 {
+			return errors.New("the marking threshold must be a positive number of points")
+		}
+	}
+	appealDays := r.FormValue("appeal_days")
+	if appealDays != "" {
+		exam.AppealDays, err = strconv.Atoi(appealDays)
+		if err != nil || exam.AppealDays < 0 {
+			return errors.New("the appeal window must be a positive number of days, 0 for no appeals")
+		}
+	}
+	weight := r.FormValue("weight")
+	if weight != "" {
+		exam.Weight, err = strconv.Atoi(weight)
+		if err != nil || exam.Weight < 0 {
+			return errors.New("the weight must be a positive number")
+		}
+	}
+	exam.Tags = tagsFromForm(r.FormValue("tags"))
+	return nil
+}
+
+// tagsFromForm reads comma separated tags, repeated ones are dropped.
+func tagsFromForm(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// rubricFromForm reads one criterion per line written as "title | points".
+func rubricFromForm(value string) ([]models.Criterion, error) {
+	var rubric []models.Criterion
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		i := strings.LastIndex(line, "|")
+		if i < 0 {
+			return nil, fmt.Errorf("rubric line %q must be written as: title | points", line)
+		}
+		title := strings.TrimSpace(line[:i])
+		points, err := strconv.Atoi(strings.TrimSpace(line[i+1:]))
+		if title == "" || err != nil || points < 1 {
+			return nil, fmt.Errorf("rubric line %q must be written as: title | points", line)
+		}
+		rubric = append(rubric, models.Criterion{Title: title, Points: points})
+	}
+	return rubric, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+
+	"github.com/alghurabi0/rehla/internal/models"
+	"github.com/alghurabi0/rehla/internal/xlsx"
+)
+
+// loadGradebook builds the gradebook of the course out of its exams,
+// subscribers and their answers.
+func (app *application) loadGradebook(ctx context.Context, courseId string) (*models.Gradebook, error) {
+	exams, err := app.exam.GetAll(ctx, courseId)
+	if err != nil {
+		return nil, err
+	}
+	students, err := app.user.GetSubscribed(ctx, courseId)
+	if err != nil {
+		return nil, err
+	}
+	answers, err := app.answer.GetCourseAnswers(ctx, courseId)
+	if err != nil {
+		return nil, err
+	}
+	gradebook := models.BuildGradebook(*exams, *students, *answers)
+	return &gradebook, nil
+}
+
+func (app *application) gradebookPage(w http.ResponseWriter, r *http.Request) {
+	courseId := r.PathValue("courseId")
+	if courseId == "" {
+		app.notFound(w)
+		return
+	}
+	ctx := context.Background()
+	course, err := app.course.Get(ctx, courseId)
+	if err != nil {
+		app.errorLog.Println(err)
+		app.notFound(w)
+		return
+	}
+	gradebook, err := app.loadGradebook(ctx, courseId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Course = course
+	data.Gradebook = gradebook
+	app.render(w, http.StatusOK, "gradebook.tmpl.html", data)
+}
+
+// exportGradebook downloads the gradebook as ?format=csv or xlsx.
+func (app *application) exportGradebook(w http.ResponseWriter, r *http.Request) {
+	courseId := r.PathValue("courseId")
+	if courseId == "" {
+		app.notFound(w)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "csv" && format != "xlsx" {
+		http.Error(w, "format must be csv or xlsx", http.StatusBadRequest)
+		return
+	}
+	ctx := context.Background()
+	course, err := app.course.Get(ctx, courseId)
+	if err != nil {
+		app.errorLog.Println(err)
+		app.notFound(w)
+		return
+	}
+	gradebook, err := app.loadGradebook(ctx, courseId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	table := gradebook.Table()
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"gradebook-%s.%s\"", courseId, format))
+	if format == "xlsx" {
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		err = xlsx.Write(w, course.Title, table)
+		if err != nil {
+			app.errorLog.Println(err)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	// the byte order mark makes Excel read the arabic names as utf-8
+	w.Write([]byte("\ufeff"))
+	cw := csv.NewWriter(w)
+	for _, row := range table {
+		cw.Write(models.CSVRecord(row))
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		app.errorLog.Println(err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"cloud.google.com/go/firestore"
 	"github.com/alghurabi0/rehla/internal/models"
 )
 
@@ -264,7 +265,30 @@ func (app *application) editCourse(w http.ResponseWriter, r *http.Request) {
 		course.Price = price
 	}
 
+	// the form is sent with the current completion rules
+	var minWatch, minAverage int
+	if v := r.FormValue("min_watch"); v != "" {
+		minWatch, err = strconv.Atoi(v)
+		if err != nil || minWatch < 0 || minWatch > 100 {
+			http.Error(w, "lecture watch percent must be between 0 and 100", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := r.FormValue("min_average"); v != "" {
+		minAverage, err = strconv.Atoi(v)
+		if err != nil || minAverage < 0 || minAverage > 100 {
+			http.Error(w, "minimum average must be between 0 and 100", http.StatusBadRequest)
+			return
+		}
+	}
+
 	updates := app.createFirestoreUpdateArr(course, true)
+	updates = append(updates,
+		firestore.Update{Path: "drip", Value: r.FormValue("drip") == "1"},
+		firestore.Update{Path: "certificates", Value: r.FormValue("certificates") == "1"},
+		firestore.Update{Path: "min_watch", Value: minWatch},
+		firestore.Update{Path: "min_average", Value: minAverage},
+	)
 	err = app.course.Update(ctx, courseId, updates)
 	if err != nil {
 		app.serverError(w, err)
@@ -315,31 +339,50 @@ func (app *application) deleteCourse(w http.ResponseWriter, r *http.Request) {
 
 	}
 	// TODO - delete wistia folder
+	var paths []string
 	for _, exam := range *exams {
-		//err := app.storage.DeleteFile(ctx, exam.FilePath)
-		//if err != nil {
-		//app.serverError(w, fmt.Errorf("error while deleting storage file, exam: %v, error: %v", exam, err))
-		//return
-		//}
+		paths = append(paths, exam.FilePath)
 		err = app.exam.Delete(ctx, id, exam.ID)
 		if err != nil {
 			app.serverError(w, fmt.Errorf("error while deleting exam from firestore, exam: %v, error: %v", exam, err))
 			return
 		}
+		err = app.queue.DeleteExam(ctx, id, exam.ID)
+		if err != nil {
+			app.serverError(w, fmt.Errorf("error while emptying the correction queue, exam: %v, error: %v", exam, err))
+			return
+		}
+		err = app.similarity.DeleteExam(ctx, id, exam.ID)
+		if err != nil {
+			app.serverError(w, fmt.Errorf("error while deleting answer fingerprints, exam: %v, error: %v", exam, err))
+			return
+		}
 	}
 	for _, material := range *materials {
-		//err := app.storage.DeleteFile(ctx, material.FilePath)
-		//if err != nil {
-		//app.serverError(w, fmt.Errorf("error while deleting storage file, material: %v, error: %v", material, err))
-		//return
-		//}
+		paths = append(paths, material.FilePath)
 		err = app.material.Delete(ctx, id, material.ID)
 		if err != nil {
 			app.serverError(w, fmt.Errorf("error while deleting material from firestore, material: %v, error: %v", material, err))
 			return
 		}
 	}
+	questions, err := app.question.GetAll(ctx, id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	for _, question := range *questions {
+		err = app.question.Delete(ctx, id, question.ID)
+		if err != nil {
+			app.serverError(w, fmt.Errorf("error while deleting question from firestore, question: %v, error: %v", question, err))
+			return
+		}
+	}
 
+	// the exam and material files are removed by the worker
+	if len(paths) > 0 {
+		app.deleteFiles(paths...)
+	}
 	err = app.storage.DeleteFile(ctx, course.FilePath)
 	if err != nil {
 		app.serverError(w, fmt.Errorf("error while deleting storage file, course: %v, error: %v", course, err))
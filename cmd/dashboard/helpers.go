@@ -9,6 +9,8 @@ import (
 	"net/http"
 	"reflect"
 	"runtime/debug"
+	"strconv"
+	"time"
 
 	"cloud.google.com/go/firestore"
 	"github.com/alghurabi0/rehla/internal/dashboard_models"
@@ -153,3 +155,91 @@ func (app *application) removeString(slice []string, str string) []string {
 	// Return the original slice if the string is not found
 	return slice
 }
+
+// dropCached deletes cached keys after their documents changed, the web app
+// reads firestore for them until the cache page puts them back.
+func (app *application) dropCached(ctx context.Context, keys ...string) {
+	err := app.redis.Del(ctx, keys...).Err()
+	if err != nil {
+		app.errorLog.Printf("failed to delete %v -redis, err: %v\n", keys, err)
+	}
+}
+
+// publishAtFromForm reads when a lecture, exam or material goes out, zero
+// for right away.
+func publishAtFromForm(r *http.Request) (time.Time, error) {
+	publishAt := r.FormValue("publish_at")
+	if publishAt == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.ParseInLocation(datetimeLayout, publishAt, models.ExamTimezone)
+	if err != nil {
+		return time.Time{}, errors.New("invalid publish time")
+	}
+	return t, nil
+}
+
+// prerequisitesFromForm reads the lectures and exams the form requires
+// before a lecture or exam of kind opens, selfId being empty for new ones.
+// Prerequisites that would leave content requiring itself are refused.
+func (app *application) prerequisitesFromForm(ctx context.Context, r *http.Request, courseId, kind, selfId string) ([]models.Prerequisite, error) {
+	lecs, err := app.lec.GetAll(ctx, courseId)
+	if err != nil {
+		return nil, err
+	}
+	exams, err := app.exam.GetAll(ctx, courseId)
+	if err != nil {
+		return nil, err
+	}
+
+	var prereqs []models.Prerequisite
+	required := map[string]bool{}
+	for _, id := range r.Form["requires_lec"] {
+		required[id] = true
+	}
+	for _, lec := range *lecs {
+		if !required[lec.ID] {
+			continue
+		}
+		delete(required, lec.ID)
+		prereqs = append(prereqs, models.Prerequisite{Kind: models.PrerequisiteLec, ID: lec.ID})
+	}
+	if len(required) > 0 {
+		return nil, errors.New("a required lecture doesn't exist")
+	}
+	for _, exam := range *exams {
+		minPercent := r.FormValue("requires_exam_" + exam.ID)
+		if minPercent == "" {
+			continue
+		}
+		percent, err := strconv.Atoi(minPercent)
+		if err != nil || percent < 0 || percent > 100 {
+			return nil, errors.New("required grades must be between 0 and 100")
+		}
+		prereqs = append(prereqs, models.Prerequisite{Kind: models.PrerequisiteExam, ID: exam.ID, MinPercent: percent})
+	}
+
+	for _, p := range prereqs {
+		if p.Kind == kind && p.ID == selfId {
+			return nil, errors.New("content can't require itself")
+		}
+	}
+	// nothing requires new content yet so it can't close a loop
+	if selfId == "" {
+		return prereqs, nil
+	}
+	for i := range *lecs {
+		if kind == models.PrerequisiteLec && (*lecs)[i].ID == selfId {
+			(*lecs)[i].Prerequisites = prereqs
+		}
+	}
+	for i := range *exams {
+		if kind == models.PrerequisiteExam && (*exams)[i].ID == selfId {
+			(*exams)[i].Prerequisites = prereqs
+		}
+	}
+	if models.CheckPrerequisites(*lecs, *exams) != nil {
+		return nil, errors.New("the prerequisites would require each other")
+	}
+	return prereqs, nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+	"github.com/alghurabi0/rehla/internal/dashboard_models"
+	"github.com/alghurabi0/rehla/internal/models"
+	"github.com/alghurabi0/rehla/internal/notifications"
+)
+
+const inquiriesPageSize = 50
+
+func (app *application) inquiriesPage(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	status := query.Get("status")
+	if status != "" && !slices.Contains(models.InquiryStatuses, status) {
+		http.Error(w, "invalid status", http.StatusBadRequest)
+		return
+	}
+	search := query.Get("q")
+
+	ctx := context.Background()
+	page, err := app.contact.GetInquiries(ctx, models.InquiryQuery{
+		Status: status,
+		Search: search,
+		After:  query.Get("after"),
+		Limit:  inquiriesPageSize,
+	})
+	if errors.Is(err, models.ErrInvalidCursor) {
+		http.Error(w, "invalid page", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Inquiries = &page.Inquiries
+	data.Status = status
+	data.Search = search
+	if page.Next != "" {
+		query.Set("after", page.Next)
+		data.NextPage = fmt.Sprintf("/inquiries?%s", query.Encode())
+	}
+	app.render(w, http.StatusOK, "inquiries.tmpl.html", data)
+}
+
+// syncInquiries fills in the fields inquiries from before statuses existed
+// are missing, they aren't listed without them.
+func (app *application) syncInquiries(w http.ResponseWriter, r *http.Request) {
+	err := app.contact.SyncInquiries(context.Background())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/inquiries", http.StatusSeeOther)
+}
+
+func (app *application) inquiryPage(w http.ResponseWriter, r *http.Request) {
+	inquiryId := r.PathValue("inquiryId")
+	if inquiryId == "" {
+		app.notFound(w)
+		return
+	}
+	ctx := context.Background()
+	inquiry, err := app.contact.GetInquiry(ctx, inquiryId)
+	if err != nil {
+		app.notFound(w)
+		app.errorLog.Print(err)
+		return
+	}
+	staff, err := app.dashboardUser.GetAll(ctx)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Inquiry = inquiry
+	data.Staff = staff
+	app.render(w, http.StatusOK, "inquiry.tmpl.html", data)
+}
+
+// editInquiry sets the status and the staff member the inquiry is assigned
+// to.
+func (app *application) editInquiry(w http.ResponseWriter, r *http.Request) {
+	inquiryId := r.PathValue("inquiryId")
+	if inquiryId == "" {
+		app.notFound(w)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	status := r.FormValue("status")
+	if !slices.Contains(models.InquiryStatuses, status) {
+		http.Error(w, "invalid status", http.StatusBadRequest)
+		return
+	}
+	ctx := context.Background()
+	_, err = app.contact.GetInquiry(ctx, inquiryId)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+	assignedTo := r.FormValue("assigned_to")
+	if assignedTo != "" {
+		staff, err := app.dashboardUser.GetAll(ctx)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		if !slices.ContainsFunc(*staff, func(user dashboard_models.DashboardUser) bool {
+			return user.Username == assignedTo
+		}) {
+			http.Error(w, "invalid staff member", http.StatusBadRequest)
+			return
+		}
+	}
+	var updates []firestore.Update
+	updates = append(updates, firestore.Update{
+		Path:  "status",
+		Value: status,
+	})
+	updates = append(updates, firestore.Update{
+		Path:  "assigned_to",
+		Value: assignedTo,
+	})
+	err = app.contact.UpdateInquiry(ctx, inquiryId, updates)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/inquiries/%s", inquiryId), http.StatusSeeOther)
+}
+
+// replyToInquiry adds a staff reply and notifies the student when the
+// inquiry was sent while logged in.
+func (app *application) replyToInquiry(w http.ResponseWriter, r *http.Request) {
+	inquiryId := r.PathValue("inquiryId")
+	if inquiryId == "" {
+		app.notFound(w)
+		return
+	}
+	user, err := app.getUser(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	body := strings.TrimSpace(r.FormValue("body"))
+	if body == "" {
+		http.Error(w, "must provide reply", http.StatusBadRequest)
+		return
+	}
+	status := r.FormValue("status")
+	if status == "" {
+		status = models.InquiryInProgress
+	}
+	if !slices.Contains(models.InquiryStatuses, status) {
+		http.Error(w, "invalid status", http.StatusBadRequest)
+		return
+	}
+	ctx := context.Background()
+	inquiry, err := app.contact.GetInquiry(ctx, inquiryId)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+	reply := &models.Reply{
+		Author:    user.Username,
+		FromStaff: true,
+		Body:      body,
+	}
+	_, err = app.contact.AddReply(ctx, inquiryId, status, reply)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if inquiry.UserId != "" {
+		app.notify(inquiry.UserId, notifications.InquiryReply(inquiryId, body))
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/inquiries/%s", inquiryId), http.StatusSeeOther)
+}
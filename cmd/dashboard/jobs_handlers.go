@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/alghurabi0/rehla/internal/jobs"
+)
+
+// how many jobs the jobs page lists in each table
+const jobsPageSize = 100
+
+func (app *application) jobsPage(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	recent, err := app.jobs.Recent(ctx, jobsPageSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	failed, err := app.jobs.Failed(ctx, jobsPageSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Jobs = recent
+	data.FailedJobs = failed
+	app.render(w, http.StatusOK, "jobs.tmpl.html", data)
+}
+
+func (app *application) retryJob(w http.ResponseWriter, r *http.Request) {
+	jobId := r.PathValue("jobId")
+	if jobId == "" {
+		app.notFound(w)
+		return
+	}
+	err := app.jobs.Retry(context.Background(), jobId)
+	if err == jobs.ErrNoJob {
+		app.notFound(w)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(w, r, "/jobs", http.StatusSeeOther)
+}
@@ -6,8 +6,11 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"sort"
 	"strconv"
 
+	"cloud.google.com/go/firestore"
+	"github.com/alghurabi0/rehla/internal/jobs"
 	"github.com/alghurabi0/rehla/internal/models"
 )
 
@@ -23,7 +26,10 @@ func (app *application) lecsPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	sort.SliceStable(*lecs, func(i, j int) bool { return (*lecs)[i].Order < (*lecs)[j].Order })
+
 	data := app.newTemplateData(r)
+	data.Course = &models.Course{ID: courseId}
 	data.Lecs = lecs
 	data.HxRoute = fmt.Sprintf("/courses/%s/lec", courseId)
 	app.render(w, http.StatusOK, "lecs.tmpl.html", data)
@@ -45,8 +51,25 @@ func (app *application) lecPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	sections, err := app.section.GetAll(ctx, courseId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
 	data := app.newTemplateData(r)
+	data.Lecs, err = app.lec.GetAll(ctx, courseId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	data.Exams, err = app.exam.GetAll(ctx, courseId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 	data.Lec = lec
+	data.Sections = sections
 	data.HxMethod = "patch"
 	data.HxRoute = fmt.Sprintf("/courses/%s/lecs/%s", courseId, lecId)
 	app.render(w, http.StatusOK, "lec.tmpl.html", data)
@@ -99,14 +122,32 @@ func (app *application) createLec(w http.ResponseWriter, r *http.Request) {
 		app.serverError(w, err)
 		return
 	}
+	sectionId, err := app.sectionFromForm(ctx, r, courseId)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	publishAt, err := publishAtFromForm(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	prereqs, err := app.prerequisitesFromForm(ctx, r, courseId, models.PrerequisiteLec, "")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	lec := &models.Lec{
-		Title:       title,
-		Order:       order,
-		Description: description,
-		VideoUrl:    url,
-		FolderId:    course.FolderId,
-		Free:        course.Free,
+		Title:         title,
+		Order:         order,
+		SectionId:     sectionId,
+		Description:   description,
+		VideoUrl:      url,
+		FolderId:      course.FolderId,
+		Free:          course.Free,
+		PublishAt:     publishAt,
+		Prerequisites: prereqs,
 	}
 	id, err := app.lec.Create(ctx, courseId, lec)
 	if err != nil {
@@ -117,6 +158,9 @@ func (app *application) createLec(w http.ResponseWriter, r *http.Request) {
 		app.serverError(w, errors.New("got empty exam id from firestore"))
 		return
 	}
+	// the web app reads firestore for the lectures until they're published
+	app.dropCached(ctx, fmt.Sprintf("course:%s:lecs", courseId))
+	app.publish(courseId, jobs.ContentLec, id, publishAt)
 
 	http.Redirect(w, r, fmt.Sprintf("/courses/%s/lecs/%s", courseId, id), http.StatusSeeOther)
 }
@@ -162,14 +206,54 @@ func (app *application) editLec(w http.ResponseWriter, r *http.Request) {
 		}
 		lec.Order = order
 	}
+	ctx := context.Background()
+	sectionId, err := app.sectionFromForm(ctx, r, courseId)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	publishAt, err := publishAtFromForm(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	old, err := app.lec.Get(ctx, courseId, lecId)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+	prereqs, err := app.prerequisitesFromForm(ctx, r, courseId, models.PrerequisiteLec, lecId)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	updates := app.createFirestoreUpdateArr(lec, true)
-	ctx := context.Background()
+	// no section takes the lecture out of its section
+	if r.Form.Has("section_id") {
+		updates = append(updates, firestore.Update{Path: "section_id", Value: sectionId})
+	}
+	// the form is sent with the current publish time, empty publishes now
+	if r.Form.Has("publish_at") {
+		updates = append(updates, firestore.Update{Path: "publish_at", Value: publishAt})
+	}
+	if r.Form.Has("prerequisites") {
+		updates = append(updates, firestore.Update{Path: "prerequisites", Value: prereqs})
+	}
 	err = app.lec.Update(ctx, courseId, lecId, updates)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
+	if r.Form.Has("publish_at") && republish(old.PublishAt, publishAt) {
+		app.dropCached(ctx, fmt.Sprintf("course:%s:lecs", courseId), fmt.Sprintf("course:%s:lec:%s", courseId, lecId))
+		app.publish(courseId, jobs.ContentLec, lecId, publishAt)
+	}
+	// the web app checks prerequisites against the cached lectures
+	if r.Form.Has("prerequisites") {
+		app.dropCached(ctx, fmt.Sprintf("course:%s:lecs", courseId), fmt.Sprintf("course:%s:lec:%s", courseId, lecId))
+	}
 
 	http.Redirect(w, r, fmt.Sprintf("/courses/%s/lecs/%s", courseId, lecId), http.StatusSeeOther)
 }
@@ -208,6 +292,39 @@ func (app *application) deleteLec(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, fmt.Sprintf("/courses/%s/lecs", courseId), http.StatusSeeOther)
 }
 
+// reorderLecs takes the course's lectures ids in their new order, sent by
+// dragging them around the lectures page.
+func (app *application) reorderLecs(w http.ResponseWriter, r *http.Request) {
+	courseId := r.PathValue("courseId")
+	if courseId == "" {
+		app.notFound(w)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+	ids := r.Form["ids"]
+	ctx := context.Background()
+	err = app.lec.Reorder(ctx, courseId, ids)
+	if err != nil {
+		if errors.Is(err, models.ErrBadOrder) {
+			http.Error(w, "the lectures changed, reload the page and try again", http.StatusConflict)
+			return
+		}
+		app.serverError(w, err)
+		return
+	}
+	keys := []string{fmt.Sprintf("course:%s:lecs", courseId)}
+	for _, id := range ids {
+		keys = append(keys, fmt.Sprintf("course:%s:lec:%s", courseId, id))
+	}
+	app.dropCached(ctx, keys...)
+
+	http.Redirect(w, r, fmt.Sprintf("/courses/%s/lecs", courseId), http.StatusSeeOther)
+}
+
 func (app *application) createLecPage(w http.ResponseWriter, r *http.Request) {
 	courseId := r.PathValue("courseId")
 	if courseId == "" {
@@ -220,10 +337,27 @@ func (app *application) createLecPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	sections, err := app.section.GetAll(ctx, courseId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
 	lec := &models.Lec{}
 	data := app.newTemplateData(r)
+	data.Lecs, err = app.lec.GetAll(ctx, courseId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	data.Exams, err = app.exam.GetAll(ctx, courseId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 	data.Course = course
 	data.Lec = lec
+	data.Sections = sections
 	data.HxMethod = "post"
 	data.HxRoute = fmt.Sprintf("/courses/%s/lecs", courseId)
 	data.WistiaToken = os.Getenv("wistia_token")
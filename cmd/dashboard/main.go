@@ -18,6 +18,7 @@ import (
 	"github.com/alexedwards/scs/v2"
 	"github.com/alghurabi0/rehla/internal/dashboard_models"
 	"github.com/alghurabi0/rehla/internal/fileStorage"
+	"github.com/alghurabi0/rehla/internal/jobs"
 	"github.com/alghurabi0/rehla/internal/models"
 	"github.com/redis/go-redis/v9"
 	"google.golang.org/api/option"
@@ -30,17 +31,23 @@ type application struct {
 	session       *scs.SessionManager
 	course        *models.CourseModel
 	lec           *models.LecModel
+	section       *models.SectionModel
 	exam          *models.ExamModel
+	question      *models.QuestionModel
 	material      *models.MaterialModel
 	answer        *models.AnswerModel
+	appeal        *models.AppealModel
+	similarity    *models.SimilarityModel
 	user          *models.UserModel
 	dashboardUser *dashboard_models.DashboardUserModel
 	sub           *models.SubscriptionModel
 	payment       *models.PaymentModel
 	contact       *models.ContactModel
+	queue         *models.QueueModel
 	storage       *fileStorage.StorageModel
 	wistia        *fileStorage.WistiaModel
 	redis         *redis.Client
+	jobs          *jobs.Queue
 }
 
 var version string
@@ -103,18 +110,24 @@ func main() {
 		templateCache: templateCache,
 		course:        &models.CourseModel{DB: db, ST: strg},
 		lec:           &models.LecModel{DB: db},
+		section:       &models.SectionModel{DB: db},
 		exam:          &models.ExamModel{DB: db, ST: strg},
+		question:      &models.QuestionModel{DB: db},
 		material:      &models.MaterialModel{DB: db, ST: strg},
 		user:          &models.UserModel{DB: db},
 		dashboardUser: &dashboard_models.DashboardUserModel{DB: db},
 		answer:        &models.AnswerModel{DB: db, ST: strg},
+		appeal:        &models.AppealModel{DB: db},
+		similarity:    &models.SimilarityModel{DB: db},
 		sub:           &models.SubscriptionModel{DB: db},
 		payment:       &models.PaymentModel{DB: db},
 		contact:       &models.ContactModel{DB: db},
+		queue:         &models.QueueModel{DB: db},
 		session:       session,
 		storage:       &fileStorage.StorageModel{ST: strg},
 		wistia:        &fileStorage.WistiaModel{Token: wistiaToken},
 		redis:         rdb,
+		jobs:          &jobs.Queue{Redis: rdb},
 	}
 
 	srv := &http.Server{
@@ -5,9 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 
+	"cloud.google.com/go/firestore"
 	"cloud.google.com/go/storage"
+	"github.com/alghurabi0/rehla/internal/jobs"
 	"github.com/alghurabi0/rehla/internal/models"
 )
 
@@ -23,7 +26,10 @@ func (app *application) materialsPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	sort.SliceStable(*materials, func(i, j int) bool { return (*materials)[i].Order < (*materials)[j].Order })
+
 	data := app.newTemplateData(r)
+	data.Course = &models.Course{ID: courseId}
 	data.Materials = materials
 	data.HxRoute = fmt.Sprintf("/courses/%s/material", courseId)
 	app.render(w, http.StatusOK, "materials.tmpl.html", data)
@@ -59,8 +65,16 @@ func (app *application) materialPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	sections, err := app.section.GetAll(ctx, courseId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
 	data := app.newTemplateData(r)
+	data.Course = &models.Course{ID: courseId}
 	data.Material = material
+	data.Sections = sections
 	data.HxMethod = "patch"
 	data.HxRoute = fmt.Sprintf("/courses/%s/materials/%s", courseId, materialId)
 	app.render(w, http.StatusOK, "material.tmpl.html", data)
@@ -108,8 +122,18 @@ func (app *application) createMaterial(w http.ResponseWriter, r *http.Request) {
 	}
 
 	defer file.Close()
-	path := fmt.Sprintf("courses/%s/materials/%s", courseId, handler.Filename)
 	ctx := context.Background()
+	sectionId, err := app.sectionFromForm(ctx, r, courseId)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	publishAt, err := publishAtFromForm(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	path := fmt.Sprintf("courses/%s/materials/%s", courseId, handler.Filename)
 	file_url, object, err := app.storage.UploadFile(ctx, file, *handler, path)
 	if err != nil {
 		app.serverError(w, err)
@@ -117,10 +141,12 @@ func (app *application) createMaterial(w http.ResponseWriter, r *http.Request) {
 	}
 
 	material := &models.Material{
-		Title:    title,
-		Order:    order,
-		URL:      file_url,
-		FilePath: path,
+		Title:     title,
+		Order:     order,
+		SectionId: sectionId,
+		URL:       file_url,
+		FilePath:  path,
+		PublishAt: publishAt,
 	}
 	ctx = context.Background()
 	id, err := app.material.Create(ctx, courseId, material)
@@ -133,6 +159,9 @@ func (app *application) createMaterial(w http.ResponseWriter, r *http.Request) {
 		app.serverError(w, errors.New("got empty material id from firestore"))
 		return
 	}
+	// the web app reads firestore for the materials until they're published
+	app.dropCached(ctx, fmt.Sprintf("course:%s:mats", courseId))
+	app.publish(courseId, jobs.ContentMaterial, id, publishAt)
 
 	http.Redirect(w, r, fmt.Sprintf("/courses/%s/materials/%s", courseId, id), http.StatusSeeOther)
 }
@@ -221,6 +250,21 @@ func (app *application) editMaterial(w http.ResponseWriter, r *http.Request) {
 		}
 		material.Order = order
 	}
+	sectionId, err := app.sectionFromForm(context.Background(), r, courseId)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	publishAt, err := publishAtFromForm(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	old, err := app.material.Get(context.Background(), courseId, materialId)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
 	file, handler, err := r.FormFile("material_file")
 	var object *storage.ObjectHandle
 	if err != nil {
@@ -248,6 +292,13 @@ func (app *application) editMaterial(w http.ResponseWriter, r *http.Request) {
 	}
 
 	updates := app.createFirestoreUpdateArr(material, true)
+	if r.Form.Has("section_id") {
+		updates = append(updates, firestore.Update{Path: "section_id", Value: sectionId})
+	}
+	// the form is sent with the current publish time, empty publishes now
+	if r.Form.Has("publish_at") {
+		updates = append(updates, firestore.Update{Path: "publish_at", Value: publishAt})
+	}
 	ctx := context.Background()
 	err = app.material.Update(ctx, courseId, materialId, updates)
 	if err != nil {
@@ -255,6 +306,10 @@ func (app *application) editMaterial(w http.ResponseWriter, r *http.Request) {
 		app.serverError(w, err)
 		return
 	}
+	if r.Form.Has("publish_at") && republish(old.PublishAt, publishAt) {
+		app.dropCached(ctx, fmt.Sprintf("course:%s:mats", courseId))
+		app.publish(courseId, jobs.ContentMaterial, materialId, publishAt)
+	}
 
 	http.Redirect(w, r, fmt.Sprintf("/courses/%s/materials/%s", courseId, materialId), http.StatusSeeOther)
 }
@@ -289,6 +344,35 @@ func (app *application) deleteMaterial(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, fmt.Sprintf("/courses/%s/materials", courseId), http.StatusSeeOther)
 }
 
+// reorderMaterials takes the course's materials ids in their new order, sent by
+// dragging them around the materials page.
+func (app *application) reorderMaterials(w http.ResponseWriter, r *http.Request) {
+	courseId := r.PathValue("courseId")
+	if courseId == "" {
+		app.notFound(w)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+	ids := r.Form["ids"]
+	ctx := context.Background()
+	err = app.material.Reorder(ctx, courseId, ids)
+	if err != nil {
+		if errors.Is(err, models.ErrBadOrder) {
+			http.Error(w, "the materials changed, reload the page and try again", http.StatusConflict)
+			return
+		}
+		app.serverError(w, err)
+		return
+	}
+	app.dropCached(ctx, fmt.Sprintf("course:%s:mats", courseId))
+
+	http.Redirect(w, r, fmt.Sprintf("/courses/%s/materials", courseId), http.StatusSeeOther)
+}
+
 func (app *application) deleteFreeMaterial(w http.ResponseWriter, r *http.Request) {
 	materialId := r.PathValue("id")
 	if materialId == "" {
@@ -320,11 +404,18 @@ func (app *application) createMaterialPage(w http.ResponseWriter, r *http.Reques
 	if courseId == "" {
 		app.notFound(w)
 	}
+	sections, err := app.section.GetAll(context.Background(), courseId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 	material := &models.Material{}
 	data := app.newTemplateData(r)
+	data.Course = &models.Course{ID: courseId}
 	data.HxMethod = "post"
 	data.HxRoute = fmt.Sprintf("/courses/%s/materials", courseId)
 	data.Material = material
+	data.Sections = sections
 	app.render(w, http.StatusOK, "createMaterialPage.tmpl.html", data)
 }
 
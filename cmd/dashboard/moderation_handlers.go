@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alghurabi0/rehla/internal/models"
+	"github.com/alghurabi0/rehla/internal/notifications"
+)
+
+// moderationPage lists the double marked answers whose markers disagreed.
+func (app *application) moderationPage(w http.ResponseWriter, r *http.Request) {
+	answers, err := app.answer.GetNeedingModeration(context.Background())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Answers = answers
+	app.render(w, http.StatusOK, "moderation.tmpl.html", data)
+}
+
+// moderateAnswer settles the grade of an answer the markers disagreed on,
+// the moderator and their reasons are kept on the answer.
+func (app *application) moderateAnswer(w http.ResponseWriter, r *http.Request) {
+	courseId := r.PathValue("courseId")
+	if courseId == "" {
+		app.notFound(w)
+		return
+	}
+	examId := r.PathValue("examId")
+	if examId == "" {
+		app.notFound(w)
+		return
+	}
+	userId := r.PathValue("userId")
+	if userId == "" {
+		app.notFound(w)
+		return
+	}
+	moderator, err := app.getUser(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	ctx := context.Background()
+	exam, err := app.exam.Get(ctx, courseId, examId)
+	if err != nil {
+		app.errorLog.Println(err)
+		app.notFound(w)
+		return
+	}
+	attempts, err := app.answer.GetAttempts(ctx, userId, courseId, examId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	answer, err := pickAttempt(r, *attempts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(answer.Marks) < models.MarksNeeded {
+		http.Error(w, "this answer wasn't marked twice yet", http.StatusBadRequest)
+		return
+	}
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	grade, err := strconv.Atoi(r.PostForm.Get("grade"))
+	if err != nil || grade < 0 {
+		http.Error(w, "invalid grade number format", http.StatusBadRequest)
+		return
+	}
+	if exam.CheckGrade(grade) != nil {
+		http.Error(w, fmt.Sprintf("grade can't be bigger than %d", exam.FullMarks()), http.StatusBadRequest)
+		return
+	}
+	note := r.PostForm.Get("moderation_note")
+
+	var final *models.Answer
+	err = app.answer.UpdateAttempt(ctx, userId, courseId, examId, answer.Attempt, exam.CountingRule, func(a *models.Answer) {
+		a.Moderate(grade, moderator.Username, note, time.Now())
+		a.MaxGrade = exam.FullMarks()
+		a.Withheld = exam.WithholdsGrades()
+		final = a
+	})
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !exam.WithholdsGrades() {
+		app.notify(userId, notifications.ExamCorrected(courseId, examId, final.ExamTitle, final.Grade, final.MaxGrade, final.Notes))
+	}
+
+	http.Redirect(w, r, "/moderation", http.StatusSeeOther)
+}
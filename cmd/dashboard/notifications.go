@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/alghurabi0/rehla/internal/jobs"
+	"github.com/alghurabi0/rehla/internal/models"
+	"github.com/alghurabi0/rehla/internal/notifications"
+)
+
+// notify queues msg for the worker, which records it in the user's inbox
+// and pushes it to their devices.
+func (app *application) notify(userId string, msg notifications.Message) {
+	_, err := app.jobs.Enqueue(context.Background(), jobs.NotifyUser, jobs.NotifyUserPayload{
+		UserId:  userId,
+		Message: msg,
+	})
+	if err != nil {
+		app.errorLog.Printf("failed to queue notification for user %s: %v\n", userId, err)
+	}
+}
+
+// deleteFiles queues storage paths for removal by the worker.
+func (app *application) deleteFiles(paths ...string) {
+	_, err := app.jobs.Enqueue(context.Background(), jobs.DeleteFiles, jobs.DeleteFilesPayload{Paths: paths})
+	if err != nil {
+		app.errorLog.Printf("failed to queue deletion of %v: %v\n", paths, err)
+	}
+}
+
+// publish queues the announcement of a lecture, exam or material for when
+// it goes out, right away when publishAt passed.
+func (app *application) publish(courseId, kind, id string, publishAt time.Time) {
+	runAt := publishAt
+	if runAt.Before(time.Now()) {
+		runAt = time.Now()
+	}
+	_, err := app.jobs.EnqueueAt(context.Background(), jobs.PublishContent, jobs.PublishContentPayload{
+		CourseId:  courseId,
+		Kind:      kind,
+		ID:        id,
+		PublishAt: publishAt,
+	}, runAt)
+	if err != nil {
+		app.errorLog.Printf("failed to queue publishing of %s %s: %v\n", kind, id, err)
+	}
+}
+
+// republish reports whether moving the publish time of content from one
+// time to another has to announce it again, it does unless it was out and
+// stays out.
+func republish(from, to time.Time) bool {
+	if from.Equal(to) {
+		return false
+	}
+	now := time.Now()
+	return !models.Released(from, now) || !models.Released(to, now)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+	"github.com/alghurabi0/rehla/internal/models"
+)
+
+func (app *application) questionsPage(w http.ResponseWriter, r *http.Request) {
+	courseId := r.PathValue("courseId")
+	if courseId == "" {
+		app.notFound(w)
+		return
+	}
+	ctx := context.Background()
+	questions, err := app.question.GetAll(ctx, courseId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Questions = questions
+	data.HxRoute = fmt.Sprintf("/courses/%s/question", courseId)
+	app.render(w, http.StatusOK, "questions.tmpl.html", data)
+}
+
+func (app *application) questionPage(w http.ResponseWriter, r *http.Request) {
+	courseId := r.PathValue("courseId")
+	if courseId == "" {
+		app.notFound(w)
+		return
+	}
+	questionId := r.PathValue("questionId")
+	if questionId == "" {
+		app.notFound(w)
+		return
+	}
+	ctx := context.Background()
+	question, err := app.question.Get(ctx, courseId, questionId)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Question = question
+	data.HxMethod = "patch"
+	data.HxRoute = fmt.Sprintf("/courses/%s/questions/%s", courseId, questionId)
+	app.render(w, http.StatusOK, "question.tmpl.html", data)
+}
+
+func (app *application) createQuestionPage(w http.ResponseWriter, r *http.Request) {
+	courseId := r.PathValue("courseId")
+	if courseId == "" {
+		app.notFound(w)
+		return
+	}
+	data := app.newTemplateData(r)
+	data.Question = &models.Question{CourseId: courseId, Type: models.QuestionMCQ, Points: 1}
+	data.HxMethod = "post"
+	data.HxRoute = fmt.Sprintf("/courses/%s/questions", courseId)
+	app.render(w, http.StatusOK, "createQuestionPage.tmpl.html", data)
+}
+
+func (app *application) createQuestion(w http.ResponseWriter, r *http.Request) {
+	courseId := r.PathValue("courseId")
+	if courseId == "" {
+		app.notFound(w)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	question, err := questionFromForm(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := context.Background()
+	id, err := app.question.Create(ctx, courseId, question)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if id == "" {
+		app.serverError(w, errors.New("got empty question id from firestore"))
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/courses/%s/questions", courseId), http.StatusSeeOther)
+}
+
+// editQuestion replaces the whole question, the form is sent prefilled since
+// the answer only makes sense together with the type and choices.
+func (app *application) editQuestion(w http.ResponseWriter, r *http.Request) {
+	courseId := r.PathValue("courseId")
+	if courseId == "" {
+		app.notFound(w)
+		return
+	}
+	questionId := r.PathValue("questionId")
+	if questionId == "" {
+		app.notFound(w)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	question, err := questionFromForm(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	updates := []firestore.Update{
+		{Path: "type", Value: question.Type},
+		{Path: "text", Value: question.Text},
+		{Path: "choices", Value: question.Choices},
+		{Path: "answer", Value: question.Answer},
+		{Path: "tolerance", Value: question.Tolerance},
+		{Path: "points", Value: question.Points},
+		{Path: "order", Value: question.Order},
+	}
+	ctx := context.Background()
+	err = app.question.Update(ctx, courseId, questionId, updates)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/courses/%s/questions/%s", courseId, questionId), http.StatusSeeOther)
+}
+
+func (app *application) deleteQuestion(w http.ResponseWriter, r *http.Request) {
+	courseId := r.PathValue("courseId")
+	if courseId == "" {
+		app.notFound(w)
+		return
+	}
+	questionId := r.PathValue("questionId")
+	if questionId == "" {
+		app.notFound(w)
+		return
+	}
+	ctx := context.Background()
+	err := app.question.Delete(ctx, courseId, questionId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/courses/%s/questions", courseId), http.StatusSeeOther)
+}
+
+// questionFromForm reads and validates a question. MCQ choices are one per
+// line and the correct choice is picked by its number, starting from 1.
+func questionFromForm(r *http.Request) (*models.Question, error) {
+	question := &models.Question{
+		Type: r.PostForm.Get("type"),
+		Text: strings.TrimSpace(r.PostForm.Get("text")),
+	}
+	if !slices.Contains(models.QuestionTypes, question.Type) {
+		return nil, errors.New("invalid question type")
+	}
+	if question.Text == "" {
+		return nil, errors.New("must provide question text")
+	}
+	points, err := strconv.Atoi(r.PostForm.Get("points"))
+	if err != nil || points < 1 {
+		return nil, errors.New("points must be a number bigger than 0")
+	}
+	question.Points = points
+	orderStr := r.PostForm.Get("order")
+	if orderStr != "" {
+		order, err := strconv.Atoi(orderStr)
+		if err != nil || order < 1 {
+			return nil, errors.New("order must be a number bigger than 0")
+		}
+		question.Order = order
+	}
+
+	answer := strings.TrimSpace(r.PostForm.Get("answer"))
+	switch question.Type {
+	case models.QuestionMCQ:
+		for _, choice := range strings.Split(r.PostForm.Get("choices"), "\n") {
+			choice = strings.TrimSpace(choice)
+			if choice != "" {
+				question.Choices = append(question.Choices, choice)
+			}
+		}
+		if len(question.Choices) < 2 {
+			return nil, errors.New("multiple choice questions need at least 2 choices")
+		}
+		n, err := strconv.Atoi(answer)
+		if err != nil || n < 1 || n > len(question.Choices) {
+			return nil, fmt.Errorf("the correct choice must be a number between 1 and %d", len(question.Choices))
+		}
+		question.Answer = strconv.Itoa(n - 1)
+	case models.QuestionTrueFalse:
+		if answer != "true" && answer != "false" {
+			return nil, errors.New("the answer must be true or false")
+		}
+		question.Answer = answer
+	case models.QuestionNumeric:
+		_, err := strconv.ParseFloat(answer, 64)
+		if err != nil {
+			return nil, errors.New("the answer must be a number")
+		}
+		question.Answer = answer
+		toleranceStr := strings.TrimSpace(r.PostForm.Get("tolerance"))
+		if toleranceStr != "" {
+			tolerance, err := strconv.ParseFloat(toleranceStr, 64)
+			if err != nil || tolerance < 0 {
+				return nil, errors.New("tolerance must be a positive number")
+			}
+			question.Tolerance = tolerance
+		}
+	}
+	return question, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/alghurabi0/rehla/internal/models"
+)
+
+func (app *application) queuePage(w http.ResponseWriter, r *http.Request) {
+	items, err := app.queue.GetAll(context.Background())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	depths := models.Depths(*items, time.Now())
+
+	data := app.newTemplateData(r)
+	data.QueueDepths = &depths
+	app.render(w, http.StatusOK, "queue.tmpl.html", data)
+}
+
+// assignQueue hands the exam's unassigned answers to the course's correctors
+// in turn.
+func (app *application) assignQueue(w http.ResponseWriter, r *http.Request) {
+	courseId := r.PathValue("courseId")
+	if courseId == "" {
+		app.notFound(w)
+		return
+	}
+	examId := r.PathValue("examId")
+	if examId == "" {
+		app.notFound(w)
+		return
+	}
+	ctx := context.Background()
+	staff, err := app.dashboardUser.GetAll(ctx)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	var correctors []string
+	for _, user := range *staff {
+		if user.Role == "corrector" && slices.Contains(user.CorrectorCourses, courseId) {
+			correctors = append(correctors, user.ID)
+		}
+	}
+	if len(correctors) == 0 {
+		http.Error(w, "this course has no correctors", http.StatusBadRequest)
+		return
+	}
+	items, err := app.queue.GetExam(ctx, courseId, examId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	err = app.queue.Assign(ctx, models.AssignRoundRobin(*items, correctors))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/queue", http.StatusSeeOther)
+}
+
+// syncQueue adds the exam's answers from before the queue existed.
+func (app *application) syncQueue(w http.ResponseWriter, r *http.Request) {
+	courseId := r.PathValue("courseId")
+	if courseId == "" {
+		app.notFound(w)
+		return
+	}
+	examId := r.PathValue("examId")
+	if examId == "" {
+		app.notFound(w)
+		return
+	}
+	ctx := context.Background()
+	answers, err := app.answer.GetExamAnswers(ctx, courseId, examId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	err = app.queue.Sync(ctx, courseId, examId, *answers)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/correct/%s/%s", courseId, examId), http.StatusSeeOther)
+}
@@ -26,8 +26,16 @@ func (app *application) routes() http.Handler {
 	mux.Handle("PATCH /courses/{id}", isAdmin.ThenFunc(app.editCourse))
 	mux.Handle("DELETE /courses/{id}", isAdmin.ThenFunc(app.deleteCourse))
 
+	mux.Handle("GET /courses/{courseId}/sections", isAdmin.ThenFunc(app.sectionsPage))
+	mux.Handle("POST /courses/{courseId}/sections", isAdmin.ThenFunc(app.createSection))
+	mux.Handle("PUT /courses/{courseId}/sections/order", isAdmin.ThenFunc(app.reorderSections))
+	mux.Handle("GET /courses/{courseId}/sections/{sectionId}", isAdmin.ThenFunc(app.sectionPage))
+	mux.Handle("PATCH /courses/{courseId}/sections/{sectionId}", isAdmin.ThenFunc(app.editSection))
+	mux.Handle("DELETE /courses/{courseId}/sections/{sectionId}", isAdmin.ThenFunc(app.deleteSection))
+
 	mux.Handle("GET /courses/{courseId}/lecs", isAdmin.ThenFunc(app.lecsPage))
 	mux.Handle("POST /courses/{courseId}/lecs", isAdmin.ThenFunc(app.createLec))
+	mux.Handle("PUT /courses/{courseId}/lecs/order", isAdmin.ThenFunc(app.reorderLecs))
 	mux.Handle("GET /courses/{courseId}/lecs/{lecId}", isAdmin.ThenFunc(app.lecPage))
 	mux.Handle("PATCH /courses/{courseId}/lecs/{lecId}", isAdmin.ThenFunc(app.editLec))
 	mux.Handle("DELETE /courses/{courseId}/lecs/{lecId}", isAdmin.ThenFunc(app.deleteLec))
@@ -35,13 +43,27 @@ func (app *application) routes() http.Handler {
 
 	mux.Handle("GET /courses/{courseId}/exams", isAdmin.ThenFunc(app.examsPage))
 	mux.Handle("POST /courses/{courseId}/exams", isAdmin.ThenFunc(app.createExam))
+	mux.Handle("PUT /courses/{courseId}/exams/order", isAdmin.ThenFunc(app.reorderExams))
 	mux.Handle("GET /courses/{courseId}/exams/{examId}", isAdmin.ThenFunc(app.examPage))
 	mux.Handle("PATCH /courses/{courseId}/exams/{examId}", isAdmin.ThenFunc(app.editExam))
 	mux.Handle("DELETE /courses/{courseId}/exams/{examId}", isAdmin.ThenFunc(app.deleteExam))
+	mux.Handle("PUT /courses/{courseId}/exams/{examId}/questions", isAdmin.ThenFunc(app.editExamQuestions))
+	mux.Handle("POST /courses/{courseId}/exams/{examId}/release", isAdmin.ThenFunc(app.releaseGrades))
 	mux.Handle("GET /courses/{courseId}/exam", isAdmin.ThenFunc(app.createExamPage))
 
+	mux.Handle("GET /courses/{courseId}/gradebook", isAdmin.ThenFunc(app.gradebookPage))
+	mux.Handle("GET /courses/{courseId}/gradebook/export", isAdmin.ThenFunc(app.exportGradebook))
+
+	mux.Handle("GET /courses/{courseId}/questions", isAdmin.ThenFunc(app.questionsPage))
+	mux.Handle("POST /courses/{courseId}/questions", isAdmin.ThenFunc(app.createQuestion))
+	mux.Handle("GET /courses/{courseId}/questions/{questionId}", isAdmin.ThenFunc(app.questionPage))
+	mux.Handle("PATCH /courses/{courseId}/questions/{questionId}", isAdmin.ThenFunc(app.editQuestion))
+	mux.Handle("DELETE /courses/{courseId}/questions/{questionId}", isAdmin.ThenFunc(app.deleteQuestion))
+	mux.Handle("GET /courses/{courseId}/question", isAdmin.ThenFunc(app.createQuestionPage))
+
 	mux.Handle("GET /courses/{courseId}/materials", isAdmin.ThenFunc(app.materialsPage))
 	mux.Handle("POST /courses/{courseId}/materials", isAdmin.ThenFunc(app.createMaterial))
+	mux.Handle("PUT /courses/{courseId}/materials/order", isAdmin.ThenFunc(app.reorderMaterials))
 	mux.Handle("GET /courses/{courseId}/materials/{materialId}", isAdmin.ThenFunc(app.materialPage))
 	mux.Handle("PATCH /courses/{courseId}/materials/{materialId}", isAdmin.ThenFunc(app.editMaterial))
 	mux.Handle("DELETE /courses/{courseId}/materials/{materialId}", isAdmin.ThenFunc(app.deleteMaterial))
@@ -71,11 +93,34 @@ func (app *application) routes() http.Handler {
 	mux.Handle("POST /cache/{courseId}/lecs", isAdmin.ThenFunc(app.updateLecsCache))
 	mux.Handle("POST /cache/{courseId}/exams", isAdmin.ThenFunc(app.updateExamsCache))
 	mux.Handle("POST /cache/{courseId}/mats", isAdmin.ThenFunc(app.updateMatsCache))
+	mux.Handle("POST /cache/{courseId}/sections", isAdmin.ThenFunc(app.updateSectionsCache))
+
+	mux.Handle("GET /inquiries", isAdmin.ThenFunc(app.inquiriesPage))
+	mux.Handle("POST /inquiries/sync", isAdmin.ThenFunc(app.syncInquiries))
+	mux.Handle("GET /inquiries/{inquiryId}", isAdmin.ThenFunc(app.inquiryPage))
+	mux.Handle("PATCH /inquiries/{inquiryId}", isAdmin.ThenFunc(app.editInquiry))
+	mux.Handle("POST /inquiries/{inquiryId}/replies", isAdmin.ThenFunc(app.replyToInquiry))
+
+	mux.Handle("GET /jobs", isAdmin.ThenFunc(app.jobsPage))
+	mux.Handle("POST /jobs/{jobId}/retry", isAdmin.ThenFunc(app.retryJob))
+
+	mux.Handle("GET /queue", isAdmin.ThenFunc(app.queuePage))
+	mux.Handle("GET /moderation", isAdmin.ThenFunc(app.moderationPage))
+	mux.Handle("POST /queue/{courseId}/{examId}/assign", isAdmin.ThenFunc(app.assignQueue))
+	mux.Handle("POST /queue/{courseId}/{examId}/sync", isAdmin.ThenFunc(app.syncQueue))
+
+	mux.Handle("GET /appeals", isCorrector.ThenFunc(app.appealsPage))
+	mux.Handle("GET /appeals/report", isAdmin.ThenFunc(app.appealReport))
+	mux.Handle("GET /similarity", isAdmin.ThenFunc(app.similarityReport))
+	mux.Handle("PATCH /appeals/{appealId}", isCorrector.ThenFunc(app.decideAppeal))
 
 	mux.Handle("GET /correct/{courseId}", isCorrector.ThenFunc(app.correctExams))
 	mux.Handle("GET /correct/{courseId}/{examId}", isCorrector.ThenFunc(app.correctAnswers))
+	mux.Handle("GET /correct/{courseId}/{examId}/next", isCorrector.ThenFunc(app.nextAnswer))
 	mux.Handle("GET /correct/{courseId}/{examId}/{userId}", isCorrector.ThenFunc(app.correctAnswer))
 	mux.Handle("PATCH /correct/{courseId}/{examId}/{userId}", isCorrector.ThenFunc(app.editAnswer))
+	mux.Handle("POST /correct/{courseId}/{examId}/{userId}/moderate", isAdmin.ThenFunc(app.moderateAnswer))
+	mux.Handle("GET /correct/{courseId}/{examId}/compare/{matchId}", isCorrector.ThenFunc(app.compareAnswers))
 
 	mux.HandleFunc("GET /login", app.loginPage)
 	mux.HandleFunc("POST /login", app.login)
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+	"github.com/alghurabi0/rehla/internal/models"
+)
+
+func (app *application) sectionsPage(w http.ResponseWriter, r *http.Request) {
+	courseId := r.PathValue("courseId")
+	if courseId == "" {
+		app.notFound(w)
+		return
+	}
+	ctx := context.Background()
+	sections, err := app.section.GetAll(ctx, courseId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Course = &models.Course{ID: courseId}
+	data.Sections = sections
+	data.Section = &models.Section{}
+	data.HxMethod = "post"
+	data.HxRoute = fmt.Sprintf("/courses/%s/sections", courseId)
+	app.render(w, http.StatusOK, "sections.tmpl.html", data)
+}
+
+func (app *application) sectionPage(w http.ResponseWriter, r *http.Request) {
+	courseId := r.PathValue("courseId")
+	if courseId == "" {
+		app.notFound(w)
+		return
+	}
+	sectionId := r.PathValue("sectionId")
+	if sectionId == "" {
+		app.notFound(w)
+		return
+	}
+	ctx := context.Background()
+	section, err := app.section.Get(ctx, courseId, sectionId)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Section = section
+	data.HxMethod = "patch"
+	data.HxRoute = fmt.Sprintf("/courses/%s/sections/%s", courseId, sectionId)
+	app.render(w, http.StatusOK, "section.tmpl.html", data)
+}
+
+func (app *application) createSection(w http.ResponseWriter, r *http.Request) {
+	courseId := r.PathValue("courseId")
+	if courseId == "" {
+		app.notFound(w)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+	title := strings.TrimSpace(r.FormValue("title"))
+	if title == "" {
+		http.Error(w, "must provide title", http.StatusBadRequest)
+		return
+	}
+	ctx := context.Background()
+	sections, err := app.section.GetAll(ctx, courseId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	// new sections go last, they're moved from the sections page
+	section := &models.Section{
+		Title:       title,
+		Description: r.FormValue("description"),
+		Order:       len(*sections) + 1,
+		Preview:     r.FormValue("preview") == "1",
+	}
+	_, err = app.section.Create(ctx, courseId, section)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.cacheSections(ctx, courseId)
+
+	http.Redirect(w, r, fmt.Sprintf("/courses/%s/sections", courseId), http.StatusSeeOther)
+}
+
+func (app *application) editSection(w http.ResponseWriter, r *http.Request) {
+	courseId := r.PathValue("courseId")
+	if courseId == "" {
+		app.notFound(w)
+		return
+	}
+	sectionId := r.PathValue("sectionId")
+	if sectionId == "" {
+		app.notFound(w)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+	// the form is sent with the current values
+	title := strings.TrimSpace(r.FormValue("title"))
+	if title == "" {
+		http.Error(w, "must provide title", http.StatusBadRequest)
+		return
+	}
+	updates := []firestore.Update{
+		{Path: "title", Value: title},
+		{Path: "description", Value: r.FormValue("description")},
+		{Path: "preview", Value: r.FormValue("preview") == "1"},
+	}
+	ctx := context.Background()
+	err = app.section.Update(ctx, courseId, sectionId, updates)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.cacheSections(ctx, courseId)
+
+	http.Redirect(w, r, fmt.Sprintf("/courses/%s/sections/%s", courseId, sectionId), http.StatusSeeOther)
+}
+
+func (app *application) deleteSection(w http.ResponseWriter, r *http.Request) {
+	courseId := r.PathValue("courseId")
+	if courseId == "" {
+		app.notFound(w)
+		return
+	}
+	sectionId := r.PathValue("sectionId")
+	if sectionId == "" {
+		app.notFound(w)
+		return
+	}
+	ctx := context.Background()
+	err := app.section.Delete(ctx, courseId, sectionId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.cacheSections(ctx, courseId)
+
+	http.Redirect(w, r, fmt.Sprintf("/courses/%s/sections", courseId), http.StatusSeeOther)
+}
+
+// reorderSections takes the course's section ids in their new order, sent
+// by dragging them around the sections page.
+func (app *application) reorderSections(w http.ResponseWriter, r *http.Request) {
+	courseId := r.PathValue("courseId")
+	if courseId == "" {
+		app.notFound(w)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+	ctx := context.Background()
+	err = app.section.Reorder(ctx, courseId, r.Form["ids"])
+	if err != nil {
+		if errors.Is(err, models.ErrBadOrder) {
+			http.Error(w, "the sections changed, reload the page and try again", http.StatusConflict)
+			return
+		}
+		app.serverError(w, err)
+		return
+	}
+	app.cacheSections(ctx, courseId)
+
+	http.Redirect(w, r, fmt.Sprintf("/courses/%s/sections", courseId), http.StatusSeeOther)
+}
+
+// cacheSections puts the course's sections in the cache students read them
+// from. When that fails the cached ones are dropped so the web app reads
+// firestore instead of stale sections.
+func (app *application) cacheSections(ctx context.Context, courseId string) {
+	key := fmt.Sprintf("course:%s:sections", courseId)
+	sections, err := app.section.GetAll(ctx, courseId)
+	if err != nil {
+		app.errorLog.Printf("failed to get sections of course %s: %v\n", courseId, err)
+		app.redis.Del(ctx, key)
+		return
+	}
+	jsonSections, err := json.Marshal(sections)
+	if err != nil {
+		app.errorLog.Printf("failed to marshal sections of course %s: %v\n", courseId, err)
+		app.redis.Del(ctx, key)
+		return
+	}
+	err = app.redis.Set(ctx, key, jsonSections, 0).Err()
+	if err != nil {
+		app.errorLog.Printf("failed to cache sections of course %s: %v\n", courseId, err)
+		app.redis.Del(ctx, key)
+	}
+}
+
+// sectionFromForm returns the section picked in a lecture, exam or material
+// form, empty for none. It has to be one of the course's.
+func (app *application) sectionFromForm(ctx context.Context, r *http.Request, courseId string) (string, error) {
+	sectionId := r.FormValue("section_id")
+	if sectionId == "" {
+		return "", nil
+	}
+	_, err := app.section.Get(ctx, courseId, sectionId)
+	if err != nil {
+		return "", errors.New("the section doesn't exist")
+	}
+	return sectionId, nil
+}
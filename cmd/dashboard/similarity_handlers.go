@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/alghurabi0/rehla/internal/models"
+)
+
+// compareAnswers shows two answers that share pages side by side.
+func (app *application) compareAnswers(w http.ResponseWriter, r *http.Request) {
+	courseId := r.PathValue("courseId")
+	if courseId == "" {
+		app.notFound(w)
+		return
+	}
+	examId := r.PathValue("examId")
+	if examId == "" {
+		app.notFound(w)
+		return
+	}
+	ctx := context.Background()
+	exam, err := app.exam.Get(ctx, courseId, examId)
+	if err != nil {
+		app.errorLog.Println(err)
+		app.notFound(w)
+		return
+	}
+	match, err := app.similarity.GetMatch(ctx, courseId, r.PathValue("matchId"))
+	if err != nil || match.ExamId != exam.ID {
+		app.errorLog.Println(err)
+		app.notFound(w)
+		return
+	}
+	// correctors grading an anonymous exam only get pseudonyms
+	anonymous := exam.Anonymous && !app.isAdminCheck(r)
+	for _, userId := range match.UserIds {
+		if anonymous {
+			match.Names = append(match.Names, models.Pseudonym(exam.ID, userId))
+			continue
+		}
+		name := userId
+		user, err := app.user.Get(ctx, userId)
+		if err != nil {
+			app.errorLog.Println(err)
+		} else if full := strings.TrimSpace(user.Firstname + " " + user.Lastname); full != "" {
+			name = full
+		}
+		match.Names = append(match.Names, name)
+	}
+
+	data := app.newTemplateData(r)
+	data.Exam = exam
+	data.Match = match
+	app.render(w, http.StatusOK, "compare.tmpl.html", data)
+}
+
+// similarityReport groups the answers that share pages into clusters of
+// students, for admins to follow up.
+func (app *application) similarityReport(w http.ResponseWriter, r *http.Request) {
+	matches, err := app.similarity.GetAllMatches(context.Background())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	clusters := models.Clusters(*matches)
+
+	data := app.newTemplateData(r)
+	data.Clusters = &clusters
+	app.render(w, http.StatusOK, "similarity.tmpl.html", data)
+}
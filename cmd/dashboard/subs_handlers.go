@@ -11,6 +11,7 @@ import (
 
 	"cloud.google.com/go/firestore"
 	"github.com/alghurabi0/rehla/internal/models"
+	"github.com/alghurabi0/rehla/internal/notifications"
 )
 
 func (app *application) subPage(w http.ResponseWriter, r *http.Request) {
@@ -95,6 +96,7 @@ func (app *application) createSub(w http.ResponseWriter, r *http.Request) {
 	}
 
 	sub.CourseTitle = course.Title
+	sub.StartedAt = time.Now()
 	status := r.FormValue("status")
 	if status == "active" {
 		sub.Active = true
@@ -133,6 +135,9 @@ func (app *application) createSub(w http.ResponseWriter, r *http.Request) {
 		app.serverError(w, err)
 		return
 	}
+	if sub.Active {
+		app.notify(user.ID, notifications.SubscriptionActive(courseId, course.Title))
+	}
 
 	http.Redirect(w, r, fmt.Sprintf("/users/%s", user.ID), http.StatusSeeOther)
 }
@@ -323,6 +328,7 @@ func (app *application) createPayment(w http.ResponseWriter, r *http.Request) {
 		app.serverError(w, errors.New("empty id"))
 		return
 	}
+	app.notify(user.ID, notifications.PaymentRecorded(sub.ID, sub.CourseTitle, amount, validUntil))
 	http.Redirect(w, r, fmt.Sprintf("/users/%s/%s", user.ID, sub.ID), http.StatusSeeOther)
 }
 
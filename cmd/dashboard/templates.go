@@ -3,39 +3,106 @@ package main
 import (
 	"html/template"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
 
+	"github.com/alghurabi0/rehla/internal/dashboard_models"
+	"github.com/alghurabi0/rehla/internal/jobs"
 	"github.com/alghurabi0/rehla/internal/models"
 )
 
 type templateData struct {
-	ContactInfo        *models.ContactInfo
-	Course             *models.Course
-	Courses            *[]models.Course
-	Lec                *models.Lec
-	Lecs               *[]models.Lec
-	Exam               *models.Exam
-	Exams              *[]models.Exam
-	Material           *models.Material
-	Materials          *[]models.Material
-	Answer             *models.Answer
-	Answers            *[]models.Answer
-	UncorrectedAnswers *[]models.Answer
-	CorrectedAnswers   *[]models.Answer
-	User               *models.User
-	Users              *[]models.User
-	Sub                *models.Subscription
-	Subs               *[]models.Subscription
-	Payment            *models.Payment
-	Payments           *[]models.Payment
-	IsLoggedIn         bool
-	IsAdmin            bool
-	TemplateTitle      string
-	HxMethod           string
-	HxRoute            string
-	WistiaToken        string
+	ContactInfo   *models.ContactInfo
+	Course        *models.Course
+	Courses       *[]models.Course
+	Lec           *models.Lec
+	Lecs          *[]models.Lec
+	Section       *models.Section
+	Sections      *[]models.Section
+	Exam          *models.Exam
+	Exams         *[]models.Exam
+	Question      *models.Question
+	Questions     *[]models.Question
+	Material      *models.Material
+	Materials     *[]models.Material
+	Answer        *models.Answer
+	Answers       *[]models.Answer
+	User          *models.User
+	Users         *[]models.User
+	Sub           *models.Subscription
+	Subs          *[]models.Subscription
+	Payment       *models.Payment
+	Payments      *[]models.Payment
+	Jobs          *[]jobs.Job
+	Inquiry       *models.Contact
+	Inquiries     *[]models.Contact
+	Staff         *[]dashboard_models.DashboardUser
+	FailedJobs    *[]jobs.Job
+	QueueDepths   *[]models.QueueDepth
+	Claim         *models.QueueItem
+	Appeal        *models.Appeal
+	Appeals       *[]models.Appeal
+	AppealRates   *[]models.AppealRate
+	Gradebook     *models.Gradebook
+	Match         *models.AnswerMatch
+	Clusters      *[]models.MatchCluster
+	IsLoggedIn    bool
+	IsAdmin       bool
+	TemplateTitle string
+	HxMethod      string
+	HxRoute       string
+	Status        string
+	Search        string
+	Corrector     string
+	Sort          string
+	NextPage      string
+	Pseudonym     string
+	Mark          *models.Mark
+	WistiaToken   string
 }
 
-var functions = template.FuncMap{}
+var functions = template.FuncMap{
+	"choiceNumber": choiceNumber,
+	"hasString":    slices.Contains[[]string],
+	"examTime":     examTime,
+	"inputTime":    inputTime,
+	"prerequisite": prerequisite,
+}
+
+// examTime formats exam schedule times in the timezone they were set in.
+func examTime(t time.Time) string {
+	return t.In(models.ExamTimezone).Format("2006-01-02 15:04")
+}
+
+// inputTime formats t for datetime-local inputs, empty for zero times.
+func inputTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(models.ExamTimezone).Format(datetimeLayout)
+}
+
+// prerequisite returns the lecture's or exam's prerequisite on the kind of
+// content with id, nil when it has none.
+func prerequisite(prereqs []models.Prerequisite, kind, id string) *models.Prerequisite {
+	for i := range prereqs {
+		if prereqs[i].Kind == kind && prereqs[i].ID == id {
+			return &prereqs[i]
+		}
+	}
+	return nil
+}
+
+// choiceNumber is the 1 based number of the correct choice of an mcq
+// question, the way admins enter it.
+func choiceNumber(answer string) string {
+	n, err := strconv.Atoi(answer)
+	if err != nil {
+		return ""
+	}
+	return strconv.Itoa(n + 1)
+}
 
 func newTemplateCache() (map[string]*template.Template, error) {
 	cache := map[string]*template.Template{}
@@ -1,19 +1,27 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/alghurabi0/rehla/internal/models"
+	"github.com/alghurabi0/rehla/internal/pdf"
 	"github.com/alghurabi0/rehla/internal/validator"
 )
 
+// limits for answer uploads, each file is also limited to 10MB.
+const (
+	maxAnswerFiles  = 20
+	maxAnswerUpload = 60 << 20
+)
+
 func (app *application) createAnswer(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	if !data.IsLoggedIn {
@@ -50,46 +58,95 @@ func (app *application) createAnswer(w http.ResponseWriter, r *http.Request) {
 		app.render(w, http.StatusOK, "fail_exam.tmpl.html", data)
 		return
 	}
-
-	err = r.ParseMultipartForm(10 << 20)
+	session, err := app.examSession.Get(ctx, userId, courseId, examId)
 	if err != nil {
+		app.serverErrorLog(err)
 		data.HxRoute = fail_route
 		app.render(w, http.StatusOK, "fail_exam.tmpl.html", data)
 		return
 	}
-	file, handler, err := r.FormFile("answer_file")
-	if err != nil {
-		if err == http.ErrMissingFile {
+	access, err := exam.Access(time.Now(), session.StartedAt)
+	if err == nil {
+		var attempts int
+		attempts, err = app.answer.CountAttempts(ctx, userId, courseId, examId)
+		if err != nil {
+			app.serverErrorLog(err)
+			data.HxRoute = fail_route
+			app.render(w, http.StatusOK, "fail_exam.tmpl.html", data)
+			return
+		}
+		if !exam.CanAttempt(attempts) {
+			err = models.ErrNoAttemptsLeft
+		}
+	}
+	if err == nil {
+		var unmet []models.Prerequisite
+		unmet, err = app.unmetPrerequisites(ctx, r, courseId, exam.Prerequisites)
+		if err != nil {
+			app.serverErrorLog(err)
 			data.HxRoute = fail_route
 			app.render(w, http.StatusOK, "fail_exam.tmpl.html", data)
-			app.infoLog.Println("missing answer file")
 			return
 		}
+		if len(unmet) > 0 {
+			err = models.ErrPrerequisitesUnmet
+		}
+	}
+	if err != nil {
+		data.ExamUnavailable = examUnavailable(exam, err)
+		data.HxRoute = fmt.Sprintf("/courses/%s", courseId)
+		app.render(w, http.StatusOK, "closed_exam.tmpl.html", data)
+		return
+	}
+	if exam.IsOnline() {
+		app.createOnlineAnswer(w, r, data, exam, userId, access)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAnswerUpload)
+	err = r.ParseMultipartForm(32 << 20)
+	if err != nil {
 		data.HxRoute = fail_route
 		app.render(w, http.StatusOK, "fail_exam.tmpl.html", data)
-		app.errorLog.Println(err)
 		return
 	}
-	defer file.Close()
-
-	// validation
-	v := validator.Validator{}
-	buf := make([]byte, 512)
-	_, err = file.Read(buf)
-	if err != nil && err != io.EOF {
-		app.serverErrorLog(err)
+	// pages are merged in the order they were picked, answer_file is sent by
+	// pages loaded before multi-page uploads
+	files := r.MultipartForm.File["answer_files"]
+	if len(files) == 0 {
+		files = r.MultipartForm.File["answer_file"]
+	}
+	if len(files) == 0 {
 		data.HxRoute = fail_route
 		app.render(w, http.StatusOK, "fail_exam.tmpl.html", data)
+		app.infoLog.Println("missing answer file")
 		return
 	}
-	file.Seek(0, io.SeekStart)
+
+	// validation
+	v := validator.Validator{}
+	v.Check(len(files) <= maxAnswerFiles, "files", fmt.Sprintf("you can upload %d files or less", maxAnswerFiles))
 	allowedTypes := map[string]bool{
 		"image/jpeg":      true,
 		"image/png":       true,
 		"application/pdf": true,
 	}
-	v.Check(validator.FileTypeAllowed(buf, allowedTypes), "file_type", "file type is not allowed")
-	v.Check(validator.FileSize(handler, 10*1024*1024), "file_size", "file size must be 10MB or less")
+	parts := make([][]byte, 0, len(files))
+	for _, handler := range files {
+		v.Check(validator.FileSize(handler, 10*1024*1024), "file_size", "file size must be 10MB or less")
+		if !v.Valid() {
+			break
+		}
+		part, err := readFormFile(handler)
+		if err != nil {
+			app.serverErrorLog(err)
+			data.HxRoute = fail_route
+			app.render(w, http.StatusOK, "fail_exam.tmpl.html", data)
+			return
+		}
+		v.Check(validator.FileTypeAllowed(part, allowedTypes), "file_type", "file type is not allowed")
+		parts = append(parts, part)
+	}
 	if v.Errors != nil {
 		err = json.NewEncoder(w).Encode(v.Errors)
 		if err != nil {
@@ -104,8 +161,22 @@ func (app *application) createAnswer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	path := fmt.Sprintf("courses/%s/exams/%s/answers/%s", courseId, examId, userId)
-	url, object, err := app.storage.UploadFile(ctx, file, *handler, path)
+	// the corrector gets one document with every page
+	merged, err := pdf.Merge(parts)
+	if err != nil {
+		// a lone pdf we can't read is still worth keeping as it is
+		if len(parts) != 1 || !pdf.IsPDF(parts[0]) {
+			app.infoLog.Println("couldn't merge answer files:", err)
+			data.HxRoute = fail_route
+			app.render(w, http.StatusOK, "fail_exam.tmpl.html", data)
+			return
+		}
+		merged = parts[0]
+	}
+
+	// every attempt keeps its own file
+	path := fmt.Sprintf("courses/%s/exams/%s/answers/%s/%d.pdf", courseId, examId, userId, time.Now().Unix())
+	url, object, err := app.storage.Upload(ctx, bytes.NewReader(merged), "application/pdf", path)
 	if err != nil {
 		app.serverErrorLog(err)
 		data.HxRoute = fail_route
@@ -122,23 +193,113 @@ func (app *application) createAnswer(w http.ResponseWriter, r *http.Request) {
 		StoragePath:      path,
 		Corrected:        false,
 		DateOfSubmission: time.Now(),
+		Late:             access.Late,
+		Penalty:          access.Penalty,
 	}
-	err = app.answer.Create(ctx, answer)
+	err = app.answer.CreateAttempt(ctx, answer, exam.CountingRule, exam.MaxAttempts)
 	if err != nil {
 		deleterErr := object.Delete(ctx)
 		if deleterErr != nil {
 			app.serverErrorLog(deleterErr)
 		}
+		if errors.Is(err, models.ErrNoAttemptsLeft) {
+			data.ExamUnavailable = examUnavailable(exam, err)
+			data.HxRoute = fmt.Sprintf("/courses/%s", courseId)
+			app.render(w, http.StatusOK, "closed_exam.tmpl.html", data)
+			return
+		}
 		app.serverErrorLog(err)
 		data.HxRoute = fail_route
 		app.render(w, http.StatusOK, "fail_exam.tmpl.html", data)
 		return
 	}
 
+	// pages that look like another student's are flagged to the corrector,
+	// the answer is in either way
+	_, err = app.similarity.Check(ctx, courseId, exam.Title, models.NewFingerprint(answer, parts))
+	if err != nil {
+		app.serverErrorLog(err)
+	}
+
+	app.endExamSession(ctx, exam, userId)
+
 	data.HxRoute = fmt.Sprintf("/courses/%s", courseId)
 	app.render(w, http.StatusOK, "success_exam.tmpl.html", data)
 }
 
+// endExamSession resets the student's timer so another attempt gets the full
+// time limit.
+func (app *application) endExamSession(ctx context.Context, exam *models.Exam, userId string) {
+	if exam.TimeLimit == 0 {
+		return
+	}
+	err := app.examSession.Delete(ctx, userId, exam.CourseId, exam.ID)
+	if err != nil {
+		app.serverErrorLog(err)
+	}
+}
+
+// createOnlineAnswer grades the submitted responses against the exam's
+// questions and stores them as a new attempt.
+func (app *application) createOnlineAnswer(w http.ResponseWriter, r *http.Request, data *templateData, exam *models.Exam, userId string, access models.ExamAccess) {
+	ctx := context.Background()
+	fail_route := fmt.Sprintf("/courses/%s/exam/%s", exam.CourseId, exam.ID)
+	err := r.ParseForm()
+	if err != nil {
+		data.HxRoute = fail_route
+		app.render(w, http.StatusOK, "fail_exam.tmpl.html", data)
+		return
+	}
+	// grade against the bank, not the cached exam page
+	questions, err := app.question.GetMany(ctx, exam.CourseId, exam.QuestionIds)
+	if err != nil {
+		app.serverErrorLog(err)
+		data.HxRoute = fail_route
+		app.render(w, http.StatusOK, "fail_exam.tmpl.html", data)
+		return
+	}
+	responses := make(map[string]string, len(*questions))
+	for _, q := range *questions {
+		responses[q.ID] = r.PostForm.Get(fmt.Sprintf("q_%s", q.ID))
+	}
+	items, grade, maxGrade := models.GradeResponses(*questions, responses)
+
+	answer := &models.Answer{
+		UserId:           userId,
+		CourseId:         exam.CourseId,
+		ExamId:           exam.ID,
+		ExamTitle:        exam.Title,
+		Grade:            models.ApplyPenalty(grade, access.Penalty),
+		MaxGrade:         maxGrade,
+		Items:            items,
+		Corrected:        true,
+		Corrector:        "auto",
+		DateOfSubmission: time.Now(),
+		CorrectedAt:      time.Now(),
+		Late:             access.Late,
+		Penalty:          access.Penalty,
+		Withheld:         exam.WithholdsGrades(),
+	}
+	err = app.answer.CreateAttempt(ctx, answer, exam.CountingRule, exam.MaxAttempts)
+	if err != nil {
+		if errors.Is(err, models.ErrNoAttemptsLeft) {
+			data.ExamUnavailable = examUnavailable(exam, err)
+			data.HxRoute = fmt.Sprintf("/courses/%s", exam.CourseId)
+			app.render(w, http.StatusOK, "closed_exam.tmpl.html", data)
+			return
+		}
+		app.serverErrorLog(err)
+		data.HxRoute = fail_route
+		app.render(w, http.StatusOK, "fail_exam.tmpl.html", data)
+		return
+	}
+	app.endExamSession(ctx, exam, userId)
+
+	data.Answer = answer
+	data.HxRoute = fmt.Sprintf("/progress/%s/%s", exam.CourseId, exam.ID)
+	app.render(w, http.StatusOK, "graded_exam.tmpl.html", data)
+}
+
 func (app *application) progressPage(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	if !data.IsLoggedIn {
@@ -187,7 +348,19 @@ func (app *application) gradesPage(w http.ResponseWriter, r *http.Request) {
 		app.serverError(w, err)
 		return
 	}
+	exams, err := app.getExams(ctx, courseId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	cohort, err := app.getCohort(ctx, courseId, *exams)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	performance := models.StudentPerformance(*exams, *answers, cohort)
 	data.Answers = answers
+	data.Performance = &performance
 	data.User = user
 	app.renderFull(w, http.StatusOK, "grades.tmpl.html", data)
 }
@@ -228,8 +401,100 @@ func (app *application) answerPage(w http.ResponseWriter, r *http.Request) {
 		app.serverError(w, err)
 		return
 	}
+	attempts, err := app.answer.GetAttempts(ctx, user.ID, courseId, examId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	// the attempt that counts is the one students appeal
+	appeal, err := app.appeal.GetForAttempt(ctx, user.ID, courseId, examId, max(answer.Attempt, 1))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 	data.ExamURL = exam.URL
+	data.Exam = exam
 	data.Answer = answer
+	data.Answers = attempts
+	data.Appeal = appeal
+	data.CanAppeal = appeal == nil && exam.CanAppeal(answer, time.Now())
 	data.User = user
 	app.renderFull(w, http.StatusOK, "answer.tmpl.html", data)
 }
+
+// maxAppealReason keeps appeals to a few paragraphs.
+const maxAppealReason = 2000
+
+// appealGrade files the student's appeal of the grade that counts for the
+// exam, while the exam's appeal window is open.
+func (app *application) appealGrade(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	if !data.IsLoggedIn {
+		app.unauthorized(w, "loginRequired")
+		return
+	}
+	if !data.IsSubscribed {
+		app.unauthorized(w, "subRequired")
+		return
+	}
+	user, err := app.getUser(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	courseId := r.PathValue("courseId")
+	if strings.TrimSpace(courseId) == "" {
+		app.notFound(w)
+		return
+	}
+	examId := r.PathValue("examId")
+	if strings.TrimSpace(examId) == "" {
+		app.notFound(w)
+		return
+	}
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	reason := strings.TrimSpace(r.PostFormValue("reason"))
+	if reason == "" || utf8.RuneCountInString(reason) > maxAppealReason {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	ctx := context.Background()
+	exam, err := app.getExam(ctx, courseId, examId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	answer, err := app.answer.Get(ctx, user.ID, courseId, examId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !exam.CanAppeal(answer, time.Now()) {
+		http.Error(w, models.ErrAppealClosed.Error(), http.StatusForbidden)
+		return
+	}
+	err = app.appeal.Create(ctx, &models.Appeal{
+		UserId:     user.ID,
+		CourseId:   courseId,
+		ExamId:     examId,
+		ExamTitle:  answer.ExamTitle,
+		Attempt:    max(answer.Attempt, 1),
+		Reason:     reason,
+		Correctors: answer.Correctors(),
+		OldGrade:   answer.Grade,
+		MaxGrade:   answer.MaxGrade,
+	})
+	if errors.Is(err, models.ErrAppealExists) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	w.Header().Set("HX-Redirect", fmt.Sprintf("/progress/%s/%s", courseId, examId))
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/alghurabi0/rehla/internal/models"
+)
+
+// certificatePage lets anyone holding a certificate, or scanning its QR
+// code, check that rehla issued it.
+func (app *application) certificatePage(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	id := models.NormalizeCertificateID(r.PathValue("id"))
+	if id == "" {
+		app.renderAuth(w, http.StatusNotFound, "certificate.tmpl.html", data)
+		return
+	}
+	cert, err := app.certificate.Get(context.Background(), id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if cert == nil {
+		app.renderAuth(w, http.StatusNotFound, "certificate.tmpl.html", data)
+		return
+	}
+	err = app.certificate.SignURL(cert)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	data.Certificate = cert
+	app.renderAuth(w, http.StatusOK, "certificate.tmpl.html", data)
+}
@@ -7,3 +7,4 @@ const isLoggedInContextKey = contextKey("isLoggedIn")
 // const userIdContextKey = contextKey("userId")
 const userModelContextKey = contextKey("userStruct")
 const isSubscribedContextKey = contextKey("isSubscribed")
+const parentContextKey = contextKey("parent")
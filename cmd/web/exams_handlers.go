@@ -4,6 +4,9 @@ import (
 	"context"
 	"net/http"
 	"strings"
+	"time"
+
+	"github.com/alghurabi0/rehla/internal/models"
 )
 
 func (app *application) examPage(w http.ResponseWriter, r *http.Request) {
@@ -41,5 +44,60 @@ func (app *application) examPage(w http.ResponseWriter, r *http.Request) {
 	data.Exam = exam
 	data.User = user
 	data.TemplateTitle = exam.Title
+
+	unmet, err := app.unmetPrerequisites(ctx, r, courseId, exam.Prerequisites)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if len(unmet) > 0 {
+		data.Requires = unmet
+		data.ExamUnavailable = examUnavailable(exam, models.ErrPrerequisitesUnmet)
+		app.renderFull(w, http.StatusOK, "exam.tmpl.html", data)
+		return
+	}
+
+	attempts, err := app.answer.CountAttempts(ctx, user.ID, courseId, examId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	data.Attempts = attempts
+	if !exam.CanAttempt(attempts) {
+		data.ExamUnavailable = examUnavailable(exam, models.ErrNoAttemptsLeft)
+		app.renderFull(w, http.StatusOK, "exam.tmpl.html", data)
+		return
+	}
+
+	now := time.Now()
+	var startedAt time.Time
+	if exam.TimeLimit > 0 {
+		// the timer only starts while the exam can be taken
+		_, err = exam.Access(now, now)
+		if err == nil {
+			session, err := app.examSession.Start(ctx, user.ID, courseId, examId, now)
+			if err != nil {
+				app.serverError(w, err)
+				return
+			}
+			startedAt = session.StartedAt
+		}
+	}
+	access, err := exam.Access(now, startedAt)
+	if err != nil {
+		data.ExamUnavailable = examUnavailable(exam, err)
+		app.renderFull(w, http.StatusOK, "exam.tmpl.html", data)
+		return
+	}
+	data.ExamAccess = &access
+
+	if exam.IsOnline() {
+		questions, err := app.question.GetMany(ctx, courseId, exam.QuestionIds)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		data.Questions = questions
+	}
 	app.renderFull(w, http.StatusOK, "exam.tmpl.html", data)
 }
@@ -3,8 +3,10 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/alghurabi0/rehla/internal/models"
 )
@@ -81,6 +83,8 @@ func (app *application) coursePage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data := app.newTemplateData(r)
+	var progress []models.WatchProgress
+	var answers []models.Answer
 	if data.IsLoggedIn {
 		user, err := app.getUser(r)
 		if err != nil {
@@ -88,8 +92,51 @@ func (app *application) coursePage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		data.User = user
+		progress, err = app.getCourseWatchProgress(ctx, user.ID, course)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		data.Watched = models.ApplyWatchProgress(course.Lecs, progress)
+		if course.Certificates || course.HasPrerequisites() {
+			all, err := app.answer.GetAll(ctx, user.ID, courseId)
+			if err != nil {
+				app.serverError(w, err)
+				return
+			}
+			answers = *all
+		}
+		if course.Certificates {
+			completion := models.CheckCompletion(course, progress, answers)
+			data.Completion = &completion
+			data.Certificate, err = app.certificate.GetForCourse(ctx, user.ID, courseId)
+			if err != nil {
+				app.serverError(w, err)
+				return
+			}
+			if data.Certificate != nil {
+				err = app.certificate.SignURL(data.Certificate)
+				if err != nil {
+					app.serverError(w, err)
+					return
+				}
+			}
+			if completion.Complete && data.Certificate == nil {
+				app.requestCertificate(ctx, user.ID, courseId)
+			}
+		}
+	}
+
+	start, err := app.dripStart(ctx, r, course)
+	if err != nil {
+		app.serverError(w, err)
+		return
 	}
+	models.ApplyRelease(course, start, time.Now())
+	models.ApplyPrerequisites(course, progress, answers)
 
+	// grouped once the lectures have the student's progress
+	course.Sections = models.GroupSections(course.Sections, course.Lecs, course.Exams, course.Materials)
 	data.Course = course
 	app.renderFull(w, http.StatusOK, "course.tmpl.html", data)
 }
@@ -205,7 +252,13 @@ func (app *application) contactPage(w http.ResponseWriter, r *http.Request) {
 			app.serverError(w, err)
 			return
 		}
+		inquiries, err := app.contact.GetUserInquiries(ctx, user.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
 		data.User = user
+		data.Inquiries = inquiries
 	}
 	data.ContactInfo = contactInfo
 	app.renderFull(w, http.StatusOK, "contact.tmpl.html", data)
@@ -217,20 +270,35 @@ func (app *application) contactMessage(w http.ResponseWriter, r *http.Request) {
 		app.clientError(w, http.StatusBadRequest)
 		return
 	}
-	fullname := r.PostFormValue("fullname")
-	phone_number := r.PostFormValue("phone_number")
-	message := r.PostFormValue("message")
-	if fullname == "" || phone_number == "" || message == "" {
+	inquiry := &models.Contact{
+		Fullname:     r.PostFormValue("fullname"),
+		Phone_number: r.PostFormValue("phone_number"),
+		Message:      r.PostFormValue("message"),
+	}
+	// inquiries from logged in users show up in their contact page
+	if user, err := app.getUser(r); err == nil {
+		inquiry.UserId = user.ID
+		if inquiry.Fullname == "" {
+			inquiry.Fullname = fmt.Sprintf("%s %s", user.Firstname, user.Lastname)
+		}
+		if inquiry.Phone_number == "" {
+			inquiry.Phone_number = user.PhoneNumber
+		}
+	}
+	if inquiry.Fullname == "" || inquiry.Phone_number == "" || inquiry.Message == "" {
 		app.clientError(w, http.StatusBadRequest)
 		return
 	}
 	// TODO - validate
 	ctx := context.Background()
-	err = app.contact.SendInquiry(ctx, fullname, phone_number, message)
+	_, err = app.contact.SendInquiry(ctx, inquiry)
 	if err != nil {
 		app.clientError(w, http.StatusBadRequest)
 		return
 		// TODO - send errors
 	}
+	if inquiry.UserId != "" {
+		w.Header().Set("HX-Redirect", "/contact")
+	}
 	w.WriteHeader(http.StatusOK)
 }
@@ -6,13 +6,18 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"reflect"
 	"runtime/debug"
+	"strings"
 	"time"
 
 	"cloud.google.com/go/firestore"
+	"github.com/alghurabi0/rehla/internal/jobs"
 	"github.com/alghurabi0/rehla/internal/models"
+	"github.com/redis/go-redis/v9"
 )
 
 // serverError helper writes an error message and stack trace to the errorLog
@@ -65,7 +70,6 @@ func (app *application) isSubscribedCheck(r *http.Request) bool {
 func (app *application) getCourse(ctx context.Context, courseId string) (*models.Course, error) {
 	course := &models.Course{}
 	var lecs = &[]models.Lec{}
-	var exams = &[]models.Exam{}
 
 	foo, err := app.redis.Get(ctx, fmt.Sprintf("course:%s", courseId)).Result()
 	if err == nil {
@@ -110,28 +114,140 @@ func (app *application) getCourse(ctx context.Context, courseId string) (*models
 	course.Lecs = *lecs
 	course.NumberOfLecs = len(course.Lecs)
 
-	foo, err = app.redis.Get(ctx, fmt.Sprintf("course:%s:exams", courseId)).Result()
+	exams, err := app.getExams(ctx, courseId)
+	if err != nil {
+		return nil, err
+	}
+	course.Exams = *exams
+
+	sections, err := app.getSections(ctx, courseId)
+	if err != nil {
+		return nil, err
+	}
+	course.Sections = *sections
+	mats, err := app.getMaterials(ctx, courseId)
+	if err != nil {
+		return nil, err
+	}
+	course.Materials = *mats
+
+	return course, nil
+}
+
+// getSections returns the course's sections from the cache, or firestore
+// when they aren't cached.
+func (app *application) getSections(ctx context.Context, courseId string) (*[]models.Section, error) {
+	sections := &[]models.Section{}
+	foo, err := app.redis.Get(ctx, fmt.Sprintf("course:%s:sections", courseId)).Result()
 	if err == nil {
-		err = json.Unmarshal([]byte(foo), exams)
-		if err != nil {
-			app.errorLog.Println(err)
-			app.infoLog.Println("5 why am I here")
-			exams, err = app.exam.GetAll(ctx, courseId)
-			if err != nil {
-				return nil, err
-			}
+		err = json.Unmarshal([]byte(foo), sections)
+		if err == nil {
+			return sections, nil
 		}
-	} else {
-		app.infoLog.Println("6 why am I here")
+	}
+	if err != redis.Nil {
 		app.errorLog.Println(err)
-		exams, err = app.exam.GetAll(ctx, courseId)
-		if err != nil {
-			return nil, err
+	}
+	return app.section.GetAll(ctx, courseId)
+}
+
+// dripStart returns when the course's drip schedule started for the
+// student, zero when the course doesn't drip or they aren't subscribed.
+func (app *application) dripStart(ctx context.Context, r *http.Request, course *models.Course) (time.Time, error) {
+	if !course.Drip || !app.isSubscribedCheck(r) {
+		return time.Time{}, nil
+	}
+	return app.sub.StartedAt(ctx, app.getUserId(r), course.ID)
+}
+
+// lecReleased reports whether the lecture is out for the student.
+func (app *application) lecReleased(ctx context.Context, r *http.Request, lec *models.Lec) (bool, error) {
+	course, err := app.getCourseInfo(ctx, lec.CourseId)
+	if err != nil {
+		return false, err
+	}
+	start, err := app.dripStart(ctx, r, course)
+	if err != nil {
+		return false, err
+	}
+	return models.Released(lec.ReleasedAt(start), time.Now()), nil
+}
+
+// unmetPrerequisites returns what the student still has to do before a
+// lecture or exam of the course with the prerequisites opens, all of them
+// for visitors who aren't logged in.
+func (app *application) unmetPrerequisites(ctx context.Context, r *http.Request, courseId string, prereqs []models.Prerequisite) ([]models.Prerequisite, error) {
+	if len(prereqs) == 0 {
+		return nil, nil
+	}
+	course, err := app.getCourse(ctx, courseId)
+	if err != nil {
+		return nil, err
+	}
+	userId := app.getUserId(r)
+	if userId == "" {
+		return models.UnmetPrerequisites(prereqs, course, nil, nil), nil
+	}
+	progress, err := app.getCourseWatchProgress(ctx, userId, course)
+	if err != nil {
+		return nil, err
+	}
+	answers, err := app.answer.GetAll(ctx, userId, courseId)
+	if err != nil {
+		return nil, err
+	}
+	return models.UnmetPrerequisites(prereqs, course, progress, *answers), nil
+}
+
+// getExams returns the course's exams from the cache, or firestore when
+// they aren't cached.
+func (app *application) getExams(ctx context.Context, courseId string) (*[]models.Exam, error) {
+	exams := &[]models.Exam{}
+	foo, err := app.redis.Get(ctx, fmt.Sprintf("course:%s:exams", courseId)).Result()
+	if err == nil {
+		err = json.Unmarshal([]byte(foo), exams)
+		if err == nil {
+			return exams, nil
 		}
 	}
-	course.Exams = *exams
+	if err != redis.Nil {
+		app.errorLog.Println(err)
+	}
+	return app.exam.GetAll(ctx, courseId)
+}
 
-	return course, nil
+// cohortTTL is how stale the course averages behind percentiles can be.
+const cohortTTL = time.Hour
+
+// getCohort returns the course averages of every student, lowest first.
+// Working them out reads every answer in the course so they're cached.
+func (app *application) getCohort(ctx context.Context, courseId string, exams []models.Exam) ([]float64, error) {
+	key := fmt.Sprintf("course:%s:cohort", courseId)
+	var cohort []float64
+	foo, err := app.redis.Get(ctx, key).Result()
+	if err == nil {
+		err = json.Unmarshal([]byte(foo), &cohort)
+		if err == nil {
+			return cohort, nil
+		}
+	}
+	if err != redis.Nil {
+		app.errorLog.Println(err)
+	}
+	answers, err := app.answer.GetCourseAnswers(ctx, courseId)
+	if err != nil {
+		return nil, err
+	}
+	cohort = models.CohortAverages(exams, *answers)
+	re, err := json.Marshal(cohort)
+	if err != nil {
+		return nil, err
+	}
+	err = app.redis.Set(ctx, key, re, cohortTTL).Err()
+	if err != nil {
+		app.errorLog.Println(err)
+	}
+	return cohort, nil
 }
 
 func (app *application) getCourseInfo(ctx context.Context, courseId string) (*models.Course, error) {
@@ -180,6 +296,55 @@ func (app *application) getLec(ctx context.Context, courseId, lecId string) (*mo
 	return lec, nil
 }
 
+// getWatchProgress returns the student's progress in the lecture, buffered
+// heartbeats first. nil when they never played it.
+func (app *application) getWatchProgress(ctx context.Context, userId, courseId, lecId string) (*models.WatchProgress, error) {
+	progress, err := app.watchBuf.Get(ctx, userId, courseId, lecId)
+	if err != nil || progress != nil {
+		return progress, err
+	}
+	return app.watch.Get(ctx, userId, courseId, lecId)
+}
+
+// getCourseWatchProgress returns the student's progress in the lectures of
+// the course they played, buffered progress is newer than the flushed.
+func (app *application) getCourseWatchProgress(ctx context.Context, userId string, course *models.Course) ([]models.WatchProgress, error) {
+	flushed, err := app.watch.GetCourse(ctx, userId, course.ID)
+	if err != nil {
+		return nil, err
+	}
+	lecIds := make([]string, len(course.Lecs))
+	for i, lec := range course.Lecs {
+		lecIds[i] = lec.ID
+	}
+	buffered, err := app.watchBuf.GetCourse(ctx, userId, course.ID, lecIds)
+	if err != nil {
+		return nil, err
+	}
+	// later entries win in ApplyWatchProgress
+	return append(*flushed, buffered...), nil
+}
+
+// requestCertificate asks the worker to issue the student's certificate,
+// once every few minutes at most while they reload the course page.
+func (app *application) requestCertificate(ctx context.Context, userId, courseId string) {
+	key := fmt.Sprintf("certificate:pending:%s:%s", userId, courseId)
+	ok, err := app.redis.SetNX(ctx, key, 1, 10*time.Minute).Result()
+	if err != nil {
+		app.errorLog.Println(err)
+		return
+	}
+	// already queued
+	if !ok {
+		return
+	}
+	_, err = app.jobs.Enqueue(ctx, jobs.IssueCertificate, jobs.IssueCertificatePayload{UserId: userId, CourseId: courseId})
+	if err != nil {
+		app.errorLog.Printf("failed to queue certificate for user %s: %v\n", userId, err)
+		app.redis.Del(ctx, key)
+	}
+}
+
 func (app *application) getExam(ctx context.Context, courseId, examId string) (*models.Exam, error) {
 	exam := &models.Exam{}
 	foo, err := app.redis.Get(ctx, fmt.Sprintf("course:%s:exam:%s", courseId, examId)).Result()
@@ -203,6 +368,34 @@ func (app *application) getExam(ctx context.Context, courseId, examId string) (*
 	return exam, nil
 }
 
+// examUnavailable explains to the student why they can't take the exam.
+func examUnavailable(exam *models.Exam, err error) string {
+	switch err {
+	case models.ErrExamNotOpen:
+		return fmt.Sprintf("يبدأ الاختبار في %s", examTime(exam.OpensAt))
+	case models.ErrExamNotStarted:
+		return "يجب فتح صفحة الاختبار قبل ارسال الاجابة"
+	case models.ErrNoAttemptsLeft:
+		return "لقد استنفدت جميع محاولات هذا الاختبار"
+	case models.ErrNotReleased:
+		return fmt.Sprintf("يتاح الاختبار في %s", examTime(exam.PublishAt))
+	case models.ErrPrerequisitesUnmet:
+		return "يجب اكمال متطلبات هذا الاختبار اولا"
+	default:
+		return "انتهى وقت الاختبار ولم يعد بالامكان ارسال الاجابات"
+	}
+}
+
+// readFormFile reads a whole uploaded file into memory.
+func readFormFile(handler *multipart.FileHeader) ([]byte, error) {
+	file, err := handler.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
 func (app *application) getMaterials(ctx context.Context, courseId string) (*[]models.Material, error) {
 	var materials = &[]models.Material{}
 	foo, err := app.redis.Get(ctx, fmt.Sprintf("course:%s:mats", courseId)).Result()
@@ -240,6 +433,20 @@ func (app *application) getUser(r *http.Request) (*models.User, error) {
 	return user, nil
 }
 
+func (app *application) getParent(r *http.Request) (*models.Parent, bool) {
+	parent, ok := r.Context().Value(parentContextKey).(*models.Parent)
+	return parent, ok
+}
+
+// localPhoneNumber converts a firebase auth phone number (+9647XXXXXXXXX)
+// to the local format users sign up with (07XXXXXXXXX).
+func localPhoneNumber(phone string) string {
+	if strings.HasPrefix(phone, "+964") {
+		return "0" + strings.TrimPrefix(phone, "+964")
+	}
+	return phone
+}
+
 func (app *application) unauthorized(w http.ResponseWriter, msg string) {
 	w.WriteHeader(http.StatusUnauthorized)
 	w.Header().Set("Content-Type", "text/plain")
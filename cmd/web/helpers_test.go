@@ -0,0 +1,12 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/alghurabi0/rehla/internal/assert"
+)
+
+func TestLocalPhoneNumber(t *testing.T) {
+	assert.Equal(t, localPhoneNumber("+9647801234567"), "07801234567")
+	assert.Equal(t, localPhoneNumber("07801234567"), "07801234567")
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/alghurabi0/rehla/internal/models"
+)
+
+func (app *application) inquiryPage(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	if !data.IsLoggedIn {
+		app.unauthorized(w, "loginRequired")
+		return
+	}
+	user, err := app.getUser(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	inquiry, ok := app.getUserInquiry(w, r, user.ID)
+	if !ok {
+		return
+	}
+	data.User = user
+	data.Inquiry = inquiry
+	app.renderFull(w, http.StatusOK, "inquiry.tmpl.html", data)
+}
+
+// replyToInquiry adds the student's reply, a reply to a closed inquiry opens
+// it again.
+func (app *application) replyToInquiry(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	if !data.IsLoggedIn {
+		app.unauthorized(w, "loginRequired")
+		return
+	}
+	user, err := app.getUser(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	inquiry, ok := app.getUserInquiry(w, r, user.ID)
+	if !ok {
+		return
+	}
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	body := strings.TrimSpace(r.PostFormValue("body"))
+	if body == "" {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	status := inquiry.Status
+	if status == models.InquiryClosed {
+		status = models.InquiryOpen
+	}
+	reply := &models.Reply{
+		Author: fmt.Sprintf("%s %s", user.Firstname, user.Lastname),
+		Body:   body,
+	}
+	_, err = app.contact.AddReply(context.Background(), inquiry.ID, status, reply)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	w.Header().Set("HX-Redirect", fmt.Sprintf("/contact/%s", inquiry.ID))
+}
+
+// getUserInquiry gets the inquiry in the path, it responds with not found
+// when the inquiry belongs to someone else.
+func (app *application) getUserInquiry(w http.ResponseWriter, r *http.Request, userId string) (*models.Contact, bool) {
+	inquiryId := r.PathValue("inquiryId")
+	if strings.TrimSpace(inquiryId) == "" {
+		app.notFound(w)
+		return nil, false
+	}
+	inquiry, err := app.contact.GetInquiry(context.Background(), inquiryId)
+	if err != nil || inquiry.UserId != userId {
+		app.notFound(w)
+		return nil, false
+	}
+	return inquiry, true
+}
@@ -2,8 +2,18 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"strings"
+
+	"github.com/alghurabi0/rehla/internal/models"
+)
+
+// players send a heartbeat every few seconds and a batch every few
+// heartbeats, anything bigger didn't come from the lecture page
+const (
+	maxHeartbeats  = 200
+	maxWatchUpload = 64 << 10
 )
 
 func (app *application) lecPage(w http.ResponseWriter, r *http.Request) {
@@ -24,10 +34,33 @@ func (app *application) lecPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	data := app.newTemplateData(r)
-	if lec.Order > 3 && !data.IsSubscribed && !lec.Free {
+	sections, err := app.getSections(ctx, courseId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !data.IsSubscribed && !lec.Previewable(*sections) {
 		app.unauthorized(w, "subRequired")
 		return
 	}
+	released, err := app.lecReleased(ctx, r, lec)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !released {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+	unmet, err := app.unmetPrerequisites(ctx, r, courseId, lec.Prerequisites)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if len(unmet) > 0 {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
 	if data.IsLoggedIn {
 		user, err := app.getUser(r)
 		if err != nil {
@@ -35,8 +68,85 @@ func (app *application) lecPage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		data.User = user
+		data.Watch, err = app.getWatchProgress(ctx, user.ID, courseId, lecId)
+		if err != nil {
+			// the lecture still plays, from the start
+			app.serverErrorLog(err)
+		}
 	}
 	data.Lec = lec
 	data.TemplateTitle = lec.Title
 	app.renderFull(w, http.StatusOK, "lec.tmpl.html", data)
 }
+
+// recordWatch takes a batch of the lecture player's heartbeats.
+func (app *application) recordWatch(w http.ResponseWriter, r *http.Request) {
+	courseId := r.PathValue("courseId")
+	if strings.TrimSpace(courseId) == "" {
+		app.notFound(w)
+		return
+	}
+	lecId := r.PathValue("lecId")
+	if strings.TrimSpace(lecId) == "" {
+		app.notFound(w)
+		return
+	}
+	data := app.newTemplateData(r)
+	if !data.IsLoggedIn {
+		app.unauthorized(w, "loginRequired")
+		return
+	}
+	userId := app.getUserId(r)
+	if userId == "" {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	ctx := context.Background()
+	lec, err := app.getLec(ctx, courseId, lecId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	sections, err := app.getSections(ctx, courseId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !data.IsSubscribed && !lec.Previewable(*sections) {
+		app.unauthorized(w, "subRequired")
+		return
+	}
+	released, err := app.lecReleased(ctx, r, lec)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !released {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxWatchUpload)
+	var batch struct {
+		Beats []models.Heartbeat `json:"beats"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&batch)
+	if err != nil || len(batch.Beats) > maxHeartbeats {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	for _, beat := range batch.Beats {
+		if !beat.Valid() {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+	err = app.watchBuf.Record(ctx, userId, courseId, lecId, batch.Beats, func() (*models.WatchProgress, error) {
+		return app.watch.Get(ctx, userId, courseId, lecId)
+	})
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
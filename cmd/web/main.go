@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"expvar"
 	"flag"
 	"fmt"
@@ -15,11 +14,14 @@ import (
 
 	"cloud.google.com/go/firestore"
 	firebase "firebase.google.com/go"
+	"firebase.google.com/go/auth"
 	"firebase.google.com/go/storage"
 	"github.com/alexedwards/scs/redisstore"
 	"github.com/alexedwards/scs/v2"
 	"github.com/alghurabi0/rehla/internal/fileStorage"
+	"github.com/alghurabi0/rehla/internal/jobs"
 	"github.com/alghurabi0/rehla/internal/models"
+	"github.com/alghurabi0/rehla/internal/watch"
 	"github.com/redis/go-redis/v9"
 	"google.golang.org/api/option"
 )
@@ -30,16 +32,28 @@ type application struct {
 	templateCache map[string]*template.Template
 	course        *models.CourseModel
 	lec           *models.LecModel
+	section       *models.SectionModel
 	exam          *models.ExamModel
+	question      *models.QuestionModel
+	examSession   *models.ExamSessionModel
 	material      *models.MaterialModel
 	answer        *models.AnswerModel
+	appeal        *models.AppealModel
+	similarity    *models.SimilarityModel
 	user          *models.UserModel
 	sub           *models.SubscriptionModel
 	payment       *models.PaymentModel
 	contact       *models.ContactModel
+	device        *models.DeviceModel
+	notification  *models.NotificationModel
+	watch         *models.WatchModel
+	certificate   *models.CertificateModel
+	watchBuf      *watch.Buffer
+	jobs          *jobs.Queue
 	session       *scs.SessionManager
 	storage       *fileStorage.StorageModel
 	redis         *redis.Client
+	auth          *auth.Client
 }
 
 var version string
@@ -62,7 +76,7 @@ func main() {
 	errorLog := log.New(os.Stderr, "Error\t", log.Ldate|log.Ltime|log.Lshortfile)
 
 	ctx := context.Background()
-	db, strg, err := initDB_AUTH(ctx, *credFile, *dfBkt)
+	db, strg, authClient, err := initDB_AUTH(ctx, *credFile, *dfBkt)
 	if err != nil {
 		errorLog.Fatal(err)
 	}
@@ -103,16 +117,28 @@ func main() {
 		templateCache: templateCache,
 		course:        &models.CourseModel{DB: db},
 		lec:           &models.LecModel{DB: db},
+		section:       &models.SectionModel{DB: db},
 		exam:          &models.ExamModel{DB: db, ST: strg},
+		question:      &models.QuestionModel{DB: db},
+		examSession:   &models.ExamSessionModel{DB: db},
 		material:      &models.MaterialModel{DB: db, ST: strg},
 		answer:        &models.AnswerModel{DB: db, ST: strg},
+		appeal:        &models.AppealModel{DB: db},
+		similarity:    &models.SimilarityModel{DB: db},
 		user:          &models.UserModel{DB: db},
 		sub:           &models.SubscriptionModel{DB: db},
 		payment:       &models.PaymentModel{DB: db},
 		contact:       &models.ContactModel{DB: db},
+		device:        &models.DeviceModel{DB: db},
+		notification:  &models.NotificationModel{DB: db},
 		session:       session,
 		storage:       &fileStorage.StorageModel{ST: strg},
 		redis:         rdb,
+		auth:          authClient,
+		jobs:          &jobs.Queue{Redis: rdb},
+		watch:         &models.WatchModel{DB: db},
+		watchBuf:      &watch.Buffer{Redis: rdb},
+		certificate:   &models.CertificateModel{DB: db, ST: strg},
 	}
 	/*
 		tlsConfig := &tls.Config{
@@ -129,35 +155,11 @@ func main() {
 		WriteTimeout: 10 * time.Second,
 	}
 
-	//
-	courses, err := app.course.GetAll(context.Background())
+	// the worker fills the course cache, handlers fall back to firestore until
+	// it's done
+	_, err = app.jobs.Enqueue(ctx, jobs.WarmCourseCache, nil)
 	if err != nil {
-		app.errorLog.Printf("failed to get courses--main\n")
-		return
-	}
-	for _, course := range *courses {
-		foo, err := json.Marshal(&course)
-		if err != nil {
-			app.errorLog.Printf("failed to marshal firestore doc to redis --main, err: %v \n", err)
-			return
-		}
-
-		err = app.redis.Set(context.Background(), fmt.Sprintf("course:%s", course.ID), foo, 0).Err()
-		if err != nil {
-			app.errorLog.Printf("failed to add course %v to redis, err: %v\n", course, err)
-			return
-		}
-	}
-	foo, err := json.Marshal(*courses)
-	if err != nil {
-		app.errorLog.Printf("failed to marshal firestore docs to redis --main, err: %v \n", err)
-		return
-	}
-
-	err = app.redis.Set(context.Background(), "courses", foo, 0).Err()
-	if err != nil {
-		app.errorLog.Printf("failed to add courses--main to redis, err: %v\n", err)
-		return
+		errorLog.Printf("failed to queue cache warm-up: %v\n", err)
 	}
 
 	infoLog.Printf("starting the srv and listening on %s", *addr)
@@ -165,7 +167,7 @@ func main() {
 	errorLog.Fatal(err)
 }
 
-func initDB_AUTH(ctx context.Context, credFile, dfBkt string) (*firestore.Client, *storage.Client, error) {
+func initDB_AUTH(ctx context.Context, credFile, dfBkt string) (*firestore.Client, *storage.Client, *auth.Client, error) {
 	opt := option.WithCredentialsFile(credFile)
 	cfg := &firebase.Config{
 		StorageBucket: dfBkt,
@@ -185,19 +187,24 @@ func initDB_AUTH(ctx context.Context, credFile, dfBkt string) (*firestore.Client
 		log.Fatalln(err)
 	}
 
+	authClient, err := app.Auth(ctx)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
 	docRef := firestoreClient.Collection("ping").Doc("test")
 	docSnapshot, err := docRef.Get(ctx)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	var data map[string]interface{}
 	if err := docSnapshot.DataTo(&data); err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	expectedValue := "pong"
 	if value, ok := data["ping"].(string); !ok || value != expectedValue {
-		return nil, nil, fmt.Errorf("ping test failed, expected %s, got %s", expectedValue, value)
+		return nil, nil, nil, fmt.Errorf("ping test failed, expected %s, got %s", expectedValue, value)
 	}
 
-	return firestoreClient, storageClient, nil
+	return firestoreClient, storageClient, authClient, nil
 }
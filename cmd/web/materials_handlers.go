@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/alghurabi0/rehla/internal/models"
 )
@@ -65,7 +66,7 @@ func (app *application) courseMaterials(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	course.Materials = *mats
+	course.Materials = models.ReleasedMaterials(*mats, time.Now())
 	data.Course = course
 	data.User = user
 	app.renderFull(w, http.StatusOK, "courseMaterials.tmpl.html", data)
@@ -159,3 +159,33 @@ func (app *application) isSubscribed(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+func (app *application) isParent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session_id := app.session.GetString(r.Context(), "parent_session_id")
+		if session_id == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := context.Background()
+		val, err := app.redis.Get(ctx, fmt.Sprintf("parent:%s", session_id)).Result()
+		if err != nil {
+			app.errorLog.Printf("couldn't get parent session: %s, error: %v", session_id, err)
+			app.session.PopString(r.Context(), "parent_session_id")
+			next.ServeHTTP(w, r)
+			return
+		}
+		var parent models.Parent
+		err = json.Unmarshal([]byte(val), &parent)
+		if err != nil {
+			app.errorLog.Printf("can't unmarshal json to parent: %v\n", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx = context.WithValue(r.Context(), parentContextKey, &parent)
+		r = r.WithContext(ctx)
+		next.ServeHTTP(w, r)
+	})
+}
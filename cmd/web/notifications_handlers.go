@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/alghurabi0/rehla/internal/models"
+	"github.com/alghurabi0/rehla/internal/notifications"
+)
+
+func (app *application) registerDevice(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	if !data.IsLoggedIn {
+		app.unauthorized(w, "loginRequired")
+		return
+	}
+	userId := app.getUserId(r)
+	if userId == "" {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	token := r.PostFormValue("token")
+	deviceId := r.PostFormValue("device_id")
+	if strings.TrimSpace(token) == "" || strings.TrimSpace(deviceId) == "" {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	device := &models.Device{
+		Token:     token,
+		UserAgent: r.UserAgent(),
+		UpdatedAt: time.Now(),
+	}
+	ctx := context.Background()
+	err = app.device.Save(ctx, userId, deviceId, device)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// notificationSettings stores the kinds the user unchecked as muted.
+func (app *application) notificationSettings(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	if !data.IsLoggedIn {
+		app.unauthorized(w, "loginRequired")
+		return
+	}
+	user, err := app.getUser(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	muted := []string{}
+	for _, kind := range notifications.Kinds {
+		if r.PostFormValue(kind) != "on" {
+			muted = append(muted, kind)
+		}
+	}
+	var updates []firestore.Update
+	updates = append(updates, firestore.Update{
+		Path:  "muted_notifications",
+		Value: muted,
+	})
+	ctx := context.Background()
+	err = app.user.Update(ctx, user.ID, updates)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	user.MutedNotifications = muted
+	re, err := json.Marshal(user)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	err = app.redis.Set(ctx, user.SessionId, re, time.Hour*24).Err()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// how many inbox entries the notifications page shows
+const inboxLimit = 50
+
+func (app *application) notificationsPage(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	if !data.IsLoggedIn {
+		app.unauthorized(w, "loginRequired")
+		return
+	}
+	user, err := app.getUser(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	ctx := context.Background()
+	notifications, err := app.notification.GetAll(ctx, user.ID, inboxLimit)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	data.User = user
+	data.Notifications = notifications
+	app.renderFull(w, http.StatusOK, "notifications.tmpl.html", data)
+}
+
+// unreadNotifications returns the unread count for the nav badge.
+func (app *application) unreadNotifications(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	if !data.IsLoggedIn {
+		app.unauthorized(w, "loginRequired")
+		return
+	}
+	userId := app.getUserId(r)
+	if userId == "" {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	count, err := app.notification.CountUnread(context.Background(), userId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(strconv.Itoa(count)))
+}
+
+// readNotification marks a notification as read and sends the user to the
+// page it points to.
+func (app *application) readNotification(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	if !data.IsLoggedIn {
+		app.unauthorized(w, "loginRequired")
+		return
+	}
+	userId := app.getUserId(r)
+	if userId == "" {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	notId := r.PathValue("notId")
+	if strings.TrimSpace(notId) == "" {
+		app.notFound(w)
+		return
+	}
+	ctx := context.Background()
+	notification, err := app.notification.Get(ctx, userId, notId)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+	err = app.notification.MarkRead(ctx, userId, notId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	link := notification.Link
+	if !strings.HasPrefix(link, "/") || strings.HasPrefix(link, "//") {
+		link = "/notifications"
+	}
+	w.Header().Set("HX-Redirect", link)
+}
+
+func (app *application) readAllNotifications(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	if !data.IsLoggedIn {
+		app.unauthorized(w, "loginRequired")
+		return
+	}
+	userId := app.getUserId(r)
+	if userId == "" {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	err := app.notification.MarkAllRead(context.Background(), userId)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	w.Header().Set("HX-Redirect", "/notifications")
+}
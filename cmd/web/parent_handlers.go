@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alghurabi0/rehla/internal/models"
+	"github.com/alghurabi0/rehla/internal/validator"
+)
+
+func (app *application) parentLoginPage(w http.ResponseWriter, r *http.Request) {
+	if _, ok := app.getParent(r); ok {
+		http.Redirect(w, r, "/parent", http.StatusSeeOther)
+		return
+	}
+	app.renderAuth(w, http.StatusOK, "parent_login.tmpl.html", nil)
+}
+
+// parentLogin expects a firebase id token obtained by the client after
+// confirming the otp sent to the parent's phone.
+func (app *application) parentLogin(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	idToken := r.PostFormValue("id_token")
+	if strings.TrimSpace(idToken) == "" {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	ctx := context.Background()
+	token, err := app.auth.VerifyIDToken(ctx, idToken)
+	if err != nil {
+		app.errorLog.Printf("failed to verify parent id token: %v\n", err)
+		app.clientError(w, http.StatusUnauthorized)
+		return
+	}
+	phone, ok := token.Claims["phone_number"].(string)
+	if !ok {
+		http.Error(w, "id token has no phone number", http.StatusBadRequest)
+		return
+	}
+	phone = localPhoneNumber(phone)
+	if !validator.ValidPhoneNumber(phone) {
+		http.Error(w, "invalid phone number", http.StatusBadRequest)
+		return
+	}
+	children, err := app.user.GetByParentPhone(ctx, phone)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if len(*children) == 0 {
+		http.Error(w, "لا يوجد طالب مرتبط بهذا الرقم", http.StatusNotFound)
+		return
+	}
+
+	parent := &models.Parent{
+		PhoneNumber: phone,
+		SessionId:   app.GenerateRandomID(),
+	}
+	re, err := json.Marshal(parent)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	err = app.redis.Set(ctx, fmt.Sprintf("parent:%s", parent.SessionId), re, time.Hour*24).Err()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r.Context(), "parent_session_id", parent.SessionId)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (app *application) parentPage(w http.ResponseWriter, r *http.Request) {
+	parent, ok := app.getParent(r)
+	if !ok {
+		http.Redirect(w, r, "/parent/login", http.StatusSeeOther)
+		return
+	}
+	ctx := context.Background()
+	users, err := app.user.GetByParentPhone(ctx, parent.PhoneNumber)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	var children []models.Child
+	for _, user := range *users {
+		child, err := app.getChild(ctx, user)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		children = append(children, *child)
+	}
+
+	data := app.newTemplateData(r)
+	data.Parent = parent
+	data.Children = &children
+	app.renderAuth(w, http.StatusOK, "parent.tmpl.html", data)
+}
+
+func (app *application) parentLogout(w http.ResponseWriter, r *http.Request) {
+	parent, ok := app.getParent(r)
+	if ok {
+		err := app.redis.Del(context.Background(), fmt.Sprintf("parent:%s", parent.SessionId)).Err()
+		if err != nil {
+			app.errorLog.Println(err)
+		}
+	}
+	app.session.PopString(r.Context(), "parent_session_id")
+	w.Header().Set("HX-Redirect", "/parent/login")
+}
+
+// getChild collects the courses a student is subscribed to, along with their
+// grades, payments and remaining balance for each one.
+func (app *application) getChild(ctx context.Context, user models.User) (*models.Child, error) {
+	subs, err := app.sub.GetAll(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	child := &models.Child{User: user}
+	for _, sub := range *subs {
+		course, err := app.getCourseInfo(ctx, sub.ID)
+		if err != nil {
+			return nil, err
+		}
+		payments, err := app.payment.GetAll(ctx, user.ID, sub.ID)
+		if err != nil {
+			return nil, err
+		}
+		answers, err := app.answer.GetAll(ctx, user.ID, sub.ID)
+		if err != nil {
+			return nil, err
+		}
+		totalPaid := 0
+		for _, payment := range *payments {
+			totalPaid += payment.AmountPaid
+		}
+		course.UserSubscription = sub
+		course.UserPayments = *payments
+		course.UserAmountPaid = totalPaid
+		course.UserBalance = max(course.Price-totalPaid, 0)
+		course.UserAnswers = *answers
+		child.Courses = append(child.Courses, *course)
+	}
+	return child, nil
+}
@@ -27,16 +27,20 @@ func (app *application) routes() http.Handler {
 		http.ServeFile(w, r, ".well-known/assetlinks.json")
 	})
 	mux.HandleFunc("GET /ping", app.ping)
+	// public so anyone can check a certificate, no session needed
+	mux.HandleFunc("GET /certificates/{id}", app.certificatePage)
 
 	// is logged in middleware
 	isLoggedIn := alice.New(app.session.LoadAndSave, app.isLoggedIn)
 	isSubscribed := alice.New(app.session.LoadAndSave, app.isLoggedIn, app.isSubscribed)
+	isParent := alice.New(app.session.LoadAndSave, app.isParent)
 
 	mux.Handle("GET /", isLoggedIn.ThenFunc(app.home))
 	mux.Handle("GET /courses", isLoggedIn.ThenFunc(app.courses))
 	mux.Handle("GET /courses/{courseId}", isSubscribed.ThenFunc(app.coursePage))
 
 	mux.Handle("GET /courses/{courseId}/lec/{lecId}", isSubscribed.ThenFunc(app.lecPage))
+	mux.Handle("POST /courses/{courseId}/lec/{lecId}/progress", isSubscribed.ThenFunc(app.recordWatch))
 
 	mux.Handle("GET /courses/{courseId}/exam/{examId}", isSubscribed.ThenFunc(app.examPage))
 	mux.Handle("POST /answers/{courseId}/{examId}", isSubscribed.ThenFunc(app.createAnswer))
@@ -48,6 +52,7 @@ func (app *application) routes() http.Handler {
 	mux.Handle("GET /progress", isLoggedIn.ThenFunc(app.progressPage))
 	mux.Handle("GET /progress/{courseId}", isSubscribed.ThenFunc(app.gradesPage))
 	mux.Handle("GET /progress/{courseId}/{examId}", isSubscribed.ThenFunc(app.answerPage))
+	mux.Handle("POST /progress/{courseId}/{examId}/appeal", isSubscribed.ThenFunc(app.appealGrade))
 
 	mux.Handle("GET /payments", isLoggedIn.ThenFunc(app.paymentsPage))
 	mux.Handle("GET /payments/{courseId}", isLoggedIn.ThenFunc(app.paymentHistory))
@@ -57,7 +62,15 @@ func (app *application) routes() http.Handler {
 	mux.Handle("GET /privacy_policy", isLoggedIn.ThenFunc(app.policyPage))
 	mux.Handle("GET /contact", isLoggedIn.ThenFunc(app.contactPage))
 	mux.Handle("POST /contact", isLoggedIn.ThenFunc(app.contactMessage))
+	mux.Handle("GET /contact/{inquiryId}", isLoggedIn.ThenFunc(app.inquiryPage))
+	mux.Handle("POST /contact/{inquiryId}/replies", isLoggedIn.ThenFunc(app.replyToInquiry))
 	mux.Handle("POST /change_profile_img", isLoggedIn.ThenFunc(app.changeProfileImg))
+	mux.Handle("POST /devices", isLoggedIn.ThenFunc(app.registerDevice))
+	mux.Handle("POST /notifications/settings", isLoggedIn.ThenFunc(app.notificationSettings))
+	mux.Handle("GET /notifications", isLoggedIn.ThenFunc(app.notificationsPage))
+	mux.Handle("GET /notifications/unread", isLoggedIn.ThenFunc(app.unreadNotifications))
+	mux.Handle("POST /notifications/read", isLoggedIn.ThenFunc(app.readAllNotifications))
+	mux.Handle("POST /notifications/{notId}/read", isLoggedIn.ThenFunc(app.readNotification))
 
 	mux.Handle("GET /reset", isLoggedIn.ThenFunc(app.resetPasswordPage))
 	mux.Handle("POST /reset", isLoggedIn.ThenFunc(app.resetPassword))
@@ -72,6 +85,11 @@ func (app *application) routes() http.Handler {
 
 	mux.Handle("POST /deleteAccount", isLoggedIn.ThenFunc(app.deleteAccount))
 
+	mux.Handle("GET /parent/login", isParent.ThenFunc(app.parentLoginPage))
+	mux.Handle("POST /parent/login", isParent.ThenFunc(app.parentLogin))
+	mux.Handle("GET /parent", isParent.ThenFunc(app.parentPage))
+	mux.Handle("POST /parent/logout", isParent.ThenFunc(app.parentLogout))
+
 	mux.Handle("GET /debug/vars", expvar.Handler())
 
 	standard := alice.New(app.metrics, app.recoverPanic, app.logRequest, app.secureHeaders)
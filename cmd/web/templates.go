@@ -6,6 +6,7 @@ import (
 	"html/template"
 	"net/http"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/alghurabi0/rehla/internal/models"
@@ -20,19 +21,40 @@ type templateData struct {
 	Lec               *models.Lec
 	Exam              *models.Exam
 	ExamURL           string
+	ExamAccess        *models.ExamAccess
+	ExamUnavailable   string
+	Requires          []models.Prerequisite
 	Answer            *models.Answer
 	Answers           *[]models.Answer
+	Appeal            *models.Appeal
+	CanAppeal         bool
+	Certificate       *models.Certificate
+	Completion        *models.Completion
+	Attempts          int
+	Children          *[]models.Child
 	FreeMaterials     *[]models.Material
 	HxRoute           string
+	Inquiry           *models.Contact
+	Inquiries         *[]models.Contact
 	IsLoggedIn        bool
 	IsSubscribed      bool
+	Notifications     *[]models.Notification
+	Parent            *models.Parent
+	Performance       *models.Performance
+	Questions         *[]models.Question
 	TemplateTitle     string
 	User              *models.User
+	Watch             *models.WatchProgress
+	Watched           float64
 }
 
 var functions = template.FuncMap{
-	"subtract":  subtract,
-	"humanDate": humanDate,
+	"subtract":      subtract,
+	"humanDate":     humanDate,
+	"examTime":      examTime,
+	"inquiryStatus": inquiryStatus,
+	"trendPoints":   trendPoints,
+	"waitTime":      waitTime,
 }
 
 func newTemplateCache() (map[string]*template.Template, error) {
@@ -85,6 +107,22 @@ func newTemplateCache() (map[string]*template.Template, error) {
 	}
 	cache["login.tmpl.html"] = ts
 
+	for _, page := range []string{"parent_login.tmpl.html", "parent.tmpl.html", "certificate.tmpl.html"} {
+		ts, err = template.New(page).Funcs(functions).ParseFiles("./ui/html/auth.tmpl.html")
+		if err != nil {
+			return nil, err
+		}
+		ts, err = ts.ParseFiles("./ui/html/partials/auth/verifyForm.tmpl.html")
+		if err != nil {
+			return nil, err
+		}
+		ts, err = ts.ParseFiles(filepath.Join("./ui/html", page))
+		if err != nil {
+			return nil, err
+		}
+		cache[page] = ts
+	}
+
 	return cache, nil
 }
 
@@ -140,3 +178,52 @@ func subtract(a, b int) int {
 func humanDate(t time.Time) string {
 	return t.Format("2006-01-02")
 }
+
+// examTime formats exam schedule times in the timezone they were set in.
+func examTime(t time.Time) string {
+	return t.In(models.ExamTimezone).Format("2006-01-02 15:04")
+}
+
+func inquiryStatus(status string) string {
+	switch status {
+	case models.InquiryInProgress:
+		return "قيد المعالجة"
+	case models.InquiryClosed:
+		return "مغلق"
+	default:
+		return "مفتوح"
+	}
+}
+
+// the trend chart is drawn in a viewBox of this size
+const (
+	chartWidth  = 300
+	chartHeight = 100
+)
+
+// trendPoints lays the grades out as svg polyline points, spread evenly
+// from left to right with 100% at the top.
+func trendPoints(trend []models.GradePoint) string {
+	points := make([]string, len(trend))
+	for i, p := range trend {
+		x := float64(chartWidth) / 2
+		if len(trend) > 1 {
+			x = float64(i) * chartWidth / float64(len(trend)-1)
+		}
+		y := chartHeight - p.Percent*chartHeight/100
+		points[i] = fmt.Sprintf("%.1f,%.1f", x, y)
+	}
+	return strings.Join(points, " ")
+}
+
+// waitTime says how long a wait was in minutes, hours or days.
+func waitTime(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%d دقيقة", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%d ساعة", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%d يوم", int(d.Hours()/24))
+	}
+}
@@ -1,6 +1,11 @@
 package main
 
-import "testing"
+import (
+	"testing"
+	"time"
+
+	"github.com/alghurabi0/rehla/internal/models"
+)
 
 func TestSubtract(t *testing.T) {
 	n1 := 5
@@ -12,3 +17,23 @@ func TestSubtract(t *testing.T) {
 		t.Errorf("want %d got %d", -3, res)
 	}
 }
+
+func TestTrendPoints(t *testing.T) {
+	res := trendPoints([]models.GradePoint{{Percent: 100}, {Percent: 50}, {Percent: 0}})
+	if res != "0.0,0.0 150.0,50.0 300.0,100.0" {
+		t.Errorf("got %q", res)
+	}
+	res = trendPoints([]models.GradePoint{{Percent: 75}})
+	if res != "150.0,25.0" {
+		t.Errorf("got %q", res)
+	}
+}
+
+func TestWaitTime(t *testing.T) {
+	if res := waitTime(30 * time.Hour); res != "30 ساعة" {
+		t.Errorf("got %q", res)
+	}
+	if res := waitTime(72 * time.Hour); res != "3 يوم" {
+		t.Errorf("got %q", res)
+	}
+}
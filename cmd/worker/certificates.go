@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image/color"
+	"strings"
+	"time"
+
+	"github.com/alghurabi0/rehla/internal/jobs"
+	"github.com/alghurabi0/rehla/internal/models"
+	"github.com/alghurabi0/rehla/internal/notifications"
+	"github.com/alghurabi0/rehla/internal/pdf"
+	"github.com/alghurabi0/rehla/internal/qr"
+)
+
+// issueCertificate checks that the student completed the course and issues
+// their certificate. Students who already have one are left alone, so the
+// web app can ask more than once.
+func (app *application) issueCertificate(ctx context.Context, job *jobs.Job) error {
+	var payload jobs.IssueCertificatePayload
+	err := job.Decode(&payload)
+	if err != nil {
+		return err
+	}
+	cert, err := app.certificate.GetForCourse(ctx, payload.UserId, payload.CourseId)
+	if err != nil || cert != nil {
+		return err
+	}
+
+	course, err := app.course.Get(ctx, payload.CourseId)
+	if err != nil {
+		return err
+	}
+	course.ID = payload.CourseId
+	lecs, err := app.lec.GetAll(ctx, course.ID)
+	if err != nil {
+		return err
+	}
+	exams, err := app.exam.GetAll(ctx, course.ID)
+	if err != nil {
+		return err
+	}
+	course.Lecs, course.Exams = *lecs, *exams
+	progress, err := app.watch.GetCourse(ctx, payload.UserId, course.ID)
+	if err != nil {
+		return err
+	}
+	lecIds := make([]string, len(course.Lecs))
+	for i, lec := range course.Lecs {
+		lecIds[i] = lec.ID
+	}
+	// buffered progress is newer than the flushed
+	buffered, err := app.watchBuf.GetCourse(ctx, payload.UserId, course.ID, lecIds)
+	if err != nil {
+		return err
+	}
+	answers, err := app.answer.GetAll(ctx, payload.UserId, course.ID)
+	if err != nil {
+		return err
+	}
+	completion := models.CheckCompletion(course, append(*progress, buffered...), *answers)
+	if !completion.Complete {
+		app.infoLog.Printf("user %s hasn't completed course %s yet\n", payload.UserId, course.ID)
+		return nil
+	}
+
+	user, err := app.user.Get(ctx, payload.UserId)
+	if err != nil {
+		return err
+	}
+	cert = &models.Certificate{
+		ID:          models.NewCertificateID(),
+		UserId:      payload.UserId,
+		CourseId:    course.ID,
+		StudentName: strings.TrimSpace(user.Firstname + " " + user.Lastname),
+		CourseTitle: course.Title,
+		Teacher:     course.Teacher,
+		Average:     completion.Average,
+		HasAverage:  completion.HasAverage,
+		IssuedAt:    time.Now(),
+	}
+	file, err := app.renderCertificate(cert)
+	if err != nil {
+		return err
+	}
+	cert.FilePath = fmt.Sprintf("certificates/%s.pdf", cert.ID)
+	_, _, err = app.storage.Upload(ctx, bytes.NewReader(file), "application/pdf", cert.FilePath)
+	if err != nil {
+		return err
+	}
+	err = app.certificate.Issue(ctx, cert)
+	if err != nil {
+		// an earlier attempt got there first
+		if errors.Is(err, models.ErrCertificateExists) {
+			err = nil
+		}
+		delErr := app.storage.DeleteFile(ctx, cert.FilePath)
+		if delErr != nil {
+			app.errorLog.Println(delErr)
+		}
+		return err
+	}
+
+	err = app.notifier.Notify(ctx, cert.UserId, notifications.CertificateIssued(course.ID, course.Title))
+	if err != nil {
+		app.errorLog.Printf("failed to notify user %s: %v\n", cert.UserId, err)
+	}
+	return nil
+}
+
+var (
+	certInk    = color.RGBA{0x20, 0x20, 0x20, 0xff}
+	certAccent = color.RGBA{0xa4, 0x90, 0xbb, 0xff}
+	certMuted  = color.RGBA{0x6b, 0x6b, 0x6b, 0xff}
+)
+
+// renderCertificate draws the certificate on a landscape A4 page, the QR
+// code links to its verification page.
+func (app *application) renderCertificate(cert *models.Certificate) ([]byte, error) {
+	verifyURL := fmt.Sprintf("%s/certificates/%s", app.baseURL, cert.ID)
+	code, err := qr.Encode(verifyURL)
+	if err != nil {
+		return nil, err
+	}
+	doc := pdf.NewDocument(app.certFont)
+	w, h := pdf.A4Long, pdf.A4Short
+	page := doc.AddPage(w, h)
+	page.StrokeRect(18, 18, w-36, h-36, 4, certAccent)
+	page.StrokeRect(28, 28, w-56, h-56, 1, certAccent)
+
+	// long names and titles are shrunk to fit between the borders
+	fit := func(text string, size float64) float64 {
+		return min(size, size*(w-160)/doc.TextWidth(text, size))
+	}
+	center := w / 2
+	page.Text(center, 480, 40, pdf.AlignCenter, certAccent, "شهادة إتمام")
+	page.Text(center, 425, 16, pdf.AlignCenter, certMuted, "تمنح منصة رحلة هذه الشهادة إلى")
+	page.Text(center, 370, fit(cert.StudentName, 32), pdf.AlignCenter, certInk, cert.StudentName)
+	page.Text(center, 325, 16, pdf.AlignCenter, certMuted, "لإتمام دورة")
+	page.Text(center, 280, fit(cert.CourseTitle, 26), pdf.AlignCenter, certInk, cert.CourseTitle)
+	details := fmt.Sprintf("المدرس: %s", cert.Teacher)
+	if cert.HasAverage {
+		details = fmt.Sprintf("%s  |  المعدل: %.1f%%", details, cert.Average)
+	}
+	page.Text(center, 235, fit(details, 14), pdf.AlignCenter, certMuted, details)
+	page.Text(center, 205, 14, pdf.AlignCenter, certMuted, "تاريخ الإصدار: "+cert.IssuedAt.Format("2006-01-02"))
+
+	page.QR(60, 60, 96, code, certInk)
+	page.Text(w-60, 100, 12, pdf.AlignRight, certInk, "رقم الشهادة: "+cert.DisplayID())
+	page.Text(w-60, 78, 10, pdf.AlignRight, certMuted, "للتحقق من الشهادة امسح الرمز أو زر")
+	page.Text(w-60, 60, 10, pdf.AlignRight, certMuted, verifyURL)
+	return doc.Bytes()
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	gcloud "cloud.google.com/go/storage"
+
+	"github.com/alghurabi0/rehla/internal/jobs"
+	"github.com/alghurabi0/rehla/internal/models"
+	"github.com/alghurabi0/rehla/internal/notifications"
+)
+
+// students get reminded this long before their last payment runs out
+const expiryNotice = 3 * 24 * time.Hour
+
+// warmCourseCache stores every course under course:{id} and the full list
+// under courses, the web app falls back to firestore on a miss.
+func (app *application) warmCourseCache(ctx context.Context, job *jobs.Job) error {
+	courses, err := app.course.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get courses: %v", err)
+	}
+	for _, course := range *courses {
+		foo, err := json.Marshal(&course)
+		if err != nil {
+			return err
+		}
+		err = app.redis.Set(ctx, fmt.Sprintf("course:%s", course.ID), foo, 0).Err()
+		if err != nil {
+			return fmt.Errorf("failed to add course %s to redis: %v", course.ID, err)
+		}
+	}
+	foo, err := json.Marshal(*courses)
+	if err != nil {
+		return err
+	}
+	return app.redis.Set(ctx, "courses", foo, 0).Err()
+}
+
+// checkExpiringSubs notifies the users whose latest payment for a course
+// ends within a day of the notice period from now. It runs once a day so
+// every payment is picked up once.
+func (app *application) checkExpiringSubs(ctx context.Context, job *jobs.Job) error {
+	now := time.Now()
+	from := now.Add(expiryNotice - 24*time.Hour)
+	to := now.Add(expiryNotice)
+	payments, err := app.payment.GetExpiring(ctx, from, to)
+	if err != nil {
+		return err
+	}
+	for _, payment := range *payments {
+		subPayments, err := app.payment.GetAll(ctx, payment.UserId, payment.SubId)
+		if err != nil {
+			return err
+		}
+		renewed := false
+		for _, p := range *subPayments {
+			if !p.ValidUntil.Before(to) {
+				renewed = true
+				break
+			}
+		}
+		if renewed {
+			continue
+		}
+		sub, err := app.sub.Get(ctx, payment.UserId, payment.SubId)
+		if err != nil {
+			return err
+		}
+		if !sub.Active {
+			continue
+		}
+		msg := notifications.SubscriptionExpiring(sub.ID, sub.CourseTitle, payment.ValidUntil)
+		err = app.notifier.Notify(ctx, payment.UserId, msg)
+		if err != nil {
+			app.errorLog.Printf("failed to notify user %s: %v\n", payment.UserId, err)
+		}
+	}
+	return nil
+}
+
+func (app *application) notifyUser(ctx context.Context, job *jobs.Job) error {
+	var payload jobs.NotifyUserPayload
+	err := job.Decode(&payload)
+	if err != nil {
+		return err
+	}
+	return app.notifier.Notify(ctx, payload.UserId, payload.Message)
+}
+
+func (app *application) notifyCourse(ctx context.Context, job *jobs.Job) error {
+	var payload jobs.NotifyCoursePayload
+	err := job.Decode(&payload)
+	if err != nil {
+		return err
+	}
+	users, err := app.user.GetSubscribed(ctx, payload.CourseId)
+	if err != nil {
+		return err
+	}
+	// fan out so a retry only repeats the users that failed
+	for _, user := range *users {
+		_, err := app.jobs.Enqueue(ctx, jobs.NotifyUser, jobs.NotifyUserPayload{
+			UserId:  user.ID,
+			Message: payload.Message,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (app *application) deleteFiles(ctx context.Context, job *jobs.Job) error {
+	var payload jobs.DeleteFilesPayload
+	err := job.Decode(&payload)
+	if err != nil {
+		return err
+	}
+	for _, path := range payload.Paths {
+		if path == "" {
+			continue
+		}
+		err := app.storage.DeleteFile(ctx, path)
+		// already gone on an earlier attempt
+		if errors.Is(err, gcloud.ErrObjectNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushWatchProgress writes the watch progress buffered in redis to
+// firestore.
+func (app *application) flushWatchProgress(ctx context.Context, job *jobs.Job) error {
+	n, err := app.watchBuf.Flush(ctx, func(progress []models.WatchProgress) error {
+		return app.watch.SaveAll(ctx, progress)
+	})
+	if n > 0 {
+		app.infoLog.Printf("flushed the watch progress of %d lectures\n", n)
+	}
+	return err
+}
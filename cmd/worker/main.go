@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	firebase "firebase.google.com/go"
+	"firebase.google.com/go/messaging"
+	"firebase.google.com/go/storage"
+	"github.com/alghurabi0/rehla/internal/fileStorage"
+	"github.com/alghurabi0/rehla/internal/jobs"
+	"github.com/alghurabi0/rehla/internal/models"
+	"github.com/alghurabi0/rehla/internal/notifications"
+	"github.com/alghurabi0/rehla/internal/pdf"
+	"github.com/alghurabi0/rehla/internal/watch"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/api/option"
+)
+
+type application struct {
+	errorLog    *log.Logger
+	infoLog     *log.Logger
+	course      *models.CourseModel
+	user        *models.UserModel
+	sub         *models.SubscriptionModel
+	payment     *models.PaymentModel
+	storage     *fileStorage.StorageModel
+	redis       *redis.Client
+	notifier    *notifications.Notifier
+	jobs        *jobs.Queue
+	watch       *models.WatchModel
+	watchBuf    *watch.Buffer
+	lec         *models.LecModel
+	exam        *models.ExamModel
+	material    *models.MaterialModel
+	answer      *models.AnswerModel
+	certificate *models.CertificateModel
+	certFont    *pdf.Font
+	// where the web app is served, certificates link to it
+	baseURL string
+}
+
+var version string
+
+func main() {
+	credFile := flag.String("cred-file", "./internal/rehla-74745-firebase-adminsdk-m9ksq-dc2a61849d.json", "Path to the credentials file")
+	dfBkt := flag.String("default-bucket", "rehla-74745.appspot.com", "Defualt google storage bucket")
+	concurrency := flag.Int("concurrency", 4, "Number of jobs to run at the same time")
+	certFont := flag.String("cert-font", "./ui/fonts/DejaVuSans.ttf", "TrueType font certificates are written in")
+	baseURL := flag.String("base-url", "https://www.rehla.live", "Address of the web app")
+	versionDisplay := flag.Bool("version", false, "display version and exit")
+	flag.Parse()
+
+	if *versionDisplay {
+		fmt.Printf("Version\t%s\n", version)
+		os.Exit(0)
+	}
+
+	// loggers
+	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
+	errorLog := log.New(os.Stderr, "Error\t", log.Ldate|log.Ltime|log.Lshortfile)
+
+	fontData, err := os.ReadFile(*certFont)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	font, err := pdf.ParseFont("DejaVuSans", fontData)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	ctx := context.Background()
+	db, strg, msgClient, err := initFirebase(ctx, *credFile, *dfBkt)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	defer db.Close()
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     "localhost:6379",
+		Password: "",
+		DB:       0,
+	})
+	_, err = rdb.Ping(ctx).Result()
+	if err != nil {
+		errorLog.Fatalf("failed to ping redis: %v\n", err)
+	}
+	infoLog.Println("redis connected")
+
+	app := &application{
+		errorLog: errorLog,
+		infoLog:  infoLog,
+		course:   &models.CourseModel{DB: db},
+		user:     &models.UserModel{DB: db},
+		sub:      &models.SubscriptionModel{DB: db},
+		payment:  &models.PaymentModel{DB: db},
+		storage:  &fileStorage.StorageModel{ST: strg},
+		redis:    rdb,
+		notifier: &notifications.Notifier{
+			Sender:  &notifications.FCMSender{Client: msgClient},
+			Users:   &models.UserModel{DB: db},
+			Devices: &models.DeviceModel{DB: db},
+			Inbox:   &models.NotificationModel{DB: db},
+		},
+		jobs:        &jobs.Queue{Redis: rdb},
+		watch:       &models.WatchModel{DB: db},
+		watchBuf:    &watch.Buffer{Redis: rdb},
+		lec:         &models.LecModel{DB: db},
+		exam:        &models.ExamModel{DB: db, ST: strg},
+		material:    &models.MaterialModel{DB: db, ST: strg},
+		answer:      &models.AnswerModel{DB: db, ST: strg},
+		certificate: &models.CertificateModel{DB: db},
+		certFont:    font,
+		baseURL:     strings.TrimSuffix(*baseURL, "/"),
+	}
+
+	worker := &jobs.Worker{
+		Queue:       app.jobs,
+		ErrorLog:    errorLog,
+		InfoLog:     infoLog,
+		Concurrency: *concurrency,
+	}
+	app.register(worker)
+
+	// warm the cache right away instead of waiting for the first period
+	_, err = worker.Queue.Enqueue(ctx, jobs.WarmCourseCache, nil)
+	if err != nil {
+		errorLog.Printf("failed to queue cache warm-up: %v\n", err)
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	infoLog.Printf("starting worker with %d goroutines", *concurrency)
+	worker.Run(ctx)
+	infoLog.Println("worker stopped")
+}
+
+func (app *application) register(w *jobs.Worker) {
+	w.Handle(jobs.WarmCourseCache, app.warmCourseCache)
+	w.Handle(jobs.CheckExpiringSubs, app.checkExpiringSubs)
+	w.Handle(jobs.NotifyUser, app.notifyUser)
+	w.Handle(jobs.NotifyCourse, app.notifyCourse)
+	w.Handle(jobs.DeleteFiles, app.deleteFiles)
+	w.Handle(jobs.FlushWatchProgress, app.flushWatchProgress)
+	w.Handle(jobs.IssueCertificate, app.issueCertificate)
+	w.Handle(jobs.PublishContent, app.publishContent)
+
+	w.Every(jobs.WarmCourseCache, time.Hour, nil)
+	w.Every(jobs.CheckExpiringSubs, 24*time.Hour, nil)
+	w.Every(jobs.FlushWatchProgress, 5*time.Minute, nil)
+}
+
+func initFirebase(ctx context.Context, credFile, dfBkt string) (*firestore.Client, *storage.Client, *messaging.Client, error) {
+	opt := option.WithCredentialsFile(credFile)
+	cfg := &firebase.Config{
+		StorageBucket: dfBkt,
+	}
+	app, err := firebase.NewApp(ctx, cfg, opt)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	firestoreClient, err := app.Firestore(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	storageClient, err := app.Storage(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	messagingClient, err := app.Messaging(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return firestoreClient, storageClient, messagingClient, nil
+}
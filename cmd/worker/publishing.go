@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/alghurabi0/rehla/internal/jobs"
+	"github.com/alghurabi0/rehla/internal/notifications"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// publishContent puts a lecture, exam or material that just went out in
+// the cache and tells the course's students about it. Content deleted or
+// rescheduled since the job was queued is left alone.
+func (app *application) publishContent(ctx context.Context, job *jobs.Job) error {
+	var payload jobs.PublishContentPayload
+	err := job.Decode(&payload)
+	if err != nil {
+		return err
+	}
+	course, err := app.course.Get(ctx, payload.CourseId)
+	if err != nil {
+		return err
+	}
+
+	var msg notifications.Message
+	switch payload.Kind {
+	case jobs.ContentLec:
+		lec, err := app.lec.Get(ctx, payload.CourseId, payload.ID)
+		if status.Code(err) == codes.NotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !lec.PublishAt.Equal(payload.PublishAt) {
+			return nil
+		}
+		err = app.cacheLecs(ctx, payload.CourseId)
+		if err != nil {
+			return err
+		}
+		msg = notifications.NewLecture(payload.CourseId, payload.ID, course.Title, lec.Title)
+	case jobs.ContentExam:
+		exam, err := app.exam.Get(ctx, payload.CourseId, payload.ID)
+		if status.Code(err) == codes.NotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !exam.PublishAt.Equal(payload.PublishAt) {
+			return nil
+		}
+		err = app.cacheExams(ctx, payload.CourseId)
+		if err != nil {
+			return err
+		}
+		msg = notifications.NewExam(payload.CourseId, payload.ID, course.Title, exam.Title)
+	case jobs.ContentMaterial:
+		mat, err := app.material.Get(ctx, payload.CourseId, payload.ID)
+		if status.Code(err) == codes.NotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !mat.PublishAt.Equal(payload.PublishAt) {
+			return nil
+		}
+		err = app.cacheMaterials(ctx, payload.CourseId)
+		if err != nil {
+			return err
+		}
+		msg = notifications.NewMaterial(payload.CourseId, course.Title, mat.Title)
+	default:
+		return fmt.Errorf("unknown content kind %q", payload.Kind)
+	}
+
+	_, err = app.jobs.Enqueue(ctx, jobs.NotifyCourse, jobs.NotifyCoursePayload{
+		CourseId: payload.CourseId,
+		Message:  msg,
+	})
+	return err
+}
+
+// cacheLecs stores the course's lectures the way the dashboard's cache page
+// does, each under course:{id}:lec:{lecId} and all of them under
+// course:{id}:lecs.
+func (app *application) cacheLecs(ctx context.Context, courseId string) error {
+	lecs, err := app.lec.GetAll(ctx, courseId)
+	if err != nil {
+		return err
+	}
+	for _, lec := range *lecs {
+		foo, err := json.Marshal(lec)
+		if err != nil {
+			return err
+		}
+		err = app.redis.Set(ctx, fmt.Sprintf("course:%s:lec:%s", courseId, lec.ID), foo, 0).Err()
+		if err != nil {
+			return err
+		}
+	}
+	foo, err := json.Marshal(lecs)
+	if err != nil {
+		return err
+	}
+	return app.redis.Set(ctx, fmt.Sprintf("course:%s:lecs", courseId), foo, 0).Err()
+}
+
+func (app *application) cacheExams(ctx context.Context, courseId string) error {
+	exams, err := app.exam.GetAll(ctx, courseId)
+	if err != nil {
+		return err
+	}
+	for _, exam := range *exams {
+		foo, err := json.Marshal(exam)
+		if err != nil {
+			return err
+		}
+		err = app.redis.Set(ctx, fmt.Sprintf("course:%s:exam:%s", courseId, exam.ID), foo, 0).Err()
+		if err != nil {
+			return err
+		}
+	}
+	foo, err := json.Marshal(exams)
+	if err != nil {
+		return err
+	}
+	return app.redis.Set(ctx, fmt.Sprintf("course:%s:exams", courseId), foo, 0).Err()
+}
+
+func (app *application) cacheMaterials(ctx context.Context, courseId string) error {
+	materials, err := app.material.GetAll(ctx, courseId)
+	if err != nil {
+		return err
+	}
+	foo, err := json.Marshal(materials)
+	if err != nil {
+		return err
+	}
+	return app.redis.Set(ctx, fmt.Sprintf("course:%s:mats", courseId), foo, 0).Err()
+}
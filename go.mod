@@ -13,6 +13,7 @@ require (
 	github.com/justinas/alice v1.2.0
 	github.com/redis/go-redis/v9 v9.6.1
 	google.golang.org/api v0.176.1
+	google.golang.org/grpc v1.63.2
 )
 
 require (
@@ -50,6 +51,5 @@ require (
 	google.golang.org/genproto v0.0.0-20240227224415-6ceb2ff114de // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20240311132316-a219d84964c2 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240415180920-8c6c420018be // indirect
-	google.golang.org/grpc v1.63.2 // indirect
 	google.golang.org/protobuf v1.33.0 // indirect
 )
@@ -35,6 +35,29 @@ func (u *DashboardUserModel) Get(ctx context.Context, userId string) (*Dashboard
 	return &user, nil
 }
 
+func (u *DashboardUserModel) GetAll(ctx context.Context) (*[]DashboardUser, error) {
+	iter := u.DB.Collection("dashboard_users").OrderBy("username", firestore.Asc).Documents(ctx)
+	var users []DashboardUser
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var user DashboardUser
+		if err := doc.DataTo(&user); err != nil {
+			return nil, err
+		}
+		user.ID = doc.Ref.ID
+		// never hand passwords to templates
+		user.Password = ""
+		users = append(users, user)
+	}
+	return &users, nil
+}
+
 func (u *DashboardUserModel) Create(ctx context.Context, username, role, pwd string) (string, error) {
 	userData := DashboardUser{
 		Username: username,
@@ -7,12 +7,10 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
-	"strings"
 	"time"
 
 	gcloud "cloud.google.com/go/storage"
 	"firebase.google.com/go/storage"
-	"google.golang.org/api/iterator"
 )
 
 type StorageModel struct {
@@ -20,6 +18,12 @@ type StorageModel struct {
 }
 
 func (s *StorageModel) UploadFile(ctx context.Context, file multipart.File, handler multipart.FileHeader, path string) (string, *gcloud.ObjectHandle, error) {
+	return s.Upload(ctx, file, handler.Header.Get("Content-Type"), path)
+}
+
+// Upload stores everything read from r at path, used for files the server
+// builds itself like merged answers.
+func (s *StorageModel) Upload(ctx context.Context, r io.Reader, contentType, path string) (string, *gcloud.ObjectHandle, error) {
 	bkt, err := s.ST.DefaultBucket()
 	if err != nil {
 		return "", nil, err
@@ -27,9 +31,10 @@ func (s *StorageModel) UploadFile(ctx context.Context, file multipart.File, hand
 	// Upload the file to Firebase Storage
 	object := bkt.Object(path)
 	wc := object.NewWriter(ctx)
+	wc.ContentType = contentType
 	defer wc.Close()
 	// Copy the uploaded file's content to Firebase storage
-	if _, err := io.Copy(wc, file); err != nil {
+	if _, err := io.Copy(wc, r); err != nil {
 		object.Delete(ctx)
 		return "", nil, err
 	}
@@ -59,36 +64,8 @@ func (s *StorageModel) DeleteFile(ctx context.Context, path string) error {
 
 	object := bkt.Object(path)
 	if err := object.Delete(ctx); err != nil {
-		return fmt.Errorf("failed to delete file: %v", err)
+		return fmt.Errorf("failed to delete file: %w", err)
 	}
 
 	return nil
 }
-
-func (s *StorageModel) GetAnswers(ctx context.Context, courseId, examId string) ([]string, error) {
-	bkt, err := s.ST.DefaultBucket()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get default bucket: %v", err)
-	}
-	path := fmt.Sprintf("courses/%s/exams/%s/answers/", courseId, examId)
-
-	query := &gcloud.Query{
-		Prefix: path,
-	}
-	objectsIter := bkt.Objects(ctx, query)
-
-	var answers []string
-	for {
-		obj, err := objectsIter.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			return nil, fmt.Errorf("couldn't iterate over objects, error: %v", err)
-		}
-		objName := strings.TrimPrefix(obj.Name, path)
-		answers = append(answers, objName)
-	}
-
-	return answers, nil
-}
@@ -0,0 +1,59 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/alghurabi0/rehla/internal/notifications"
+)
+
+// jobs handled by cmd/worker
+const (
+	WarmCourseCache    = "warm_course_cache"
+	CheckExpiringSubs  = "check_expiring_subs"
+	NotifyUser         = "notify_user"
+	NotifyCourse       = "notify_course"
+	DeleteFiles        = "delete_files"
+	FlushWatchProgress = "flush_watch_progress"
+	IssueCertificate   = "issue_certificate"
+	PublishContent     = "publish_content"
+)
+
+type NotifyUserPayload struct {
+	UserId  string                `json:"user_id"`
+	Message notifications.Message `json:"message"`
+}
+
+// NotifyCoursePayload notifies every user subscribed to the course.
+type NotifyCoursePayload struct {
+	CourseId string                `json:"course_id"`
+	Message  notifications.Message `json:"message"`
+}
+
+// DeleteFilesPayload holds storage paths left behind by deleted documents.
+type DeleteFilesPayload struct {
+	Paths []string `json:"paths"`
+}
+
+// IssueCertificatePayload asks for the student's certificate once they
+// completed the course, the worker checks the rules again.
+type IssueCertificatePayload struct {
+	UserId   string `json:"user_id"`
+	CourseId string `json:"course_id"`
+}
+
+// kinds of course content a PublishContentPayload is about
+const (
+	ContentLec      = "lec"
+	ContentExam     = "exam"
+	ContentMaterial = "material"
+)
+
+// PublishContentPayload announces a lecture, exam or material when it goes
+// out. PublishAt is the time it was queued for, the worker skips it when
+// the content was rescheduled since.
+type PublishContentPayload struct {
+	CourseId  string    `json:"course_id"`
+	Kind      string    `json:"kind"`
+	ID        string    `json:"id"`
+	PublishAt time.Time `json:"publish_at"`
+}
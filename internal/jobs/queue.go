@@ -0,0 +1,366 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	StatusQueued  = "queued"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// redis keys, scheduled and running are sorted sets of job ids scored by
+// the time they are due and the time their lease runs out.
+const (
+	scheduledKey = "jobs:scheduled"
+	runningKey   = "jobs:running"
+	historyKey   = "jobs:history"
+	failedKey    = "jobs:failed"
+)
+
+const (
+	defaultMaxAttempts = 5
+	historySize        = 500
+	// finished jobs are kept this long for the dashboard
+	retention = 7 * 24 * time.Hour
+)
+
+var (
+	ErrNoJob     = errors.New("jobs: no job found")
+	ErrLeaseLost = errors.New("jobs: the job's lease ran out while it ran")
+)
+
+type Job struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	LastError   string          `json:"last_error"`
+	RunAt       time.Time       `json:"run_at"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+
+	// when the lease of the claim running the job runs out, its score in
+	// the running set
+	lease int64
+}
+
+// Decode unmarshals the job payload into v.
+func (j *Job) Decode(v any) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+type Queue struct {
+	Redis *redis.Client
+}
+
+// claimScript moves the first due job from the scheduled set to the running
+// set in one step, so only one worker can get it.
+var claimScript = redis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, 1)
+if #ids == 0 then
+	return false
+end
+redis.call('ZREM', KEYS[1], ids[1])
+redis.call('ZADD', KEYS[2], ARGV[2], ids[1])
+return ids[1]
+`)
+
+// releaseScript takes a job out of the running set if it's still held by
+// the lease it was claimed with. Once the lease runs out the job goes back
+// on the schedule and another worker may have claimed it since.
+var releaseScript = redis.NewScript(`
+local lease = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if lease and tonumber(lease) == tonumber(ARGV[2]) then
+	redis.call('ZREM', KEYS[1], ARGV[1])
+	return 1
+end
+return 0
+`)
+
+// completeScript is releaseScript for jobs that succeeded. A job whose lease
+// ran out is taken off the schedule, or out of the failed jobs, instead so
+// it doesn't run twice. Once it was claimed again it's left alone.
+var completeScript = redis.NewScript(`
+local lease = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if lease and tonumber(lease) == tonumber(ARGV[2]) then
+	redis.call('ZREM', KEYS[1], ARGV[1])
+	return 1
+end
+return redis.call('ZREM', KEYS[2], ARGV[1]) + redis.call('ZREM', KEYS[3], ARGV[1])
+`)
+
+func (q *Queue) Enqueue(ctx context.Context, name string, payload any) (string, error) {
+	return q.EnqueueAt(ctx, name, payload, time.Now())
+}
+
+func (q *Queue) EnqueueAt(ctx context.Context, name string, payload any, runAt time.Time) (string, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	job := &Job{
+		ID:          newID(),
+		Name:        name,
+		Payload:     raw,
+		Status:      StatusQueued,
+		MaxAttempts: defaultMaxAttempts,
+		RunAt:       runAt,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	err = q.save(ctx, job)
+	if err != nil {
+		return "", err
+	}
+	err = q.Redis.ZAdd(ctx, scheduledKey, redis.Z{Score: float64(runAt.Unix()), Member: job.ID}).Err()
+	if err != nil {
+		return "", err
+	}
+	return job.ID, nil
+}
+
+func (q *Queue) Get(ctx context.Context, id string) (*Job, error) {
+	foo, err := q.Redis.Get(ctx, jobKey(id)).Result()
+	if err == redis.Nil {
+		return nil, ErrNoJob
+	}
+	if err != nil {
+		return nil, err
+	}
+	var job Job
+	err = json.Unmarshal([]byte(foo), &job)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Recent returns the last updated jobs, newest first.
+func (q *Queue) Recent(ctx context.Context, limit int) (*[]Job, error) {
+	return q.list(ctx, historyKey, limit)
+}
+
+// Failed returns the jobs that ran out of attempts, newest first.
+func (q *Queue) Failed(ctx context.Context, limit int) (*[]Job, error) {
+	return q.list(ctx, failedKey, limit)
+}
+
+// Retry puts a failed job back on the schedule with a fresh set of attempts.
+func (q *Queue) Retry(ctx context.Context, id string) error {
+	job, err := q.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if job.Status != StatusFailed {
+		return fmt.Errorf("jobs: job %s is %s, not failed", id, job.Status)
+	}
+	now := time.Now()
+	job.Status = StatusQueued
+	job.Attempts = 0
+	job.RunAt = now
+	job.UpdatedAt = now
+	err = q.save(ctx, job)
+	if err != nil {
+		return err
+	}
+	pipe := q.Redis.TxPipeline()
+	pipe.ZRem(ctx, failedKey, id)
+	pipe.ZAdd(ctx, scheduledKey, redis.Z{Score: float64(now.Unix()), Member: id})
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// claim takes the next due job and leases it until now+lease. It returns
+// ErrNoJob when nothing is due.
+func (q *Queue) claim(ctx context.Context, now time.Time, lease time.Duration) (*Job, error) {
+	until := now.Add(lease).Unix()
+	res, err := claimScript.Run(ctx, q.Redis, []string{scheduledKey, runningKey},
+		now.Unix(), until).Result()
+	if err == redis.Nil {
+		return nil, ErrNoJob
+	}
+	if err != nil {
+		return nil, err
+	}
+	id, ok := res.(string)
+	if !ok {
+		return nil, fmt.Errorf("jobs: unexpected claim result %v", res)
+	}
+	job, err := q.Get(ctx, id)
+	if err != nil {
+		// the job expired or was removed, drop the dangling id
+		q.Redis.ZRem(ctx, runningKey, id)
+		return nil, err
+	}
+	job.Status = StatusRunning
+	job.Attempts++
+	job.UpdatedAt = now
+	job.lease = until
+	err = q.save(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// complete marks the job done. It returns ErrLeaseLost, leaving the job
+// alone, when the job ran past its lease and another worker claimed it
+// again.
+func (q *Queue) complete(ctx context.Context, job *Job) error {
+	ok, err := completeScript.Run(ctx, q.Redis, []string{runningKey, scheduledKey, failedKey}, job.ID, job.lease).Bool()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLeaseLost
+	}
+	job.Status = StatusDone
+	job.LastError = ""
+	job.UpdatedAt = time.Now()
+	return q.save(ctx, job)
+}
+
+// fail schedules the job again after a backoff, or marks it failed once it
+// used all its attempts. It returns ErrLeaseLost when the job ran past its
+// lease, requeueExpired already counted the attempt then.
+func (q *Queue) fail(ctx context.Context, job *Job, jobErr error) error {
+	ok, err := releaseScript.Run(ctx, q.Redis, []string{runningKey}, job.ID, job.lease).Bool()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLeaseLost
+	}
+	return q.reschedule(ctx, job, jobErr)
+}
+
+// reschedule puts a job that's out of the running set back on the schedule,
+// or in the failed set once it used all its attempts.
+func (q *Queue) reschedule(ctx context.Context, job *Job, jobErr error) error {
+	now := time.Now()
+	job.LastError = jobErr.Error()
+	job.UpdatedAt = now
+	pipe := q.Redis.TxPipeline()
+	if job.Attempts >= job.MaxAttempts {
+		job.Status = StatusFailed
+		pipe.ZAdd(ctx, failedKey, redis.Z{Score: float64(now.Unix()), Member: job.ID})
+	} else {
+		job.Status = StatusQueued
+		job.RunAt = now.Add(Backoff(job.Attempts))
+		pipe.ZAdd(ctx, scheduledKey, redis.Z{Score: float64(job.RunAt.Unix()), Member: job.ID})
+	}
+	err := q.save(ctx, job)
+	if err != nil {
+		return err
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// requeueExpired returns jobs whose lease ran out, because their worker died
+// or hung, to the schedule. They count as a failed attempt.
+func (q *Queue) requeueExpired(ctx context.Context, now time.Time) error {
+	expired, err := q.Redis.ZRangeByScoreWithScores(ctx, runningKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.Unix(), 10),
+	}).Result()
+	if err != nil {
+		return err
+	}
+	for _, z := range expired {
+		id, ok := z.Member.(string)
+		if !ok {
+			continue
+		}
+		// only the instance that removes the id gets to requeue it
+		removed, err := releaseScript.Run(ctx, q.Redis, []string{runningKey}, id, int64(z.Score)).Bool()
+		if err != nil {
+			return err
+		}
+		if !removed {
+			continue
+		}
+		job, err := q.Get(ctx, id)
+		if err == ErrNoJob {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = q.reschedule(ctx, job, errors.New("lease expired"))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *Queue) save(ctx context.Context, job *Job) error {
+	raw, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	pipe := q.Redis.TxPipeline()
+	pipe.Set(ctx, jobKey(job.ID), raw, retention)
+	pipe.ZAdd(ctx, historyKey, redis.Z{Score: float64(job.UpdatedAt.UnixNano()), Member: job.ID})
+	pipe.ZRemRangeByRank(ctx, historyKey, 0, -historySize-1)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (q *Queue) list(ctx context.Context, key string, limit int) (*[]Job, error) {
+	ids, err := q.Redis.ZRevRange(ctx, key, 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	var jobs []Job
+	for _, id := range ids {
+		job, err := q.Get(ctx, id)
+		if err == ErrNoJob {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+	return &jobs, nil
+}
+
+// Backoff is how long to wait before the next attempt, doubling from 30
+// seconds up to an hour.
+func Backoff(attempt int) time.Duration {
+	d := 30 * time.Second
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if d >= time.Hour {
+			return time.Hour
+		}
+	}
+	return d
+}
+
+func jobKey(id string) string {
+	return fmt.Sprintf("job:%s", id)
+}
+
+func newID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,223 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alghurabi0/rehla/internal/assert"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		attempt int
+		want    time.Duration
+	}{
+		{"first retry", 1, 30 * time.Second},
+		{"doubles", 3, 2 * time.Minute},
+		{"capped", 10, time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, Backoff(tt.attempt), tt.want)
+		})
+	}
+}
+
+// testQueue returns a queue on database 15 of the redis at REDIS_TEST_ADDR,
+// which it empties. The tests that need one are skipped when it's unset.
+func testQueue(t *testing.T) *Queue {
+	t.Helper()
+	addr := os.Getenv("REDIS_TEST_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_TEST_ADDR is not set")
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: addr, DB: 15})
+	t.Cleanup(func() { rdb.Close() })
+	ctx := context.Background()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		t.Fatalf("no redis at %s: %v", addr, err)
+	}
+	if err := rdb.FlushDB(ctx).Err(); err != nil {
+		t.Fatal(err)
+	}
+	return &Queue{Redis: rdb}
+}
+
+func isMember(t *testing.T, q *Queue, key, id string) bool {
+	t.Helper()
+	err := q.Redis.ZScore(context.Background(), key, id).Err()
+	if err != nil && err != redis.Nil {
+		t.Fatal(err)
+	}
+	return err == nil
+}
+
+func TestClaim(t *testing.T) {
+	q := testQueue(t)
+	ctx := context.Background()
+	now := time.Now()
+	later, err := q.EnqueueAt(ctx, "later", nil, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	due, err := q.EnqueueAt(ctx, "due", nil, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := q.claim(ctx, now, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, job.ID, due)
+	assert.Equal(t, job.Status, StatusRunning)
+	assert.Equal(t, job.Attempts, 1)
+	assert.Equal(t, isMember(t, q, runningKey, due), true)
+	assert.Equal(t, isMember(t, q, scheduledKey, due), false)
+
+	// the other job isn't due yet
+	_, err = q.claim(ctx, now, time.Minute)
+	assert.Equal(t, err, ErrNoJob)
+	assert.Equal(t, isMember(t, q, scheduledKey, later), true)
+
+	err = q.complete(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, err := q.Get(ctx, due)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, saved.Status, StatusDone)
+	assert.Equal(t, isMember(t, q, runningKey, due), false)
+}
+
+func TestFail(t *testing.T) {
+	q := testQueue(t)
+	ctx := context.Background()
+	id, err := q.Enqueue(ctx, "flaky", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := q.claim(ctx, time.Now(), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = q.fail(ctx, job, errors.New("boom"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, err := q.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, saved.Status, StatusQueued)
+	assert.Equal(t, saved.LastError, "boom")
+	assert.Equal(t, isMember(t, q, scheduledKey, id), true)
+	assert.Equal(t, isMember(t, q, runningKey, id), false)
+
+	// the last attempt fails the job for good
+	job, err = q.claim(ctx, saved.RunAt, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.MaxAttempts = job.Attempts
+	err = q.fail(ctx, job, errors.New("boom"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, job.Status, StatusFailed)
+	assert.Equal(t, isMember(t, q, failedKey, id), true)
+	assert.Equal(t, isMember(t, q, scheduledKey, id), false)
+}
+
+func TestRequeueExpired(t *testing.T) {
+	q := testQueue(t)
+	ctx := context.Background()
+	now := time.Now()
+	id, err := q.EnqueueAt(ctx, "slow", nil, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = q.claim(ctx, now, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// still leased
+	err = q.requeueExpired(ctx, now.Add(30*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, isMember(t, q, runningKey, id), true)
+
+	err = q.requeueExpired(ctx, now.Add(2*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, err := q.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, saved.Status, StatusQueued)
+	assert.Equal(t, saved.LastError, "lease expired")
+	assert.Equal(t, isMember(t, q, runningKey, id), false)
+	assert.Equal(t, isMember(t, q, scheduledKey, id), true)
+}
+
+func TestOverrun(t *testing.T) {
+	q := testQueue(t)
+	ctx := context.Background()
+	now := time.Now()
+	id, err := q.EnqueueAt(ctx, "slow", nil, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := q.claim(ctx, now, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = q.requeueExpired(ctx, now.Add(2*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("finishes before the retry", func(t *testing.T) {
+		err := q.complete(ctx, first)
+		if err != nil {
+			t.Fatal(err)
+		}
+		saved, err := q.Get(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, saved.Status, StatusDone)
+		// the retry was called off
+		assert.Equal(t, isMember(t, q, scheduledKey, id), false)
+	})
+
+	t.Run("finishes during the retry", func(t *testing.T) {
+		err := q.Redis.ZAdd(ctx, scheduledKey, redis.Z{Score: float64(now.Unix()), Member: id}).Err()
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := q.claim(ctx, now.Add(3*time.Minute), time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, q.complete(ctx, first), ErrLeaseLost)
+		assert.Equal(t, q.fail(ctx, first, errors.New("late")), ErrLeaseLost)
+		// the retry keeps its lease
+		assert.Equal(t, isMember(t, q, runningKey, id), true)
+		err = q.complete(ctx, second)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
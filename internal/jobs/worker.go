@@ -0,0 +1,167 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Handler runs a single job, returning an error schedules a retry.
+type Handler func(ctx context.Context, job *Job) error
+
+// Schedule enqueues a job once per period. Periods are aligned to
+// time.Truncate, so a 24 hour schedule runs right after midnight UTC no
+// matter how many workers are up or when they started.
+type Schedule struct {
+	Name    string
+	Every   time.Duration
+	Payload any
+}
+
+type Worker struct {
+	Queue       *Queue
+	ErrorLog    *log.Logger
+	InfoLog     *log.Logger
+	Concurrency int
+	// how long a job may run before another worker is allowed to retry it
+	Lease time.Duration
+	// how often idle workers look for due jobs
+	PollInterval time.Duration
+
+	handlers  map[string]Handler
+	schedules []Schedule
+}
+
+func (w *Worker) Handle(name string, h Handler) {
+	if w.handlers == nil {
+		w.handlers = make(map[string]Handler)
+	}
+	w.handlers[name] = h
+}
+
+func (w *Worker) Every(name string, every time.Duration, payload any) {
+	w.schedules = append(w.schedules, Schedule{Name: name, Every: every, Payload: payload})
+}
+
+// Run processes jobs until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	if w.Concurrency < 1 {
+		w.Concurrency = 1
+	}
+	if w.Lease == 0 {
+		w.Lease = 5 * time.Minute
+	}
+	if w.PollInterval == 0 {
+		w.PollInterval = time.Second
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.runScheduler(ctx)
+	}()
+	for i := 0; i < w.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.runJobs(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (w *Worker) runScheduler(ctx context.Context) {
+	ticker := time.NewTicker(w.PollInterval)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		for _, s := range w.schedules {
+			err := w.enqueueDue(ctx, s, now)
+			if err != nil {
+				w.ErrorLog.Printf("failed to schedule %s: %v\n", s.Name, err)
+			}
+		}
+		err := w.Queue.requeueExpired(ctx, now)
+		if err != nil {
+			w.ErrorLog.Printf("failed to requeue expired jobs: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// enqueueDue enqueues s if no instance did it for the current period yet.
+func (w *Worker) enqueueDue(ctx context.Context, s Schedule, now time.Time) error {
+	period := now.Truncate(s.Every)
+	key := fmt.Sprintf("jobs:cron:%s:%d", s.Name, period.Unix())
+	ok, err := w.Queue.Redis.SetNX(ctx, key, now.Unix(), 2*s.Every).Result()
+	if err != nil || !ok {
+		return err
+	}
+	_, err = w.Queue.Enqueue(ctx, s.Name, s.Payload)
+	return err
+}
+
+func (w *Worker) runJobs(ctx context.Context) {
+	for {
+		job, err := w.Queue.claim(ctx, time.Now(), w.Lease)
+		if err != nil {
+			if err != ErrNoJob {
+				w.ErrorLog.Printf("failed to claim job: %v\n", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(w.PollInterval):
+			}
+			continue
+		}
+		w.process(ctx, job)
+	}
+}
+
+func (w *Worker) process(ctx context.Context, job *Job) {
+	err := w.run(ctx, job)
+	if err != nil {
+		w.ErrorLog.Printf("job %s (%s) attempt %d failed: %v\n", job.ID, job.Name, job.Attempts, err)
+		err = w.Queue.fail(context.Background(), job, err)
+		if err == ErrLeaseLost {
+			w.ErrorLog.Printf("job %s ran past its lease, it was already retried\n", job.ID)
+			return
+		}
+		if err != nil {
+			w.ErrorLog.Printf("failed to record failure of job %s: %v\n", job.ID, err)
+		}
+		return
+	}
+	err = w.Queue.complete(context.Background(), job)
+	if err == ErrLeaseLost {
+		w.ErrorLog.Printf("job %s ran past its lease and is running again\n", job.ID)
+		return
+	}
+	if err != nil {
+		w.ErrorLog.Printf("failed to complete job %s: %v\n", job.ID, err)
+	}
+}
+
+// run calls the job's handler, a panic counts as a failed attempt.
+func (w *Worker) run(ctx context.Context, job *Job) (err error) {
+	h, ok := w.handlers[job.Name]
+	if !ok {
+		return fmt.Errorf("no handler for %s", job.Name)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(ctx, w.Lease)
+	defer cancel()
+	return h(ctx, job)
+}
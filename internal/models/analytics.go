@@ -0,0 +1,159 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// MinCohortSize keeps percentiles from pointing at individual students in
+// small courses.
+const MinCohortSize = 5
+
+// GradePoint is a graded exam on the student's trend.
+type GradePoint struct {
+	ExamTitle string
+	Date      time.Time
+	Percent   float64
+}
+
+// TopicScore is how the student does in the exams tagged with a topic.
+type TopicScore struct {
+	Topic   string
+	Exams   int
+	Average float64
+}
+
+// Performance sums up a student's grades in a course. Grades held back from
+// the student don't count.
+type Performance struct {
+	// graded exams, oldest submission first
+	Trend      []GradePoint
+	Average    float64
+	HasAverage bool
+	// the percent of the course the student's average is above
+	Percentile    float64
+	HasPercentile bool
+	CohortSize    int
+	Unsubmitted   []Exam
+	// how long correctors took on average, auto graded exams aside
+	CorrectionTime    time.Duration
+	HasCorrectionTime bool
+	Topics            []TopicScore
+}
+
+// visibleAnswers drops the answers whose grades students can't see yet.
+func visibleAnswers(answers []Answer) []Answer {
+	var visible []Answer
+	for _, ans := range answers {
+		if !ans.Withheld {
+			visible = append(visible, ans)
+		}
+	}
+	return visible
+}
+
+// CohortAverages returns the course averages of everyone who has one,
+// lowest first.
+func CohortAverages(exams []Exam, answers []Answer) []float64 {
+	g := BuildGradebook(exams, nil, visibleAnswers(answers))
+	var averages []float64
+	for _, row := range g.Rows {
+		if row.HasAverage {
+			averages = append(averages, row.Average)
+		}
+	}
+	sort.Float64s(averages)
+	return averages
+}
+
+// Percentile is the percent of the cohort below average, ties count half.
+func Percentile(cohort []float64, average float64) float64 {
+	if len(cohort) == 0 {
+		return 0
+	}
+	var below float64
+	for _, a := range cohort {
+		switch {
+		case a < average:
+			below++
+		case a == average:
+			below += 0.5
+		}
+	}
+	return below * 100 / float64(len(cohort))
+}
+
+// StudentPerformance works out the student's performance from their answers
+// to the course exams, cohort is CohortAverages of the course.
+func StudentPerformance(exams []Exam, answers []Answer, cohort []float64) Performance {
+	var p Performance
+	submitted := make(map[string]bool, len(answers))
+	for _, ans := range answers {
+		submitted[ans.ExamId] = true
+	}
+	answers = visibleAnswers(answers)
+	g := BuildGradebook(exams, nil, answers)
+	for _, exam := range g.Exams {
+		if !submitted[exam.ID] {
+			p.Unsubmitted = append(p.Unsubmitted, exam)
+		}
+	}
+	if len(g.Rows) == 0 {
+		return p
+	}
+	row := g.Rows[0]
+	p.Average, p.HasAverage = row.Average, row.HasAverage
+	if p.HasAverage && len(cohort) >= MinCohortSize {
+		p.Percentile = Percentile(cohort, p.Average)
+		p.HasPercentile = true
+		p.CohortSize = len(cohort)
+	}
+
+	byExam := make(map[string]Answer, len(answers))
+	for _, ans := range answers {
+		byExam[ans.ExamId] = ans
+	}
+	topics := map[string]*TopicScore{}
+	var topicOrder []string
+	for j, exam := range g.Exams {
+		cell := row.Cells[j]
+		if !cell.Graded() {
+			continue
+		}
+		p.Trend = append(p.Trend, GradePoint{
+			ExamTitle: exam.Title,
+			Date:      byExam[exam.ID].DateOfSubmission,
+			Percent:   cell.Percent(),
+		})
+		for _, tag := range exam.Tags {
+			t, ok := topics[tag]
+			if !ok {
+				t = &TopicScore{Topic: tag}
+				topics[tag] = t
+				topicOrder = append(topicOrder, tag)
+			}
+			// a running mean keeps it to one pass
+			t.Exams++
+			t.Average += (cell.Percent() - t.Average) / float64(t.Exams)
+		}
+	}
+	sort.SliceStable(p.Trend, func(i, j int) bool { return p.Trend[i].Date.Before(p.Trend[j].Date) })
+	for _, tag := range topicOrder {
+		p.Topics = append(p.Topics, *topics[tag])
+	}
+
+	var waited time.Duration
+	corrected := 0
+	for _, ans := range answers {
+		if !ans.Corrected || ans.Corrector == "auto" || ans.CorrectedAt.IsZero() {
+			continue
+		}
+		waited += ans.CorrectedAt.Sub(ans.DateOfSubmission)
+		corrected++
+	}
+	if corrected > 0 {
+		p.CorrectionTime = waited / time.Duration(corrected)
+		p.HasCorrectionTime = true
+	}
+	return p
+}
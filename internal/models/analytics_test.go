@@ -0,0 +1,54 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alghurabi0/rehla/internal/assert"
+)
+
+func TestPercentile(t *testing.T) {
+	cohort := []float64{40, 50, 60, 60, 90}
+	assert.Equal(t, Percentile(cohort, 60), 60.0)
+	assert.Equal(t, Percentile(cohort, 95), 100.0)
+	assert.Equal(t, Percentile(nil, 60), 0.0)
+}
+
+func TestStudentPerformance(t *testing.T) {
+	day := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	exams := []Exam{
+		{ID: "e1", Title: "One", Order: 1, MaxMarks: 10, Tags: []string{"algebra"}},
+		{ID: "e2", Title: "Two", Order: 2, MaxMarks: 10, Tags: []string{"algebra", "geometry"}},
+		{ID: "e3", Title: "Three", Order: 3, MaxMarks: 10},
+		{ID: "e4", Title: "Four", Order: 4, MaxMarks: 10},
+	}
+	answers := []Answer{
+		{UserId: "u", ExamId: "e2", Corrected: true, Grade: 8, Corrector: "amal", DateOfSubmission: day.AddDate(0, 0, 2), CorrectedAt: day.AddDate(0, 0, 3)},
+		{UserId: "u", ExamId: "e1", Corrected: true, Grade: 4, Corrector: "auto", DateOfSubmission: day, CorrectedAt: day},
+		// held back from the student, it doesn't count yet
+		{UserId: "u", ExamId: "e3", Corrected: true, Grade: 10, Withheld: true, DateOfSubmission: day},
+	}
+	p := StudentPerformance(exams, answers, []float64{20, 30, 40, 70, 80})
+
+	assert.Equal(t, len(p.Trend), 2)
+	assert.Equal(t, p.Trend[0].ExamTitle, "One")
+	assert.Equal(t, p.Average, 60.0)
+	assert.Equal(t, p.HasPercentile, true)
+	assert.Equal(t, p.Percentile, 60.0)
+	assert.Equal(t, len(p.Unsubmitted), 1)
+	assert.Equal(t, p.Unsubmitted[0].ID, "e4")
+	assert.Equal(t, p.CorrectionTime, 24*time.Hour)
+
+	assert.Equal(t, len(p.Topics), 2)
+	assert.Equal(t, p.Topics[0].Topic, "algebra")
+	assert.Equal(t, p.Topics[0].Average, 60.0)
+	assert.Equal(t, p.Topics[1].Exams, 1)
+
+	// too few students to rank against
+	p = StudentPerformance(exams, answers, []float64{20, 30})
+	assert.Equal(t, p.HasPercentile, false)
+
+	p = StudentPerformance(exams, nil, nil)
+	assert.Equal(t, len(p.Unsubmitted), 4)
+	assert.Equal(t, p.HasAverage, false)
+}
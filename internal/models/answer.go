@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"cloud.google.com/go/firestore"
@@ -26,6 +27,85 @@ type Answer struct {
 	URL              string    `firestore:"url"`
 	Corrected        bool      `firestore:"corrected"`
 	Corrector        string    `firestore:"corrector"`
+	// when the grade was given, appeals are counted from it
+	CorrectedAt time.Time `firestore:"corrected_at"`
+	// per question breakdown of online exams
+	Items []AnswerItem `firestore:"items"`
+	// rubric breakdown of upload exams
+	Scores   []CriterionScore `firestore:"scores"`
+	MaxGrade int              `firestore:"max_grade"`
+	// submitted after the deadline, penalty is the percent taken off the grade
+	Late    bool `firestore:"late"`
+	Penalty int  `firestore:"penalty"`
+	// the attempt number, on the exam answer it's the attempt that counts
+	Attempt int `firestore:"attempt"`
+	// how many attempts the student made, only set on the exam answer
+	Attempts int `firestore:"attempts"`
+	// feedback returned by the corrector, a marked up copy of the answer
+	// and a voice note
+	MarkedURL  string `firestore:"marked_url"`
+	MarkedPath string `firestore:"marked_path"`
+	AudioURL   string `firestore:"audio_url"`
+	AudioPath  string `firestore:"audio_path"`
+	// graded but kept from the student until the exam's grades are released
+	Withheld bool `firestore:"withheld"`
+	// double marked exams, the correctors' marks and the moderator who
+	// settled them when they disagreed
+	Marks           []Mark    `firestore:"marks"`
+	NeedsModeration bool      `firestore:"needs_moderation"`
+	ModeratedBy     string    `firestore:"moderated_by"`
+	ModeratedAt     time.Time `firestore:"moderated_at"`
+	ModerationNote  string    `firestore:"moderation_note"`
+	// other students' answers that share pages with this one, filled in
+	// for correctors
+	Similar []AnswerMatch `firestore:"-"`
+}
+
+// MarkedIsImage reports whether the marked up answer is a photo rather than
+// a pdf.
+func (a *Answer) MarkedIsImage() bool {
+	return strings.HasSuffix(a.MarkedPath, ".jpg") || strings.HasSuffix(a.MarkedPath, ".png")
+}
+
+type AnswerItem struct {
+	QuestionId string `firestore:"question_id"`
+	// the question text when the answer was submitted
+	Question string `firestore:"question"`
+	Response string `firestore:"response"`
+	Correct  bool   `firestore:"correct"`
+	Points   int    `firestore:"points"`
+	Awarded  int    `firestore:"awarded"`
+	// set when a corrector changed the awarded points
+	Overridden bool `firestore:"overridden"`
+}
+
+// CriterionScore is what a corrector gave for a rubric criterion, the title
+// is kept so later rubric changes don't change old grades.
+type CriterionScore struct {
+	Criterion string `firestore:"criterion"`
+	Points    int    `firestore:"points"`
+	Awarded   int    `firestore:"awarded"`
+}
+
+// RubricGrade returns the total of the rubric scores before any late
+// penalty.
+func (a *Answer) RubricGrade() int {
+	var total int
+	for _, score := range a.Scores {
+		total += score.Awarded
+	}
+	return total
+}
+
+// Awarded returns the points given for the criterion with the title, 0 if
+// it wasn't scored.
+func (a *Answer) Awarded(criterion string) int {
+	for _, score := range a.Scores {
+		if score.Criterion == criterion {
+			return score.Awarded
+		}
+	}
+	return 0
 }
 
 type AnswerModel struct {
@@ -68,6 +148,162 @@ func (s *AnswerModel) GetAll(ctx context.Context, userId, courseId string) (*[]A
 	return &answers, nil
 }
 
+// GetExamAnswers returns every student's answer to the exam.
+func (s *AnswerModel) GetExamAnswers(ctx context.Context, courseId, examId string) (*[]Answer, error) {
+	iter := s.DB.CollectionGroup("answers").Where("course_id", "==", courseId).Where("exam_id", "==", examId).Documents(ctx)
+	var answers []Answer
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var ans Answer
+		if err := doc.DataTo(&ans); err != nil {
+			return nil, err
+		}
+		ans.ID = doc.Ref.ID
+		answers = append(answers, ans)
+	}
+	return &answers, nil
+}
+
+// answer list filters
+const (
+	AnswersUncorrected = "uncorrected"
+	AnswersCorrected   = "corrected"
+)
+
+var AnswerStatuses = []string{AnswersUncorrected, AnswersCorrected}
+
+var ErrInvalidCursor = errors.New("models: invalid page cursor")
+
+// AnswerQuery picks a page of an exam's answers, oldest submission first
+// unless Newest is set. Status and Corrector are optional filters, After is
+// the cursor returned with the previous page. Skip leaves answers out, the
+// page is still filled up to Limit.
+type AnswerQuery struct {
+	CourseId  string
+	ExamId    string
+	Status    string
+	Corrector string
+	Newest    bool
+	After     string
+	Limit     int
+	Skip      func(Answer) bool
+}
+
+type AnswerPage struct {
+	Answers []Answer
+	// cursor of the next page, empty on the last one
+	Next string
+}
+
+// ListExamAnswers returns a page of the exam's answers. Filtering and
+// ordering by submission need a composite index on the answers collection
+// group over course_id, exam_id, corrected, corrector and
+// date_of_submission.
+func (s *AnswerModel) ListExamAnswers(ctx context.Context, q AnswerQuery) (*AnswerPage, error) {
+	query := s.DB.CollectionGroup("answers").Where("course_id", "==", q.CourseId).Where("exam_id", "==", q.ExamId)
+	switch q.Status {
+	case AnswersUncorrected:
+		query = query.Where("corrected", "==", false)
+	case AnswersCorrected:
+		query = query.Where("corrected", "==", true)
+	}
+	if q.Corrector != "" {
+		query = query.Where("corrector", "==", q.Corrector)
+	}
+	dir := firestore.Asc
+	if q.Newest {
+		dir = firestore.Desc
+	}
+	query = query.OrderBy("date_of_submission", dir).OrderBy(firestore.DocumentID, dir)
+	// the cursor is the student of the last answer, their answer doc is
+	// where the next page starts
+	if q.After != "" {
+		last, err := s.ref(q.After, q.CourseId, q.ExamId).Get(ctx)
+		if last != nil && !last.Exists() {
+			return nil, ErrInvalidCursor
+		}
+		if err != nil {
+			return nil, err
+		}
+		query = query.StartAfter(last)
+	}
+	var page AnswerPage
+	for {
+		docs, err := query.Limit(q.Limit + 1).Documents(ctx).GetAll()
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			var ans Answer
+			if err := doc.DataTo(&ans); err != nil {
+				return nil, err
+			}
+			ans.ID = doc.Ref.ID
+			if q.Skip != nil && q.Skip(ans) {
+				continue
+			}
+			if len(page.Answers) == q.Limit {
+				page.Next = page.Answers[len(page.Answers)-1].UserId
+				return &page, nil
+			}
+			page.Answers = append(page.Answers, ans)
+		}
+		if len(docs) <= q.Limit {
+			return &page, nil
+		}
+		// skipped answers left the page short, read on after them
+		query = query.StartAfter(docs[len(docs)-1])
+	}
+}
+
+// ReleaseGrades shows the exam's withheld grades to the students and returns
+// the released exam answers. It needs indexes on the answers and attempts
+// collection groups over course_id, exam_id and withheld.
+func (s *AnswerModel) ReleaseGrades(ctx context.Context, courseId, examId string) ([]Answer, error) {
+	var released []Answer
+	var jobs []*firestore.BulkWriterJob
+	bw := s.DB.BulkWriter(ctx)
+	for _, group := range []string{"answers", "attempts"} {
+		docs, err := s.DB.CollectionGroup(group).Where("course_id", "==", courseId).Where("exam_id", "==", examId).Where("withheld", "==", true).Documents(ctx).GetAll()
+		if err != nil {
+			bw.End()
+			return nil, err
+		}
+		for _, doc := range docs {
+			job, err := bw.Update(doc.Ref, []firestore.Update{{Path: "withheld", Value: false}})
+			if err != nil {
+				bw.End()
+				return nil, err
+			}
+			jobs = append(jobs, job)
+			if group != "answers" {
+				continue
+			}
+			var ans Answer
+			if err := doc.DataTo(&ans); err != nil {
+				bw.End()
+				return nil, err
+			}
+			ans.ID = doc.Ref.ID
+			ans.Withheld = false
+			released = append(released, ans)
+		}
+	}
+	bw.End()
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			return nil, err
+		}
+	}
+	return released, nil
+}
+
 func (s *AnswerModel) Create(ctx context.Context, answer *Answer) error {
 	_, err := s.DB.Collection("users").Doc(answer.UserId).Collection("subs").Doc(answer.CourseId).Collection("answers").Doc(answer.ExamId).Set(ctx, answer)
 	if err != nil {
@@ -0,0 +1,308 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+// appeal statuses, an appeal is open until it's upheld or the answer is
+// regraded
+const (
+	AppealOpen     = "open"
+	AppealUpheld   = "upheld"
+	AppealRegraded = "regraded"
+)
+
+var AppealStatuses = []string{AppealOpen, AppealUpheld, AppealRegraded}
+
+var (
+	ErrAppealClosed  = errors.New("models: the appeal window is closed")
+	ErrAppealExists  = errors.New("models: the answer was already appealed")
+	ErrAppealDecided = errors.New("models: the appeal was already decided")
+)
+
+// Appeal is a student asking for the grade of an attempt to be looked at
+// again. It reaches whoever graded the attempt, and admins.
+type Appeal struct {
+	ID        string `firestore:"-"`
+	UserId    string `firestore:"user_id"`
+	CourseId  string `firestore:"course_id"`
+	ExamId    string `firestore:"exam_id"`
+	ExamTitle string `firestore:"exam_title"`
+	Attempt   int    `firestore:"attempt"`
+	Reason    string `firestore:"reason"`
+	Status    string `firestore:"status"`
+	// the usernames of who graded the attempt
+	Correctors []string `firestore:"correctors"`
+	// grades after the late penalty, the new grade is the old one when the
+	// appeal is upheld
+	OldGrade  int       `firestore:"old_grade"`
+	NewGrade  int       `firestore:"new_grade"`
+	MaxGrade  int       `firestore:"max_grade"`
+	Response  string    `firestore:"response"`
+	DecidedBy string    `firestore:"decided_by"`
+	CreatedAt time.Time `firestore:"created_at"`
+	DecidedAt time.Time `firestore:"decided_at"`
+}
+
+// IsOpen reports whether the appeal still waits for a decision.
+func (a *Appeal) IsOpen() bool {
+	return a.Status == AppealOpen
+}
+
+// Correctors lists who graded the answer, each marker of a double marked
+// one. Auto graded answers have none.
+func (a *Answer) Correctors() []string {
+	if len(a.Marks) > 0 {
+		names := make([]string, 0, len(a.Marks))
+		for _, m := range a.Marks {
+			names = append(names, m.Corrector)
+		}
+		return names
+	}
+	if a.Corrector == "" || a.Corrector == "auto" {
+		return nil
+	}
+	return []string{a.Corrector}
+}
+
+// AppealDeadline is the last moment the student can appeal the grade of the
+// answer, zero when the exam takes no appeals or the grade isn't out. The
+// window starts when the answer was graded, or when held grades were
+// released.
+func (e *Exam) AppealDeadline(a *Answer) time.Time {
+	if e.AppealDays <= 0 || !a.Corrected || a.Withheld || a.CorrectedAt.IsZero() {
+		return time.Time{}
+	}
+	gradedAt := a.CorrectedAt
+	if e.GradesReleasedAt.After(gradedAt) {
+		gradedAt = e.GradesReleasedAt
+	}
+	return gradedAt.AddDate(0, 0, e.AppealDays)
+}
+
+// CanAppeal reports whether the grade of the answer can still be appealed.
+func (e *Exam) CanAppeal(a *Answer, now time.Time) bool {
+	deadline := e.AppealDeadline(a)
+	return !deadline.IsZero() && now.Before(deadline)
+}
+
+// AppealRate is how often a corrector's grades were appealed and how the
+// appeals went.
+type AppealRate struct {
+	Corrector string
+	Corrected int
+	Appealed  int
+	Upheld    int
+	Regraded  int
+	Open      int
+}
+
+// Rate is the percent of the corrector's answers that were appealed.
+func (r AppealRate) Rate() float64 {
+	if r.Corrected == 0 {
+		return 0
+	}
+	return float64(r.Appealed) * 100 / float64(r.Corrected)
+}
+
+// AppealRates counts the appeals against each corrector next to how many
+// answers they corrected, the most appealed first. Appeals of double marked
+// answers count against both markers.
+func AppealRates(appeals []Appeal, corrected map[string]int) []AppealRate {
+	byCorrector := map[string]*AppealRate{}
+	rate := func(corrector string) *AppealRate {
+		r, ok := byCorrector[corrector]
+		if !ok {
+			r = &AppealRate{Corrector: corrector}
+			byCorrector[corrector] = r
+		}
+		return r
+	}
+	for corrector, n := range corrected {
+		rate(corrector).Corrected = n
+	}
+	for _, appeal := range appeals {
+		for _, corrector := range appeal.Correctors {
+			r := rate(corrector)
+			r.Appealed++
+			switch appeal.Status {
+			case AppealUpheld:
+				r.Upheld++
+			case AppealRegraded:
+				r.Regraded++
+			default:
+				r.Open++
+			}
+		}
+	}
+	rates := make([]AppealRate, 0, len(byCorrector))
+	for _, r := range byCorrector {
+		rates = append(rates, *r)
+	}
+	sort.Slice(rates, func(i, j int) bool {
+		if rates[i].Rate() != rates[j].Rate() {
+			return rates[i].Rate() > rates[j].Rate()
+		}
+		return rates[i].Corrector < rates[j].Corrector
+	})
+	return rates
+}
+
+type AppealModel struct {
+	DB *firestore.Client
+}
+
+// appealId keys appeals by attempt, so each attempt is appealed once.
+func appealId(courseId, examId, userId string, attempt int) string {
+	return fmt.Sprintf("%s_%s_%s_%d", courseId, examId, userId, attempt)
+}
+
+// Create files the appeal, ErrAppealExists when the attempt was appealed
+// before.
+func (s *AppealModel) Create(ctx context.Context, appeal *Appeal) error {
+	appeal.ID = appealId(appeal.CourseId, appeal.ExamId, appeal.UserId, appeal.Attempt)
+	appeal.Status = AppealOpen
+	appeal.CreatedAt = time.Now()
+	ref := s.DB.Collection("appeals").Doc(appeal.ID)
+	return s.DB.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if doc == nil {
+			return err
+		}
+		if doc.Exists() {
+			return ErrAppealExists
+		}
+		return tx.Create(ref, appeal)
+	})
+}
+
+func (s *AppealModel) Get(ctx context.Context, appealId string) (*Appeal, error) {
+	doc, err := s.DB.Collection("appeals").Doc(appealId).Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return toAppeal(doc)
+}
+
+// GetForAttempt returns the appeal of the attempt, nil when it wasn't
+// appealed.
+func (s *AppealModel) GetForAttempt(ctx context.Context, userId, courseId, examId string, attempt int) (*Appeal, error) {
+	doc, err := s.DB.Collection("appeals").Doc(appealId(courseId, examId, userId, attempt)).Get(ctx)
+	if doc != nil && !doc.Exists() {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toAppeal(doc)
+}
+
+// GetAll returns the appeals with the given status, or all of them when
+// status is empty, against corrector when it's set. Newest first.
+func (s *AppealModel) GetAll(ctx context.Context, status, corrector string) (*[]Appeal, error) {
+	query := s.DB.Collection("appeals").Query
+	if status != "" {
+		query = query.Where("status", "==", status)
+	}
+	if corrector != "" {
+		query = query.Where("correctors", "array-contains", corrector)
+	}
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	appeals := make([]Appeal, 0, len(docs))
+	for _, doc := range docs {
+		appeal, err := toAppeal(doc)
+		if err != nil {
+			return nil, err
+		}
+		appeals = append(appeals, *appeal)
+	}
+	sort.Slice(appeals, func(i, j int) bool {
+		return appeals[i].CreatedAt.After(appeals[j].CreatedAt)
+	})
+	return &appeals, nil
+}
+
+// Decide records the outcome of an open appeal, ErrAppealDecided when
+// someone decided it first. Regrades apply regrade to the appealed attempt,
+// counted by rule, in the same transaction so only whoever decides the
+// appeal changes the grade.
+func (s *AppealModel) Decide(ctx context.Context, appealId, status, rule string, regrade func(*Answer), response, decidedBy string, now time.Time) error {
+	ref := s.DB.Collection("appeals").Doc(appealId)
+	answers := &AnswerModel{DB: s.DB}
+	return s.DB.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		appeal, err := toAppeal(doc)
+		if err != nil {
+			return err
+		}
+		if !appeal.IsOpen() {
+			return ErrAppealDecided
+		}
+		newGrade := appeal.OldGrade
+		if status == AppealRegraded {
+			err = answers.updateAttempt(tx, appeal.UserId, appeal.CourseId, appeal.ExamId, appeal.Attempt, rule, func(a *Answer) {
+				regrade(a)
+				newGrade = a.Grade
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Update(ref, []firestore.Update{
+			{Path: "status", Value: status},
+			{Path: "new_grade", Value: newGrade},
+			{Path: "response", Value: response},
+			{Path: "decided_by", Value: decidedBy},
+			{Path: "decided_at", Value: now},
+		})
+	})
+}
+
+func toAppeal(doc *firestore.DocumentSnapshot) (*Appeal, error) {
+	var appeal Appeal
+	err := doc.DataTo(&appeal)
+	if err != nil {
+		return nil, err
+	}
+	appeal.ID = doc.Ref.ID
+	return &appeal, nil
+}
+
+// CorrectedCounts counts the corrected attempts of each corrector in every
+// course, answers from before attempts existed are included.
+func (s *AnswerModel) CorrectedCounts(ctx context.Context) (map[string]int, error) {
+	counts := map[string]int{}
+	for _, group := range []string{"attempts", "answers"} {
+		docs, err := s.DB.CollectionGroup(group).Where("corrected", "==", true).
+			Select("corrector", "attempts", "marks").Documents(ctx).GetAll()
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			var ans Answer
+			if err := doc.DataTo(&ans); err != nil {
+				return nil, err
+			}
+			// exam answers with attempts are copies of one
+			if group == "answers" && ans.Attempts > 0 {
+				continue
+			}
+			for _, corrector := range ans.Correctors() {
+				counts[corrector]++
+			}
+		}
+	}
+	return counts, nil
+}
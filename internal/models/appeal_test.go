@@ -0,0 +1,51 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alghurabi0/rehla/internal/assert"
+)
+
+func TestCanAppeal(t *testing.T) {
+	graded := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	a := &Answer{Corrected: true, CorrectedAt: graded}
+	e := &Exam{AppealDays: 3}
+	assert.Equal(t, e.AppealDeadline(a), graded.AddDate(0, 0, 3))
+	assert.Equal(t, e.CanAppeal(a, graded.Add(time.Hour)), true)
+	assert.Equal(t, e.CanAppeal(a, graded.AddDate(0, 0, 3)), false)
+
+	// the window opens when held grades are released
+	e.GradesReleasedAt = graded.AddDate(0, 0, 5)
+	assert.Equal(t, e.CanAppeal(a, graded.AddDate(0, 0, 6)), true)
+
+	a.Withheld = true
+	assert.Equal(t, e.CanAppeal(a, graded), false)
+	a.Withheld = false
+	assert.Equal(t, (&Exam{}).CanAppeal(a, graded), false)
+	assert.Equal(t, e.CanAppeal(&Answer{}, graded), false)
+}
+
+func TestAppealRates(t *testing.T) {
+	appeals := []Appeal{
+		{Correctors: []string{"amal"}, Status: AppealUpheld},
+		{Correctors: []string{"amal", "bashir"}, Status: AppealRegraded},
+		{Correctors: []string{"bashir"}, Status: AppealOpen},
+	}
+	rates := AppealRates(appeals, map[string]int{"amal": 20, "bashir": 4, "dana": 10})
+	assert.Equal(t, len(rates), 3)
+	assert.Equal(t, rates[0].Corrector, "bashir")
+	assert.Equal(t, rates[0].Rate(), 50.0)
+	assert.Equal(t, rates[0].Open, 1)
+	assert.Equal(t, rates[1].Corrector, "amal")
+	assert.Equal(t, rates[1].Upheld, 1)
+	assert.Equal(t, rates[1].Regraded, 1)
+	assert.Equal(t, rates[2].Rate(), 0.0)
+}
+
+func TestCorrectors(t *testing.T) {
+	assert.Equal(t, len((&Answer{Corrector: "auto"}).Correctors()), 0)
+	assert.Equal(t, (&Answer{Corrector: "amal"}).Correctors()[0], "amal")
+	a := &Answer{Corrector: "amal, bashir", Marks: []Mark{{Corrector: "amal"}, {Corrector: "bashir"}}}
+	assert.Equal(t, len(a.Correctors()), 2)
+}
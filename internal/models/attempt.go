@@ -0,0 +1,260 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+
+	"cloud.google.com/go/firestore"
+)
+
+// which attempt counts as the exam's grade
+const (
+	CountLatest = "latest"
+	CountBest   = "best"
+	CountFirst  = "first"
+)
+
+var CountingRules = []string{CountLatest, CountBest, CountFirst}
+
+var ErrNoAttempt = errors.New("models: no such attempt")
+
+// Every submission is kept as an attempt in answers/{examId}/attempts/{n}.
+// The answers/{examId} doc is a copy of the attempt that counts, so grades
+// pages and reports keep reading a single answer per exam. Answers submitted
+// before attempts existed have no attempts and count as the first one.
+
+// CountingAttempt picks the attempt that counts under rule. Best only looks
+// at corrected attempts and falls back to the latest while none is.
+func CountingAttempt(attempts []Answer, rule string) Answer {
+	sorted := make([]Answer, len(attempts))
+	copy(sorted, attempts)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Attempt < sorted[j].Attempt })
+	switch rule {
+	case CountFirst:
+		return sorted[0]
+	case CountBest:
+		best := -1
+		for i, attempt := range sorted {
+			if attempt.Corrected && (best == -1 || attempt.Grade >= sorted[best].Grade) {
+				best = i
+			}
+		}
+		if best != -1 {
+			return sorted[best]
+		}
+	}
+	return sorted[len(sorted)-1]
+}
+
+// summarize builds the exam answer out of the attempts, it's only corrected
+// once every attempt is.
+func summarize(attempts []Answer, rule string) Answer {
+	summary := CountingAttempt(attempts, rule)
+	summary.Attempts = len(attempts)
+	for _, attempt := range attempts {
+		if !attempt.Corrected {
+			summary.Corrected = false
+		}
+		if attempt.NeedsModeration {
+			summary.NeedsModeration = true
+		}
+	}
+	return summary
+}
+
+// awaitCorrector reports whether any attempt still needs a corrector.
+func awaitCorrector(attempts []Answer) bool {
+	for _, attempt := range attempts {
+		if attempt.AwaitsCorrector() {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *AnswerModel) ref(userId, courseId, examId string) *firestore.DocumentRef {
+	return s.DB.Collection("users").Doc(userId).Collection("subs").Doc(courseId).Collection("answers").Doc(examId)
+}
+
+// CountAttempts returns how many times the user answered the exam.
+func (s *AnswerModel) CountAttempts(ctx context.Context, userId, courseId, examId string) (int, error) {
+	doc, err := s.ref(userId, courseId, examId).Get(ctx)
+	if doc != nil && !doc.Exists() {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var ans Answer
+	err = doc.DataTo(&ans)
+	if err != nil {
+		return 0, err
+	}
+	return max(ans.Attempts, 1), nil
+}
+
+// GetAttempts returns the user's attempts at the exam, oldest first.
+func (s *AnswerModel) GetAttempts(ctx context.Context, userId, courseId, examId string) (*[]Answer, error) {
+	docs, err := s.ref(userId, courseId, examId).Collection("attempts").OrderBy("attempt", firestore.Asc).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	attempts, err := toAttempts(docs)
+	if err != nil {
+		return nil, err
+	}
+	if len(attempts) > 0 {
+		return &attempts, nil
+	}
+	ans, err := s.Get(ctx, userId, courseId, examId)
+	if err != nil {
+		return nil, err
+	}
+	ans.Attempt = 1
+	return &[]Answer{*ans}, nil
+}
+
+// CreateAttempt stores answer as the user's next attempt and updates the
+// exam answer under rule, uncorrected answers join the correction queue.
+// It returns ErrNoAttemptsLeft once the user made maxAttempts attempts,
+// zero allows any number.
+func (s *AnswerModel) CreateAttempt(ctx context.Context, answer *Answer, rule string, maxAttempts int) error {
+	ref := s.ref(answer.UserId, answer.CourseId, answer.ExamId)
+	col := ref.Collection("attempts")
+	return s.DB.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if doc == nil {
+			return err
+		}
+		docs, err := tx.Documents(col).GetAll()
+		if err != nil {
+			return err
+		}
+		attempts, err := toAttempts(docs)
+		if err != nil {
+			return err
+		}
+		queued, err := tx.Get(queueRef(s.DB, answer.CourseId, answer.ExamId, answer.UserId))
+		if queued == nil {
+			return err
+		}
+		// counted here too, two submissions at once both pass the handler
+		made := len(attempts)
+		if made == 0 && doc.Exists() {
+			made = 1
+		}
+		if maxAttempts > 0 && made >= maxAttempts {
+			return ErrNoAttemptsLeft
+		}
+		if len(attempts) == 0 && doc.Exists() {
+			var first Answer
+			err = doc.DataTo(&first)
+			if err != nil {
+				return err
+			}
+			first.Attempt = 1
+			first.Attempts = 0
+			err = tx.Set(col.Doc("1"), first)
+			if err != nil {
+				return err
+			}
+			attempts = append(attempts, first)
+		}
+		answer.Attempt = len(attempts) + 1
+		answer.Attempts = 0
+		err = tx.Set(col.Doc(strconv.Itoa(answer.Attempt)), answer)
+		if err != nil {
+			return err
+		}
+		attempts = append(attempts, *answer)
+		// a student already waiting keeps their place and corrector
+		if !answer.Corrected && !queued.Exists() {
+			err = tx.Create(queued.Ref, newQueueItem(answer))
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Set(ref, summarize(attempts, rule))
+	})
+}
+
+// UpdateAttempt applies update to one attempt and recounts the exam answer,
+// it leaves the correction queue once no attempt needs a corrector. Answers
+// without attempts are updated in place.
+func (s *AnswerModel) UpdateAttempt(ctx context.Context, userId, courseId, examId string, attempt int, rule string, update func(*Answer)) error {
+	return s.DB.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		return s.updateAttempt(tx, userId, courseId, examId, attempt, rule, update)
+	})
+}
+
+// updateAttempt is UpdateAttempt inside a transaction, it reads before it
+// writes so the transaction can read more first.
+func (s *AnswerModel) updateAttempt(tx *firestore.Transaction, userId, courseId, examId string, attempt int, rule string, update func(*Answer)) error {
+	ref := s.ref(userId, courseId, examId)
+	col := ref.Collection("attempts")
+	docs, err := tx.Documents(col).GetAll()
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		var ans Answer
+		err = doc.DataTo(&ans)
+		if err != nil {
+			return err
+		}
+		update(&ans)
+		if !ans.AwaitsCorrector() {
+			err = tx.Delete(queueRef(s.DB, courseId, examId, userId))
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Set(ref, ans)
+	}
+	attempts, err := toAttempts(docs)
+	if err != nil {
+		return err
+	}
+	found := false
+	for i := range attempts {
+		if attempts[i].Attempt != attempt {
+			continue
+		}
+		update(&attempts[i])
+		err = tx.Set(col.Doc(attempts[i].ID), attempts[i])
+		if err != nil {
+			return err
+		}
+		found = true
+	}
+	if !found {
+		return ErrNoAttempt
+	}
+	summary := summarize(attempts, rule)
+	if !awaitCorrector(attempts) {
+		err = tx.Delete(queueRef(s.DB, courseId, examId, userId))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Set(ref, summary)
+}
+
+func toAttempts(docs []*firestore.DocumentSnapshot) ([]Answer, error) {
+	var attempts []Answer
+	for _, doc := range docs {
+		var ans Answer
+		if err := doc.DataTo(&ans); err != nil {
+			return nil, err
+		}
+		ans.ID = doc.Ref.ID
+		attempts = append(attempts, ans)
+	}
+	return attempts, nil
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/alghurabi0/rehla/internal/assert"
+)
+
+func TestCountingAttempt(t *testing.T) {
+	attempts := []Answer{
+		{Attempt: 2, Grade: 9, Corrected: true},
+		{Attempt: 1, Grade: 6, Corrected: true},
+		{Attempt: 3, Grade: 0, Corrected: false},
+	}
+	assert.Equal(t, CountingAttempt(attempts, CountLatest).Attempt, 3)
+	assert.Equal(t, CountingAttempt(attempts, CountFirst).Attempt, 1)
+	assert.Equal(t, CountingAttempt(attempts, CountBest).Attempt, 2)
+	assert.Equal(t, CountingAttempt(attempts, "").Attempt, 3)
+
+	uncorrected := []Answer{{Attempt: 1}, {Attempt: 2}}
+	assert.Equal(t, CountingAttempt(uncorrected, CountBest).Attempt, 2)
+}
+
+func TestSummarize(t *testing.T) {
+	attempts := []Answer{
+		{Attempt: 1, Grade: 9, Corrected: true},
+		{Attempt: 2, Grade: 0, Corrected: false},
+	}
+	summary := summarize(attempts, CountBest)
+	assert.Equal(t, summary.Attempt, 1)
+	assert.Equal(t, summary.Grade, 9)
+	assert.Equal(t, summary.Attempts, 2)
+	assert.Equal(t, summary.Corrected, false)
+}
@@ -0,0 +1,164 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	gcloud "cloud.google.com/go/storage"
+	"firebase.google.com/go/storage"
+)
+
+// certificate ids leave out letters that read like digits, they're typed in
+// from paper
+const (
+	certificateAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	certificateIdLength = 12
+)
+
+var ErrCertificateExists = errors.New("models: the student already has a certificate for the course")
+
+// Certificate is issued once to a student who completed a course, anyone can
+// check it by its id.
+type Certificate struct {
+	ID          string  `firestore:"-"`
+	UserId      string  `firestore:"user_id"`
+	CourseId    string  `firestore:"course_id"`
+	StudentName string  `firestore:"student_name"`
+	CourseTitle string  `firestore:"course_title"`
+	Teacher     string  `firestore:"teacher"`
+	Average     float64 `firestore:"average"`
+	HasAverage  bool    `firestore:"has_average"`
+	FilePath    string  `firestore:"file_path"`
+	// signed url of the pdf, filled in when it's shown. Signed urls expire
+	// so only the path is kept.
+	URL      string    `firestore:"-"`
+	IssuedAt time.Time `firestore:"issued_at"`
+}
+
+// NewCertificateID returns a random certificate id.
+func NewCertificateID() string {
+	b := make([]byte, certificateIdLength)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+	for i := range b {
+		// 256 is a multiple of the alphabet's 32 letters so none is favoured
+		b[i] = certificateAlphabet[int(b[i])%len(certificateAlphabet)]
+	}
+	return string(b)
+}
+
+// NormalizeCertificateID reads an id the way it's printed, in groups of
+// four, or typed in lower case.
+func NormalizeCertificateID(id string) string {
+	id = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(id), "-", ""))
+	if len(id) != certificateIdLength {
+		return ""
+	}
+	for _, c := range id {
+		if !strings.ContainsRune(certificateAlphabet, c) {
+			return ""
+		}
+	}
+	return id
+}
+
+// DisplayID splits the id in groups of four.
+func (c *Certificate) DisplayID() string {
+	var groups []string
+	for i := 0; i < len(c.ID); i += 4 {
+		groups = append(groups, c.ID[i:min(i+4, len(c.ID))])
+	}
+	return strings.Join(groups, "-")
+}
+
+type CertificateModel struct {
+	DB *firestore.Client
+	ST *storage.Client
+}
+
+// the student's certificates are indexed by course, so each course issues
+// one per student
+func (s *CertificateModel) userRef(userId, courseId string) *firestore.DocumentRef {
+	return s.DB.Collection("users").Doc(userId).Collection("certificates").Doc(courseId)
+}
+
+// Issue stores the certificate, ErrCertificateExists when the student got
+// one for the course before.
+func (s *CertificateModel) Issue(ctx context.Context, cert *Certificate) error {
+	ref := s.DB.Collection("certificates").Doc(cert.ID)
+	userRef := s.userRef(cert.UserId, cert.CourseId)
+	return s.DB.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(userRef)
+		if doc == nil {
+			return err
+		}
+		if doc.Exists() {
+			return ErrCertificateExists
+		}
+		err = tx.Create(ref, cert)
+		if err != nil {
+			return err
+		}
+		return tx.Create(userRef, map[string]any{"certificate_id": cert.ID})
+	})
+}
+
+// Get returns the certificate, nil when there is no certificate with the id.
+func (s *CertificateModel) Get(ctx context.Context, id string) (*Certificate, error) {
+	doc, err := s.DB.Collection("certificates").Doc(id).Get(ctx)
+	if doc != nil && !doc.Exists() {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toCertificate(doc)
+}
+
+// GetForCourse returns the student's certificate for the course, nil when
+// they don't have one.
+func (s *CertificateModel) GetForCourse(ctx context.Context, userId, courseId string) (*Certificate, error) {
+	doc, err := s.userRef(userId, courseId).Get(ctx)
+	if doc != nil && !doc.Exists() {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	id, ok := doc.Data()["certificate_id"].(string)
+	if !ok {
+		return nil, nil
+	}
+	return s.Get(ctx, id)
+}
+
+// SignURL fills in the certificate's URL, signed for an hour.
+func (s *CertificateModel) SignURL(cert *Certificate) error {
+	bkt, err := s.ST.DefaultBucket()
+	if err != nil {
+		return err
+	}
+	opts := &gcloud.SignedURLOptions{
+		Expires: time.Now().Add(time.Hour),
+		Method:  http.MethodGet,
+	}
+	cert.URL, err = bkt.SignedURL(cert.FilePath, opts)
+	return err
+}
+
+func toCertificate(doc *firestore.DocumentSnapshot) (*Certificate, error) {
+	var cert Certificate
+	err := doc.DataTo(&cert)
+	if err != nil {
+		return nil, err
+	}
+	cert.ID = doc.Ref.ID
+	return &cert, nil
+}
@@ -0,0 +1,89 @@
+package models
+
+// Completion is how far a student is from meeting the course's completion
+// rules: every lecture watched and every exam graded with a high enough
+// average.
+type Completion struct {
+	Lecs int
+	// lectures watched at least MinWatch percent
+	WatchedLecs int
+	Exams       int
+	GradedExams int
+	// submitted exams waiting for a grade, or for it to be released
+	PendingExams int
+	Average      float64
+	HasAverage   bool
+	MinWatch     int
+	MinAverage   int
+	Complete     bool
+}
+
+// Percent is the share of the lectures and exams done, out of 100.
+func (c Completion) Percent() float64 {
+	total := c.Lecs + c.Exams
+	if total == 0 {
+		return 0
+	}
+	return float64(c.WatchedLecs+c.GradedExams) * 100 / float64(total)
+}
+
+// AverageMet reports whether the average is high enough, courses without
+// exams have nothing to average.
+func (c Completion) AverageMet() bool {
+	return c.Exams == 0 || (c.HasAverage && c.Average >= float64(c.MinAverage))
+}
+
+// minWatch is how much of a lecture has to be watched for it to count as
+// watched in the course.
+func (c *Course) minWatch() int {
+	if c.MinWatch <= 0 {
+		return finishedPercent
+	}
+	return c.MinWatch
+}
+
+// CheckCompletion checks the student's watch progress and answers against
+// the rules of the course, whose Lecs and Exams must be loaded. Grades held
+// back from the student don't count until they're released.
+func CheckCompletion(course *Course, progress []WatchProgress, answers []Answer) Completion {
+	c := Completion{
+		Lecs:       len(course.Lecs),
+		Exams:      len(course.Exams),
+		MinWatch:   course.minWatch(),
+		MinAverage: course.MinAverage,
+	}
+
+	// the course's lectures keep the percents the page already shows
+	lecs := append([]Lec(nil), course.Lecs...)
+	ApplyWatchProgress(lecs, progress)
+	for _, lec := range lecs {
+		if lec.Watched >= float64(c.MinWatch) {
+			c.WatchedLecs++
+		}
+	}
+
+	submitted := make(map[string]bool, len(answers))
+	for _, ans := range answers {
+		submitted[ans.ExamId] = true
+	}
+	g := BuildGradebook(course.Exams, nil, visibleAnswers(answers))
+	if len(g.Rows) > 0 {
+		row := g.Rows[0]
+		for _, cell := range row.Cells {
+			if cell.Graded() {
+				c.GradedExams++
+			}
+		}
+		c.Average, c.HasAverage = row.Average, row.HasAverage
+	}
+	for _, exam := range course.Exams {
+		if submitted[exam.ID] {
+			c.PendingExams++
+		}
+	}
+	c.PendingExams -= c.GradedExams
+
+	c.Complete = course.Certificates && c.Lecs+c.Exams > 0 &&
+		c.WatchedLecs == c.Lecs && c.GradedExams == c.Exams && c.AverageMet()
+	return c
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/alghurabi0/rehla/internal/assert"
+)
+
+func TestCheckCompletion(t *testing.T) {
+	course := &Course{
+		Certificates: true,
+		MinAverage:   60,
+		Lecs:         []Lec{{ID: "l1"}, {ID: "l2"}},
+		Exams: []Exam{
+			{ID: "quiz", Order: 1, MaxMarks: 10},
+			{ID: "final", Order: 2, MaxMarks: 20},
+		},
+	}
+	full := WatchProgress{LecId: "l1", Duration: 10, Watched: []byte{0xc0}}
+	half := WatchProgress{LecId: "l2", Duration: 20, Watched: []byte{0xc0}}
+	quiz := Answer{UserId: "a", ExamId: "quiz", Corrected: true, Grade: 8, MaxGrade: 10}
+	final := Answer{UserId: "a", ExamId: "final", Corrected: true, Grade: 10, MaxGrade: 20}
+
+	c := CheckCompletion(course, []WatchProgress{full, half}, []Answer{quiz, final})
+	assert.Equal(t, c.WatchedLecs, 1)
+	assert.Equal(t, c.GradedExams, 2)
+	assert.Equal(t, c.MinWatch, finishedPercent)
+	assert.Equal(t, c.Percent(), 75.0)
+	assert.Equal(t, c.Complete, false)
+	// the course's own lectures are left as they were
+	assert.Equal(t, course.Lecs[1].Watched, 0.0)
+
+	course.MinWatch = 50
+	c = CheckCompletion(course, []WatchProgress{full, half}, []Answer{quiz, final})
+	assert.Equal(t, c.Average, 65.0)
+	assert.Equal(t, c.Complete, true)
+
+	course.MinAverage = 66
+	c = CheckCompletion(course, []WatchProgress{full, half}, []Answer{quiz, final})
+	assert.Equal(t, c.AverageMet(), false)
+	assert.Equal(t, c.Complete, false)
+
+	// held back grades wait like ungraded ones
+	course.MinAverage = 0
+	final.Withheld = true
+	c = CheckCompletion(course, []WatchProgress{full, half}, []Answer{quiz, final})
+	assert.Equal(t, c.GradedExams, 1)
+	assert.Equal(t, c.PendingExams, 1)
+	assert.Equal(t, c.Complete, false)
+
+	course.Certificates = false
+	final.Withheld = false
+	c = CheckCompletion(course, []WatchProgress{full, half}, []Answer{quiz, final})
+	assert.Equal(t, c.Complete, false)
+
+	empty := CheckCompletion(&Course{Certificates: true}, nil, nil)
+	assert.Equal(t, empty.Complete, false)
+	assert.Equal(t, empty.Percent(), 0.0)
+}
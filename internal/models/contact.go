@@ -3,15 +3,44 @@ package models
 import (
 	"context"
 	"errors"
+	"sort"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/firestore"
-	//"google.golang.org/api/iterator"
+	"google.golang.org/api/iterator"
 )
 
+// inquiry statuses, inquiries saved before statuses existed read as open
+const (
+	InquiryOpen       = "open"
+	InquiryInProgress = "in_progress"
+	InquiryClosed     = "closed"
+)
+
+var InquiryStatuses = []string{InquiryOpen, InquiryInProgress, InquiryClosed}
+
+// Contact is a support inquiry sent from the contact page. Replies live in
+// contact/{id}/replies.
 type Contact struct {
-	Fullname     string `firestore:"full_name"`
-	Phone_number string `firestore:"phone_number"`
-	Message      string `firestre:"message"`
+	ID           string    `firestore:"-"`
+	UserId       string    `firestore:"user_id"`
+	Fullname     string    `firestore:"full_name"`
+	Phone_number string    `firestore:"phone_number"`
+	Message      string    `firestore:"message"`
+	Status       string    `firestore:"status"`
+	AssignedTo   string    `firestore:"assigned_to"`
+	CreatedAt    time.Time `firestore:"created_at"`
+	UpdatedAt    time.Time `firestore:"updated_at"`
+	Replies      []Reply   `firestore:"-"`
+}
+
+type Reply struct {
+	ID        string    `firestore:"-"`
+	Author    string    `firestore:"author"`
+	FromStaff bool      `firestore:"from_staff"`
+	Body      string    `firestore:"body"`
+	CreatedAt time.Time `firestore:"created_at"`
 }
 
 type ContactInfo struct {
@@ -26,8 +55,12 @@ type ContactModel struct {
 	DB *firestore.Client
 }
 
+// the contact info shown on the contact page shares the collection with the
+// inquiries
+const contactInfoDoc = "contactInfo"
+
 func (c *ContactModel) GetContactInfo(ctx context.Context) (*ContactInfo, error) {
-	contactDoc, err := c.DB.Collection("contact").Doc("contactInfo").Get(ctx)
+	contactDoc, err := c.DB.Collection("contact").Doc(contactInfoDoc).Get(ctx)
 	if err != nil {
 		return nil, errors.New("no contactInfo doc in contact collection")
 	}
@@ -39,16 +72,241 @@ func (c *ContactModel) GetContactInfo(ctx context.Context) (*ContactInfo, error)
 	return &info, nil
 }
 
-func (c *ContactModel) SendInquiry(ctx context.Context, fullname, phone_number, message string) error {
-	data := Contact{
-		Fullname:     fullname,
-		Phone_number: phone_number,
-		Message:      message,
-	}
+func (c *ContactModel) SendInquiry(ctx context.Context, inquiry *Contact) (string, error) {
+	now := time.Now()
+	inquiry.Status = InquiryOpen
+	inquiry.CreatedAt = now
+	inquiry.UpdatedAt = now
 	newDoc := c.DB.Collection("contact").NewDoc()
-	_, err := newDoc.Set(ctx, data)
+	_, err := newDoc.Set(ctx, inquiry)
+	if err != nil {
+		return "", err
+	}
+	return newDoc.ID, nil
+}
+
+func (c *ContactModel) GetInquiry(ctx context.Context, inquiryId string) (*Contact, error) {
+	if inquiryId == contactInfoDoc {
+		return &Contact{}, errors.New("not an inquiry")
+	}
+	doc, err := c.DB.Collection("contact").Doc(inquiryId).Get(ctx)
+	if err != nil {
+		return &Contact{}, err
+	}
+	inquiry, err := toInquiry(doc)
+	if err != nil {
+		return &Contact{}, err
+	}
+	replies, err := c.getReplies(ctx, inquiryId)
+	if err != nil {
+		return &Contact{}, err
+	}
+	inquiry.Replies = *replies
+	return inquiry, nil
+}
+
+// InquiryQuery picks a page of inquiries, newest first. Status is an optional
+// filter, Search keeps the inquiries whose name, phone number or message
+// contain it and After is the cursor returned with the previous page.
+type InquiryQuery struct {
+	Status string
+	Search string
+	After  string
+	Limit  int
+}
+
+type InquiryPage struct {
+	Inquiries []Contact
+	// cursor of the next page, empty on the last one
+	Next string
+}
+
+// GetInquiries returns a page of inquiries. Filtering by status and ordering
+// need a composite index on the contact collection over status and
+// created_at. Inquiries from before statuses existed are only listed once
+// SyncInquiries filled them in.
+func (c *ContactModel) GetInquiries(ctx context.Context, q InquiryQuery) (*InquiryPage, error) {
+	query := c.DB.Collection("contact").Query
+	if q.Status != "" {
+		query = query.Where("status", "==", q.Status)
+	}
+	query = query.OrderBy("created_at", firestore.Desc).OrderBy(firestore.DocumentID, firestore.Desc)
+	if q.After != "" {
+		last, err := c.DB.Collection("contact").Doc(q.After).Get(ctx)
+		if last != nil && !last.Exists() {
+			return nil, ErrInvalidCursor
+		}
+		if err != nil {
+			return nil, err
+		}
+		query = query.StartAfter(last)
+	}
+	search := strings.ToLower(strings.TrimSpace(q.Search))
+	var page InquiryPage
+	for {
+		docs, err := query.Limit(q.Limit + 1).Documents(ctx).GetAll()
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			if doc.Ref.ID == contactInfoDoc {
+				continue
+			}
+			inquiry, err := toInquiry(doc)
+			if err != nil {
+				return nil, err
+			}
+			if search != "" && !inquiry.matches(search) {
+				continue
+			}
+			if len(page.Inquiries) == q.Limit {
+				page.Next = page.Inquiries[len(page.Inquiries)-1].ID
+				return &page, nil
+			}
+			page.Inquiries = append(page.Inquiries, *inquiry)
+		}
+		if len(docs) <= q.Limit {
+			return &page, nil
+		}
+		// the search left the page short, read on after it
+		query = query.StartAfter(docs[len(docs)-1])
+	}
+}
+
+// SyncInquiries stores the status, creation time and message of inquiries
+// saved before they had them, so they can be filtered and ordered.
+func (c *ContactModel) SyncInquiries(ctx context.Context) error {
+	docs, err := c.DB.Collection("contact").Documents(ctx).GetAll()
 	if err != nil {
 		return err
 	}
+	bw := c.DB.BulkWriter(ctx)
+	defer bw.End()
+	for _, doc := range docs {
+		if doc.Ref.ID == contactInfoDoc {
+			continue
+		}
+		data := doc.Data()
+		_, hasStatus := data["status"]
+		_, hasCreatedAt := data["created_at"]
+		_, hasMessage := data["message"]
+		if hasStatus && hasCreatedAt && hasMessage {
+			continue
+		}
+		inquiry, err := toInquiry(doc)
+		if err != nil {
+			return err
+		}
+		_, err = bw.Update(doc.Ref, []firestore.Update{
+			{Path: "status", Value: inquiry.Status},
+			{Path: "created_at", Value: inquiry.CreatedAt},
+			{Path: "message", Value: inquiry.Message},
+		})
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+func (c *ContactModel) GetUserInquiries(ctx context.Context, userId string) (*[]Contact, error) {
+	iter := c.DB.Collection("contact").Where("user_id", "==", userId).Documents(ctx)
+	var inquiries []Contact
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		inquiry, err := toInquiry(doc)
+		if err != nil {
+			return nil, err
+		}
+		inquiries = append(inquiries, *inquiry)
+	}
+	sort.Slice(inquiries, func(i, j int) bool {
+		return inquiries[i].CreatedAt.After(inquiries[j].CreatedAt)
+	})
+	return &inquiries, nil
+}
+
+func (c *ContactModel) UpdateInquiry(ctx context.Context, inquiryId string, updates []firestore.Update) error {
+	updates = append(updates, firestore.Update{Path: "updated_at", Value: time.Now()})
+	_, err := c.DB.Collection("contact").Doc(inquiryId).Update(ctx, updates)
+	return err
+}
+
+// AddReply adds reply to the inquiry's thread and sets its status in the
+// same write.
+func (c *ContactModel) AddReply(ctx context.Context, inquiryId, status string, reply *Reply) (string, error) {
+	inquiryRef := c.DB.Collection("contact").Doc(inquiryId)
+	replyRef := inquiryRef.Collection("replies").NewDoc()
+	reply.CreatedAt = time.Now()
+	err := c.DB.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		err := tx.Create(replyRef, reply)
+		if err != nil {
+			return err
+		}
+		return tx.Update(inquiryRef, []firestore.Update{
+			{Path: "status", Value: status},
+			{Path: "updated_at", Value: reply.CreatedAt},
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+	return replyRef.ID, nil
+}
+
+func (c *ContactModel) getReplies(ctx context.Context, inquiryId string) (*[]Reply, error) {
+	iter := c.DB.Collection("contact").Doc(inquiryId).Collection("replies").OrderBy("created_at", firestore.Asc).Documents(ctx)
+	var replies []Reply
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var reply Reply
+		if err := doc.DataTo(&reply); err != nil {
+			return nil, err
+		}
+		reply.ID = doc.Ref.ID
+		replies = append(replies, reply)
+	}
+	return &replies, nil
+}
+
+func toInquiry(doc *firestore.DocumentSnapshot) (*Contact, error) {
+	var inquiry Contact
+	err := doc.DataTo(&inquiry)
+	if err != nil {
+		return nil, err
+	}
+	inquiry.ID = doc.Ref.ID
+	// inquiries from before the message tag was fixed kept it under the
+	// field's name
+	if inquiry.Message == "" {
+		inquiry.Message, _ = doc.Data()["Message"].(string)
+	}
+	if inquiry.Status == "" {
+		inquiry.Status = InquiryOpen
+	}
+	if inquiry.CreatedAt.IsZero() {
+		inquiry.CreatedAt = doc.CreateTime
+	}
+	return &inquiry, nil
+}
+
+func (c *Contact) matches(search string) bool {
+	for _, field := range []string{c.Fullname, c.Phone_number, c.Message} {
+		if strings.Contains(strings.ToLower(field), search) {
+			return true
+		}
+	}
+	return false
+}
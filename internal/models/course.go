@@ -30,13 +30,24 @@ type Course struct {
 	Lecs             []Lec        `firestore:"-"`
 	Exams            []Exam       `firestore:"-"`
 	Materials        []Material   `firestore:"-"`
+	Sections         []Section    `firestore:"-"`
 	UserSubscription Subscription `firestore:"-"`
 	UserPayments     []Payment    `firestore:"-"`
 	UserLastPayment  Payment      `firestore:"-"`
 	UserAmountPaid   int          `firestore:"-"`
+	UserBalance      int          `firestore:"-"`
+	UserAnswers      []Answer     `firestore:"-"`
 	// TODO - add to dashboard
 	Active bool `firestore:"active"`
 	Free   bool `firestore:"free"`
+	// completion rules, students who meet them get a certificate
+	Certificates bool `firestore:"certificates"`
+	// percent of every lecture to watch, 0 for the default
+	MinWatch int `firestore:"min_watch"`
+	// course average to reach, out of 100
+	MinAverage int `firestore:"min_average"`
+	// drip courses open lecture N on day N of the student's subscription
+	Drip bool `firestore:"drip"`
 }
 
 type CourseModel struct {
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+// Device is a browser or phone that registered an FCM token for a user. The
+// doc id is the device id the client keeps in local storage, so a refreshed
+// token replaces the old one instead of piling up.
+type Device struct {
+	ID        string    `firestore:"-"`
+	UserId    string    `firestore:"-"`
+	Token     string    `firestore:"token"`
+	UserAgent string    `firestore:"user_agent"`
+	UpdatedAt time.Time `firestore:"updated_at"`
+}
+
+type DeviceModel struct {
+	DB *firestore.Client
+}
+
+func (d *DeviceModel) GetAll(ctx context.Context, userId string) (*[]Device, error) {
+	devicesIter := d.DB.Collection("users").Doc(userId).Collection("devices").Documents(ctx)
+	var devices []Device
+	for {
+		doc, err := devicesIter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var device Device
+		if err := doc.DataTo(&device); err != nil {
+			return nil, err
+		}
+		device.ID = doc.Ref.ID
+		device.UserId = userId
+		devices = append(devices, device)
+	}
+	return &devices, nil
+}
+
+func (d *DeviceModel) Save(ctx context.Context, userId, deviceId string, device *Device) error {
+	_, err := d.DB.Collection("users").Doc(userId).Collection("devices").Doc(deviceId).Set(ctx, device)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d *DeviceModel) Delete(ctx context.Context, userId, deviceId string) error {
+	_, err := d.DB.Collection("users").Doc(userId).Collection("devices").Doc(deviceId).Delete(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
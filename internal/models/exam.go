@@ -2,6 +2,7 @@ package models
 
 import (
 	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"net/http"
@@ -13,13 +14,106 @@ import (
 	"google.golang.org/api/iterator"
 )
 
+// exam types, upload exams are a paper the students answer on and upload
+// for a corrector, online exams are answered in the app from the course
+// question bank and graded automatically.
+const (
+	ExamUpload = "upload"
+	ExamOnline = "online"
+)
+
 type Exam struct {
-	ID       string `firestore:"-"`
-	CourseId string `firestore:"-"`
-	Title    string `firestore:"title"`
-	Order    int    `firestore:"order"`
-	URL      string `firestore:"url"`
-	FilePath string `firestore:"file_path"`
+	ID          string   `firestore:"-"`
+	CourseId    string   `firestore:"-"`
+	Title       string   `firestore:"title"`
+	Order       int      `firestore:"order"`
+	SectionId   string   `firestore:"section_id"`
+	URL         string   `firestore:"url"`
+	FilePath    string   `firestore:"file_path"`
+	Type        string   `firestore:"type"`
+	QuestionIds []string `firestore:"question_ids"`
+	// when the exam goes out, zero for right away
+	PublishAt time.Time `firestore:"publish_at"`
+	// filled in for the course page while the exam isn't out
+	LockedUntil time.Time `firestore:"-"`
+	// what students have to do before the exam opens
+	Prerequisites []Prerequisite `firestore:"prerequisites"`
+	// the prerequisites the student hasn't met, filled in for the course page
+	Requires []Prerequisite `firestore:"-"`
+	// schedule, zero values mean no limit
+	OpensAt  time.Time `firestore:"opens_at"`
+	ClosesAt time.Time `firestore:"closes_at"`
+	// minutes a student has from opening the exam
+	TimeLimit      int    `firestore:"time_limit"`
+	LatePolicy     string `firestore:"late_policy"`
+	PenaltyPercent int    `firestore:"penalty_percent"`
+	// 0 allows any number of attempts
+	MaxAttempts  int    `firestore:"max_attempts"`
+	CountingRule string `firestore:"counting_rule"`
+	// grading of upload exams, 0 max marks means the rubric total
+	MaxMarks int         `firestore:"max_marks"`
+	Rubric   []Criterion `firestore:"rubric"`
+	// anonymous exams hide who the student is from correctors
+	Anonymous bool `firestore:"anonymous"`
+	// held grades reach students when an admin releases them
+	HoldGrades       bool      `firestore:"hold_grades"`
+	GradesReleasedAt time.Time `firestore:"grades_released_at"`
+	// upload answers graded by two correctors, marks further apart than the
+	// threshold go to a moderator
+	DoubleMarking bool `firestore:"double_marking"`
+	MarkThreshold int  `firestore:"mark_threshold"`
+	// days students have to appeal a grade once they see it, 0 takes no
+	// appeals
+	AppealDays int `firestore:"appeal_days"`
+	// how much the exam counts in the course average, 0 counts once
+	Weight int `firestore:"weight"`
+	// topics the exam covers, students see how they do per topic
+	Tags []string `firestore:"tags"`
+}
+
+// Criterion is a part of an upload exam the corrector scores on its own.
+type Criterion struct {
+	Title  string `firestore:"title"`
+	Points int    `firestore:"points"`
+}
+
+// what happens to submissions after the deadline
+const (
+	LatePolicyReject  = "reject"
+	LatePolicyFlag    = "flag"
+	LatePolicyPenalty = "penalty"
+)
+
+var LatePolicies = []string{LatePolicyReject, LatePolicyFlag, LatePolicyPenalty}
+
+// SubmissionGrace covers the time it takes to upload an answer that was
+// sent right before the deadline.
+const SubmissionGrace = 2 * time.Minute
+
+// ExamTimezone is used for exam schedules, Iraq has no daylight saving so a
+// fixed zone avoids depending on the tz database.
+var ExamTimezone = time.FixedZone("+03", 3*60*60)
+
+var (
+	ErrExamNotOpen    = errors.New("models: exam is not open yet")
+	ErrExamClosed     = errors.New("models: exam is closed")
+	ErrExamNotStarted = errors.New("models: exam was not started")
+	ErrNoAttemptsLeft = errors.New("models: no attempts left")
+	ErrAboveMaxMarks  = errors.New("models: grade is above the max marks")
+)
+
+// ExamAccess is where a student stands in an exam's schedule.
+type ExamAccess struct {
+	// zero when the exam has no deadline
+	Deadline time.Time
+	Late     bool
+	Penalty  int
+}
+
+// IsOnline reports whether the exam is answered in the app, exams created
+// before exam types existed are uploads.
+func (e *Exam) IsOnline() bool {
+	return e.Type == ExamOnline
 }
 
 type ExamModel struct {
@@ -27,6 +121,130 @@ type ExamModel struct {
 	ST *storage.Client
 }
 
+// CanAttempt reports whether a student who made attempts attempts can
+// submit another one.
+func (e *Exam) CanAttempt(attempts int) bool {
+	return e.MaxAttempts == 0 || attempts < e.MaxAttempts
+}
+
+// IsScheduled reports whether the exam has a window or a time limit.
+func (e *Exam) IsScheduled() bool {
+	return !e.OpensAt.IsZero() || !e.ClosesAt.IsZero() || e.TimeLimit > 0
+}
+
+// Deadline is when a student who started the exam at startedAt has to
+// submit, the earliest of the closing time and the end of their timer.
+func (e *Exam) Deadline(startedAt time.Time) time.Time {
+	deadline := e.ClosesAt
+	if e.TimeLimit > 0 && !startedAt.IsZero() {
+		timer := startedAt.Add(time.Duration(e.TimeLimit) * time.Minute)
+		if deadline.IsZero() || timer.Before(deadline) {
+			deadline = timer
+		}
+	}
+	return deadline
+}
+
+// Access checks whether a student who started the exam at startedAt, zero if
+// they didn't, can work on it at now and applies the late policy once the
+// deadline passed.
+func (e *Exam) Access(now, startedAt time.Time) (ExamAccess, error) {
+	if !Released(e.PublishAt, now) {
+		return ExamAccess{}, ErrNotReleased
+	}
+	if !e.OpensAt.IsZero() && now.Before(e.OpensAt) {
+		return ExamAccess{}, ErrExamNotOpen
+	}
+	// a closed exam reads as closed, even to students who never started it
+	access := ExamAccess{Deadline: e.Deadline(startedAt)}
+	if !access.Deadline.IsZero() && now.After(access.Deadline.Add(SubmissionGrace)) {
+		switch e.LatePolicy {
+		case LatePolicyFlag:
+			access.Late = true
+		case LatePolicyPenalty:
+			access.Late = true
+			access.Penalty = e.PenaltyPercent
+		default:
+			return access, ErrExamClosed
+		}
+	}
+	if e.TimeLimit > 0 && startedAt.IsZero() {
+		return ExamAccess{}, ErrExamNotStarted
+	}
+	return access, nil
+}
+
+// RubricTotal returns the points of all the rubric's criteria.
+func (e *Exam) RubricTotal() int {
+	var total int
+	for _, c := range e.Rubric {
+		total += c.Points
+	}
+	return total
+}
+
+// FullMarks is the grade of a perfect upload answer, 0 when the exam has
+// neither max marks nor a rubric.
+func (e *Exam) FullMarks() int {
+	if e.MaxMarks > 0 {
+		return e.MaxMarks
+	}
+	return e.RubricTotal()
+}
+
+// CheckGrade returns ErrAboveMaxMarks when grade is more than the full marks.
+func (e *Exam) CheckGrade(grade int) error {
+	if full := e.FullMarks(); full > 0 && grade > full {
+		return ErrAboveMaxMarks
+	}
+	return nil
+}
+
+// ScoreRubric checks the points awarded for each criterion, in rubric order,
+// and returns the scores with their total.
+func (e *Exam) ScoreRubric(awarded []int) ([]CriterionScore, int, error) {
+	if len(awarded) != len(e.Rubric) {
+		return nil, 0, fmt.Errorf("models: got %d scores for %d criteria", len(awarded), len(e.Rubric))
+	}
+	scores := make([]CriterionScore, 0, len(e.Rubric))
+	var total int
+	for i, c := range e.Rubric {
+		if awarded[i] < 0 || awarded[i] > c.Points {
+			return nil, 0, fmt.Errorf("models: %q is scored out of %d", c.Title, c.Points)
+		}
+		scores = append(scores, CriterionScore{Criterion: c.Title, Points: c.Points, Awarded: awarded[i]})
+		total += awarded[i]
+	}
+	if err := e.CheckGrade(total); err != nil {
+		return nil, 0, err
+	}
+	return scores, total, nil
+}
+
+// WithholdsGrades reports whether grades given now are kept from students
+// until they're released, grades given after the release show right away.
+func (e *Exam) WithholdsGrades() bool {
+	return e.HoldGrades && e.GradesReleasedAt.IsZero()
+}
+
+// Pseudonym is what correctors call a student in an anonymous exam, it
+// changes from one exam to the next.
+func Pseudonym(examId, userId string) string {
+	sum := sha256.Sum256([]byte(examId + "/" + userId))
+	return fmt.Sprintf("Student %X", sum[:3])
+}
+
+// ApplyPenalty takes penalty percent off the grade.
+func ApplyPenalty(grade, penalty int) int {
+	if penalty <= 0 {
+		return grade
+	}
+	if penalty >= 100 {
+		return 0
+	}
+	return grade * (100 - penalty) / 100
+}
+
 func (e *ExamModel) Get(ctx context.Context, courseId, examId string) (*Exam, error) {
 	examDoc, err := e.DB.Collection("courses").Doc(courseId).Collection("exams").Doc(examId).Get(ctx)
 	if err != nil {
@@ -111,3 +329,9 @@ func (e *ExamModel) Delete(ctx context.Context, courseId, examId string) error {
 	}
 	return nil
 }
+
+// Reorder sets the order of the course's exams to the order of ids,
+// which must list all of them.
+func (e *ExamModel) Reorder(ctx context.Context, courseId string, ids []string) error {
+	return reorder(ctx, e.DB, e.DB.Collection("courses").Doc(courseId).Collection("exams"), ids)
+}
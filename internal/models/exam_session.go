@@ -0,0 +1,69 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+// ExamSession records when a student opened an exam, their timer runs from
+// that moment.
+type ExamSession struct {
+	StartedAt time.Time `firestore:"started_at"`
+}
+
+type ExamSessionModel struct {
+	DB *firestore.Client
+}
+
+func (s *ExamSessionModel) ref(userId, courseId, examId string) *firestore.DocumentRef {
+	return s.DB.Collection("users").Doc(userId).Collection("subs").Doc(courseId).Collection("exam_sessions").Doc(examId)
+}
+
+// Get returns the student's session, a session with a zero StartedAt when
+// they never opened the exam.
+func (s *ExamSessionModel) Get(ctx context.Context, userId, courseId, examId string) (*ExamSession, error) {
+	doc, err := s.ref(userId, courseId, examId).Get(ctx)
+	if doc != nil && !doc.Exists() {
+		return &ExamSession{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var session ExamSession
+	err = doc.DataTo(&session)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Start starts the student's timer at now, opening the exam again keeps the
+// first start time.
+func (s *ExamSessionModel) Start(ctx context.Context, userId, courseId, examId string, now time.Time) (*ExamSession, error) {
+	ref := s.ref(userId, courseId, examId)
+	session := &ExamSession{}
+	err := s.DB.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if doc == nil {
+			return err
+		}
+		if doc.Exists() {
+			return doc.DataTo(session)
+		}
+		session.StartedAt = now
+		return tx.Set(ref, session)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Delete ends the session, the next time the student opens the exam their
+// timer starts over.
+func (s *ExamSessionModel) Delete(ctx context.Context, userId, courseId, examId string) error {
+	_, err := s.ref(userId, courseId, examId).Delete(ctx)
+	return err
+}
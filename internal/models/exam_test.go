@@ -0,0 +1,103 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alghurabi0/rehla/internal/assert"
+)
+
+func TestExamAccess(t *testing.T) {
+	opens := time.Date(2024, 5, 1, 9, 0, 0, 0, ExamTimezone)
+	closes := opens.Add(3 * time.Hour)
+
+	tests := []struct {
+		name      string
+		exam      Exam
+		now       time.Time
+		startedAt time.Time
+		wantErr   error
+		wantLate  bool
+		penalty   int
+	}{
+		{"unscheduled", Exam{}, opens, time.Time{}, nil, false, 0},
+		{"before opening", Exam{OpensAt: opens}, opens.Add(-time.Minute), time.Time{}, ErrExamNotOpen, false, 0},
+		{"inside window", Exam{OpensAt: opens, ClosesAt: closes}, opens.Add(time.Hour), time.Time{}, nil, false, 0},
+		{"within grace", Exam{ClosesAt: closes}, closes.Add(time.Minute), time.Time{}, nil, false, 0},
+		{"closed", Exam{ClosesAt: closes}, closes.Add(time.Hour), time.Time{}, ErrExamClosed, false, 0},
+		{"late flagged", Exam{ClosesAt: closes, LatePolicy: LatePolicyFlag}, closes.Add(time.Hour), time.Time{}, nil, true, 0},
+		{"late penalty", Exam{ClosesAt: closes, LatePolicy: LatePolicyPenalty, PenaltyPercent: 20}, closes.Add(time.Hour), time.Time{}, nil, true, 20},
+		{"timer not started", Exam{TimeLimit: 30}, opens, time.Time{}, ErrExamNotStarted, false, 0},
+		{"timer running", Exam{TimeLimit: 30}, opens.Add(20 * time.Minute), opens, nil, false, 0},
+		{"closed before starting", Exam{TimeLimit: 30, ClosesAt: closes}, closes.Add(time.Hour), time.Time{}, ErrExamClosed, false, 0},
+		{"late start", Exam{TimeLimit: 30, ClosesAt: closes, LatePolicy: LatePolicyFlag}, closes.Add(time.Hour), time.Time{}, ErrExamNotStarted, false, 0},
+		{"timer ran out", Exam{TimeLimit: 30, ClosesAt: closes}, opens.Add(40 * time.Minute), opens, ErrExamClosed, false, 0},
+		{"not published", Exam{PublishAt: opens}, opens.Add(-time.Minute), time.Time{}, ErrNotReleased, false, 0},
+		{"published", Exam{PublishAt: opens}, opens, time.Time{}, nil, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			access, err := tt.exam.Access(tt.now, tt.startedAt)
+			assert.Equal(t, err, tt.wantErr)
+			assert.Equal(t, access.Late, tt.wantLate)
+			assert.Equal(t, access.Penalty, tt.penalty)
+		})
+	}
+}
+
+func TestExamDeadline(t *testing.T) {
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, ExamTimezone)
+	exam := Exam{TimeLimit: 60, ClosesAt: start.Add(30 * time.Minute)}
+	assert.Equal(t, exam.Deadline(start), exam.ClosesAt)
+
+	exam.ClosesAt = start.Add(2 * time.Hour)
+	assert.Equal(t, exam.Deadline(start), start.Add(time.Hour))
+}
+
+func TestApplyPenalty(t *testing.T) {
+	assert.Equal(t, ApplyPenalty(80, 0), 80)
+	assert.Equal(t, ApplyPenalty(80, 25), 60)
+	assert.Equal(t, ApplyPenalty(7, 50), 3)
+	assert.Equal(t, ApplyPenalty(80, 150), 0)
+}
+
+func TestScoreRubric(t *testing.T) {
+	exam := Exam{Rubric: []Criterion{{"Method", 6}, {"Result", 4}}}
+	assert.Equal(t, exam.FullMarks(), 10)
+
+	scores, total, err := exam.ScoreRubric([]int{5, 4})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, total, 9)
+	assert.Equal(t, scores[0], CriterionScore{Criterion: "Method", Points: 6, Awarded: 5})
+
+	_, _, err = exam.ScoreRubric([]int{7, 0})
+	if err == nil {
+		t.Error("expected an error for points above the criterion")
+	}
+
+	// bonus criteria can't take the total over the max
+	exam.MaxMarks = 8
+	_, _, err = exam.ScoreRubric([]int{6, 4})
+	assert.Equal(t, err, ErrAboveMaxMarks)
+}
+
+func TestCheckGrade(t *testing.T) {
+	exam := Exam{}
+	assert.Equal(t, exam.CheckGrade(1000), nil)
+	exam.MaxMarks = 100
+	assert.Equal(t, exam.CheckGrade(100), nil)
+	assert.Equal(t, exam.CheckGrade(101), ErrAboveMaxMarks)
+}
+
+func TestWithholdsGrades(t *testing.T) {
+	assert.Equal(t, (&Exam{}).WithholdsGrades(), false)
+	assert.Equal(t, (&Exam{HoldGrades: true}).WithholdsGrades(), true)
+	assert.Equal(t, (&Exam{HoldGrades: true, GradesReleasedAt: time.Now()}).WithholdsGrades(), false)
+}
+
+func TestPseudonym(t *testing.T) {
+	assert.Equal(t, Pseudonym("e1", "u1"), Pseudonym("e1", "u1"))
+	if Pseudonym("e1", "u1") == Pseudonym("e2", "u1") {
+		t.Error("expected a different pseudonym in another exam")
+	}
+}
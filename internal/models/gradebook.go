@@ -0,0 +1,288 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/iterator"
+)
+
+// histogramBins splits grades into tenths of the full marks.
+const histogramBins = 10
+
+// GradebookCell is a student's grade in one exam.
+type GradebookCell struct {
+	Submitted bool
+	Corrected bool
+	Withheld  bool
+	Grade     int
+	MaxGrade  int
+}
+
+// Graded reports whether the cell has a grade out of known full marks,
+// answers from before max grades were kept may not.
+func (c GradebookCell) Graded() bool {
+	return c.Corrected && c.MaxGrade > 0
+}
+
+// Percent is the grade out of 100.
+func (c GradebookCell) Percent() float64 {
+	if !c.Graded() {
+		return 0
+	}
+	return float64(c.Grade) * 100 / float64(c.MaxGrade)
+}
+
+// GradebookRow is a student's grades in every exam of the course.
+type GradebookRow struct {
+	UserId string
+	Name   string
+	Phone  string
+	// one cell per exam, in the order of the gradebook's exams
+	Cells []GradebookCell
+	// the weighted average percent of the graded exams
+	Average    float64
+	HasAverage bool
+}
+
+// ExamStats sums up an exam's grades, means and medians are percents of
+// the full marks.
+type ExamStats struct {
+	Submitted int
+	Corrected int
+	// the students who didn't submit
+	Missing   []string
+	Rate      float64
+	Mean      float64
+	Median    float64
+	Histogram []int
+}
+
+// HistogramBar is one tenth of the grade range.
+type HistogramBar struct {
+	Label string
+	Count int
+	// relative to the tallest bar, out of 100
+	Height int
+}
+
+// Bars returns the histogram ready to be drawn.
+func (s ExamStats) Bars() []HistogramBar {
+	tallest := 0
+	for _, n := range s.Histogram {
+		tallest = max(tallest, n)
+	}
+	bars := make([]HistogramBar, len(s.Histogram))
+	for i, n := range s.Histogram {
+		bars[i] = HistogramBar{Label: fmt.Sprintf("%d-%d", i*10, i*10+10), Count: n}
+		if tallest > 0 {
+			bars[i].Height = n * 100 / tallest
+		}
+	}
+	return bars
+}
+
+// Gradebook is the student by exam matrix of a course.
+type Gradebook struct {
+	Exams []Exam
+	Stats []ExamStats
+	Rows  []GradebookRow
+}
+
+// GradeWeight is how much the exam counts in the course average, exams
+// without a weight count once.
+func (e *Exam) GradeWeight() int {
+	if e.Weight <= 0 {
+		return 1
+	}
+	return e.Weight
+}
+
+// BuildGradebook lays the answers out by student and exam, exams in their
+// course order and students by name. Students who answered but aren't
+// subscribed anymore keep their row. Averages only count graded exams,
+// missing submissions show in the exam stats instead.
+func BuildGradebook(exams []Exam, students []User, answers []Answer) Gradebook {
+	exams = append([]Exam(nil), exams...)
+	sort.SliceStable(exams, func(i, j int) bool { return exams[i].Order < exams[j].Order })
+	col := make(map[string]int, len(exams))
+	for i, exam := range exams {
+		col[exam.ID] = i
+	}
+
+	var rows []GradebookRow
+	rowOf := map[string]int{}
+	addRow := func(userId, name, phone string) int {
+		rows = append(rows, GradebookRow{UserId: userId, Name: name, Phone: phone, Cells: make([]GradebookCell, len(exams))})
+		rowOf[userId] = len(rows) - 1
+		return len(rows) - 1
+	}
+	for _, s := range students {
+		addRow(s.ID, strings.TrimSpace(s.Firstname+" "+s.Lastname), s.PhoneNumber)
+	}
+	for _, ans := range answers {
+		j, ok := col[ans.ExamId]
+		if !ok {
+			continue
+		}
+		i, ok := rowOf[ans.UserId]
+		if !ok {
+			i = addRow(ans.UserId, ans.UserId, "")
+		}
+		maxGrade := ans.MaxGrade
+		if maxGrade == 0 {
+			maxGrade = exams[j].FullMarks()
+		}
+		rows[i].Cells[j] = GradebookCell{
+			Submitted: true,
+			Corrected: ans.Corrected,
+			Withheld:  ans.Withheld,
+			Grade:     ans.Grade,
+			MaxGrade:  maxGrade,
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].Name < rows[j].Name })
+
+	for i := range rows {
+		var sum float64
+		weights := 0
+		for j, cell := range rows[i].Cells {
+			if !cell.Graded() {
+				continue
+			}
+			w := exams[j].GradeWeight()
+			sum += cell.Percent() * float64(w)
+			weights += w
+		}
+		if weights > 0 {
+			rows[i].Average = sum / float64(weights)
+			rows[i].HasAverage = true
+		}
+	}
+
+	stats := make([]ExamStats, len(exams))
+	for j := range exams {
+		stats[j] = examStats(rows, j)
+	}
+	return Gradebook{Exams: exams, Stats: stats, Rows: rows}
+}
+
+func examStats(rows []GradebookRow, col int) ExamStats {
+	stats := ExamStats{Histogram: make([]int, histogramBins)}
+	var percents []float64
+	for _, row := range rows {
+		cell := row.Cells[col]
+		if !cell.Submitted {
+			stats.Missing = append(stats.Missing, row.Name)
+			continue
+		}
+		stats.Submitted++
+		if !cell.Corrected {
+			continue
+		}
+		stats.Corrected++
+		if !cell.Graded() {
+			continue
+		}
+		p := cell.Percent()
+		percents = append(percents, p)
+		stats.Histogram[min(int(p)/10, histogramBins-1)]++
+	}
+	if len(rows) > 0 {
+		stats.Rate = float64(stats.Submitted) * 100 / float64(len(rows))
+	}
+	if len(percents) == 0 {
+		return stats
+	}
+	sort.Float64s(percents)
+	var sum float64
+	for _, p := range percents {
+		sum += p
+	}
+	stats.Mean = sum / float64(len(percents))
+	mid := len(percents) / 2
+	stats.Median = percents[mid]
+	if len(percents)%2 == 0 {
+		stats.Median = (percents[mid-1] + percents[mid]) / 2
+	}
+	return stats
+}
+
+// Table lays the gradebook out for export, a header row then one row per
+// student. Ungraded cells say why.
+func (g *Gradebook) Table() [][]any {
+	header := []any{"Student", "Phone"}
+	for _, exam := range g.Exams {
+		header = append(header, fmt.Sprintf("%s (/%d, x%d)", exam.Title, exam.FullMarks(), exam.GradeWeight()))
+	}
+	header = append(header, "Average %")
+	table := [][]any{header}
+	for _, row := range g.Rows {
+		line := []any{row.Name, row.Phone}
+		for _, cell := range row.Cells {
+			switch {
+			case !cell.Submitted:
+				line = append(line, "missing")
+			case !cell.Corrected:
+				line = append(line, "uncorrected")
+			default:
+				line = append(line, cell.Grade)
+			}
+		}
+		if row.HasAverage {
+			line = append(line, roundTenth(row.Average))
+		} else {
+			line = append(line, nil)
+		}
+		table = append(table, line)
+	}
+	return table
+}
+
+// CSVRecord formats a row of the table for csv. Text starting like a
+// formula gets a quote in front, names and phone numbers are typed by
+// students and spreadsheets would run them.
+func CSVRecord(row []any) []string {
+	record := make([]string, len(row))
+	for i, cell := range row {
+		switch cell := cell.(type) {
+		case nil:
+		case string:
+			if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+				cell = "'" + cell
+			}
+			record[i] = cell
+		default:
+			record[i] = fmt.Sprint(cell)
+		}
+	}
+	return record
+}
+
+func roundTenth(f float64) float64 {
+	return float64(int(f*10+0.5)) / 10
+}
+
+// GetCourseAnswers returns every student's exam answers in the course.
+func (s *AnswerModel) GetCourseAnswers(ctx context.Context, courseId string) (*[]Answer, error) {
+	iter := s.DB.CollectionGroup("answers").Where("course_id", "==", courseId).Documents(ctx)
+	var answers []Answer
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var ans Answer
+		if err := doc.DataTo(&ans); err != nil {
+			return nil, err
+		}
+		ans.ID = doc.Ref.ID
+		answers = append(answers, ans)
+	}
+	return &answers, nil
+}
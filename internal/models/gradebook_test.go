@@ -0,0 +1,75 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/alghurabi0/rehla/internal/assert"
+)
+
+func TestBuildGradebook(t *testing.T) {
+	exams := []Exam{
+		{ID: "final", Title: "Final", Order: 2, MaxMarks: 20, Weight: 3},
+		{ID: "quiz", Title: "Quiz", Order: 1, MaxMarks: 10},
+	}
+	students := []User{
+		{ID: "b", Firstname: "Bashir"},
+		{ID: "a", Firstname: "Amal"},
+		{ID: "d", Firstname: "Dana"},
+	}
+	answers := []Answer{
+		{UserId: "a", ExamId: "quiz", Corrected: true, Grade: 5},
+		{UserId: "a", ExamId: "final", Corrected: true, Grade: 20, MaxGrade: 20},
+		{UserId: "b", ExamId: "quiz", Corrected: true, Grade: 10, MaxGrade: 10},
+		{UserId: "b", ExamId: "final"},
+		// unsubscribed since, still in the gradebook
+		{UserId: "c", ExamId: "quiz", Corrected: true, Grade: 9},
+		{UserId: "a", ExamId: "deleted", Corrected: true},
+	}
+	g := BuildGradebook(exams, students, answers)
+
+	assert.Equal(t, g.Exams[0].ID, "quiz")
+	assert.Equal(t, len(g.Rows), 4)
+	assert.Equal(t, g.Rows[0].Name, "Amal")
+	assert.Equal(t, g.Rows[3].Name, "c")
+
+	// (50 + 3*100) / 4
+	assert.Equal(t, g.Rows[0].Average, 87.5)
+	assert.Equal(t, g.Rows[1].Average, 100.0)
+	assert.Equal(t, g.Rows[2].HasAverage, false)
+
+	quiz := g.Stats[0]
+	assert.Equal(t, quiz.Submitted, 3)
+	assert.Equal(t, quiz.Rate, 75.0)
+	assert.Equal(t, quiz.Mean, 80.0)
+	assert.Equal(t, quiz.Median, 90.0)
+	assert.Equal(t, quiz.Histogram[5], 1)
+	assert.Equal(t, quiz.Histogram[9], 2)
+	assert.Equal(t, len(quiz.Missing), 1)
+	assert.Equal(t, quiz.Missing[0], "Dana")
+
+	final := g.Stats[1]
+	assert.Equal(t, final.Submitted, 2)
+	assert.Equal(t, final.Corrected, 1)
+	assert.Equal(t, final.Median, 100.0)
+
+	table := g.Table()
+	assert.Equal(t, len(table), 5)
+	assert.Equal(t, table[0][2], any("Quiz (/10, x1)"))
+	assert.Equal(t, table[2][3], any("uncorrected"))
+	assert.Equal(t, table[3][3], any("missing"))
+	assert.Equal(t, table[1][4], any(87.5))
+}
+
+func TestCSVRecord(t *testing.T) {
+	record := CSVRecord([]any{"=HYPERLINK(\"x\")", "+964", "-1", "@SUM(A1)", "Ali", 7, -2, 87.5, nil})
+	assert.Equal(t, record[0], "'=HYPERLINK(\"x\")")
+	assert.Equal(t, record[1], "'+964")
+	assert.Equal(t, record[2], "'-1")
+	assert.Equal(t, record[3], "'@SUM(A1)")
+	assert.Equal(t, record[4], "Ali")
+	// numbers are written by the gradebook, not typed in
+	assert.Equal(t, record[5], "7")
+	assert.Equal(t, record[6], "-2")
+	assert.Equal(t, record[7], "87.5")
+	assert.Equal(t, record[8], "")
+}
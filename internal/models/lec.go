@@ -2,6 +2,7 @@ package models
 
 import (
 	"context"
+	"time"
 
 	"cloud.google.com/go/firestore"
 	"google.golang.org/api/iterator"
@@ -13,9 +14,21 @@ type Lec struct {
 	Title       string `firestore:"title"`
 	Description string `firestore:"description"`
 	Order       int    `firestore:"order"`
+	SectionId   string `firestore:"section_id"`
 	VideoUrl    string `firestore:"video_url"`
 	FolderId    string `firestore:"folder_id"`
 	Free        bool   `firestore:"free"`
+	// when the lecture goes out, zero for right away
+	PublishAt time.Time `firestore:"publish_at"`
+	// percent the student watched, filled in for the student's pages
+	Watched float64 `firestore:"-"`
+	// when a lecture that isn't out yet opens to the student, filled in for
+	// the course page
+	LockedUntil time.Time `firestore:"-"`
+	// what students have to do before the lecture opens
+	Prerequisites []Prerequisite `firestore:"prerequisites"`
+	// the prerequisites the student hasn't met, filled in for the course page
+	Requires []Prerequisite `firestore:"-"`
 }
 
 type LecModel struct {
@@ -83,3 +96,9 @@ func (l *LecModel) Delete(ctx context.Context, courseId, lecId string) error {
 	}
 	return nil
 }
+
+// Reorder sets the order of the course's lectures to the order of ids,
+// which must list all of them.
+func (l *LecModel) Reorder(ctx context.Context, courseId string, ids []string) error {
+	return reorder(ctx, l.DB, l.DB.Collection("courses").Doc(courseId).Collection("lecs"), ids)
+}
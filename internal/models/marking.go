@@ -0,0 +1,198 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+// MarksNeeded is how many correctors grade an answer to a double marked
+// exam.
+const MarksNeeded = 2
+
+var ErrFullyMarked = errors.New("models: answer was already marked by two correctors")
+
+// Mark is one corrector's independent grade of a double marked answer, the
+// grade is before any late penalty.
+type Mark struct {
+	CorrectorId string           `firestore:"corrector_id"`
+	Corrector   string           `firestore:"corrector"`
+	Grade       int              `firestore:"grade"`
+	Scores      []CriterionScore `firestore:"scores"`
+	Notes       string           `firestore:"notes"`
+	MarkedAt    time.Time        `firestore:"marked_at"`
+}
+
+// AwaitsCorrector reports whether the answer still needs a corrector, answers
+// waiting for a moderator don't.
+func (a *Answer) AwaitsCorrector() bool {
+	return !a.Corrected && !a.NeedsModeration
+}
+
+// MarkBy returns the corrector's mark of the answer, nil if they haven't
+// marked it.
+func (a *Answer) MarkBy(correctorId string) *Mark {
+	for i := range a.Marks {
+		if a.Marks[i].CorrectorId == correctorId {
+			return &a.Marks[i]
+		}
+	}
+	return nil
+}
+
+// MarkerNames lists who marked the answer.
+func (a *Answer) MarkerNames() string {
+	names := make([]string, 0, len(a.Marks))
+	for _, m := range a.Marks {
+		names = append(names, m.Corrector)
+	}
+	return strings.Join(names, ", ")
+}
+
+// AddMark records mark, a corrector marking again replaces their own mark.
+func AddMark(marks []Mark, mark Mark) ([]Mark, error) {
+	out := make([]Mark, 0, len(marks)+1)
+	replaced := false
+	for _, m := range marks {
+		if m.CorrectorId == mark.CorrectorId {
+			m, replaced = mark, true
+		}
+		out = append(out, m)
+	}
+	if replaced {
+		return out, nil
+	}
+	if len(marks) >= MarksNeeded {
+		return marks, ErrFullyMarked
+	}
+	return append(out, mark), nil
+}
+
+// Reconcile settles the marks of an answer marked twice. Marks up to
+// threshold points apart agree on their average, rounded up, and the
+// average of each criterion. Otherwise a moderator decides.
+func Reconcile(marks []Mark, threshold int) (int, []CriterionScore, bool) {
+	if len(marks) < MarksNeeded {
+		return 0, nil, false
+	}
+	a, b := marks[0], marks[1]
+	diff := a.Grade - b.Grade
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff > threshold {
+		return 0, nil, false
+	}
+	var scores []CriterionScore
+	if len(a.Scores) == len(b.Scores) {
+		for i, s := range a.Scores {
+			s.Awarded = (s.Awarded + b.Scores[i].Awarded + 1) / 2
+			scores = append(scores, s)
+		}
+	}
+	return (a.Grade + b.Grade + 1) / 2, scores, true
+}
+
+// joinNotes puts the notes of every mark together for the student.
+func joinNotes(marks []Mark) string {
+	var notes []string
+	for _, m := range marks {
+		if m.Notes != "" {
+			notes = append(notes, m.Notes)
+		}
+	}
+	return strings.Join(notes, "\n")
+}
+
+// ApplyMark records the corrector's mark on the answer and settles it once
+// it has two, the grade gets the late penalty. It reports whether the answer
+// is now corrected.
+func (a *Answer) ApplyMark(mark Mark, threshold int) (bool, error) {
+	marks, err := AddMark(a.Marks, mark)
+	if err != nil {
+		return false, err
+	}
+	a.Marks = marks
+	a.Corrected = false
+	a.NeedsModeration = false
+	if len(marks) < MarksNeeded {
+		return false, nil
+	}
+	grade, scores, agreed := Reconcile(marks, threshold)
+	if !agreed {
+		a.NeedsModeration = true
+		return false, nil
+	}
+	a.Grade = ApplyPenalty(grade, a.Penalty)
+	a.Scores = scores
+	a.Notes = joinNotes(marks)
+	a.Corrector = a.MarkerNames()
+	a.Corrected = true
+	a.CorrectedAt = mark.MarkedAt
+	return true, nil
+}
+
+// Moderate settles an answer the markers disagreed on with the moderator's
+// grade, before the late penalty.
+func (a *Answer) Moderate(grade int, moderator, note string, now time.Time) {
+	a.Grade = ApplyPenalty(grade, a.Penalty)
+	a.Scores = nil
+	a.Notes = joinNotes(a.Marks)
+	a.Corrector = a.MarkerNames()
+	a.Corrected = true
+	a.CorrectedAt = now
+	a.NeedsModeration = false
+	a.ModeratedBy = moderator
+	a.ModeratedAt = now
+	a.ModerationNote = note
+}
+
+// GetNeedingModeration returns the attempts whose markers disagreed, in every
+// course. Answers from before attempts existed are included.
+func (s *AnswerModel) GetNeedingModeration(ctx context.Context) (*[]Answer, error) {
+	var answers []Answer
+	for _, group := range []string{"attempts", "answers"} {
+		docs, err := s.DB.CollectionGroup(group).Where("needs_moderation", "==", true).Documents(ctx).GetAll()
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			var ans Answer
+			if err := doc.DataTo(&ans); err != nil {
+				return nil, err
+			}
+			// exam answers with attempts are copies of one
+			if group == "answers" && ans.Attempts > 0 {
+				continue
+			}
+			ans.ID = doc.Ref.ID
+			answers = append(answers, ans)
+		}
+	}
+	return &answers, nil
+}
+
+// AddMarker notes that the corrector marked the queued answer, their claim
+// and assignment are dropped so any other corrector can take the second mark.
+func (s *QueueModel) AddMarker(ctx context.Context, courseId, examId, userId, correctorId string) error {
+	ref := queueRef(s.DB, courseId, examId, userId)
+	return s.DB.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if doc != nil && !doc.Exists() {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Update(ref, []firestore.Update{
+			{Path: "marked_by", Value: firestore.ArrayUnion(correctorId)},
+			{Path: "assigned_to", Value: ""},
+			{Path: "claimed_by", Value: ""},
+			{Path: "claimed_by_name", Value: ""},
+			{Path: "claimed_until", Value: time.Time{}},
+		})
+	})
+}
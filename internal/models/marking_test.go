@@ -0,0 +1,57 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/alghurabi0/rehla/internal/assert"
+)
+
+func TestAddMark(t *testing.T) {
+	marks, err := AddMark(nil, Mark{CorrectorId: "a", Grade: 5})
+	assert.Equal(t, err, nil)
+	marks, _ = AddMark(marks, Mark{CorrectorId: "b", Grade: 7})
+	// marking again replaces the corrector's own mark
+	marks, err = AddMark(marks, Mark{CorrectorId: "a", Grade: 6})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(marks), 2)
+	assert.Equal(t, marks[0].Grade, 6)
+
+	_, err = AddMark(marks, Mark{CorrectorId: "c"})
+	assert.Equal(t, err, ErrFullyMarked)
+}
+
+func TestReconcile(t *testing.T) {
+	marks := []Mark{
+		{Grade: 7, Scores: []CriterionScore{{Criterion: "x", Points: 10, Awarded: 7}}},
+		{Grade: 8, Scores: []CriterionScore{{Criterion: "x", Points: 10, Awarded: 8}}},
+	}
+	grade, scores, agreed := Reconcile(marks, 1)
+	assert.Equal(t, agreed, true)
+	assert.Equal(t, grade, 8)
+	assert.Equal(t, scores[0].Awarded, 8)
+
+	_, _, agreed = Reconcile(marks, 0)
+	assert.Equal(t, agreed, false)
+	_, _, agreed = Reconcile(marks[:1], 10)
+	assert.Equal(t, agreed, false)
+}
+
+func TestApplyMark(t *testing.T) {
+	a := &Answer{Penalty: 50}
+	corrected, _ := a.ApplyMark(Mark{CorrectorId: "a", Corrector: "amal", Grade: 10, Notes: "good"}, 2)
+	assert.Equal(t, corrected, false)
+	assert.Equal(t, a.AwaitsCorrector(), true)
+
+	corrected, _ = a.ApplyMark(Mark{CorrectorId: "b", Corrector: "bashir", Grade: 4}, 2)
+	assert.Equal(t, corrected, false)
+	assert.Equal(t, a.NeedsModeration, true)
+	assert.Equal(t, a.AwaitsCorrector(), false)
+
+	// the second marker reconsiders and the marks agree
+	corrected, _ = a.ApplyMark(Mark{CorrectorId: "b", Corrector: "bashir", Grade: 9}, 2)
+	assert.Equal(t, corrected, true)
+	assert.Equal(t, a.NeedsModeration, false)
+	assert.Equal(t, a.Grade, 5)
+	assert.Equal(t, a.Notes, "good")
+	assert.Equal(t, a.Corrector, "amal, bashir")
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"context"
+	"time"
 
 	"cloud.google.com/go/firestore"
 	"firebase.google.com/go/storage"
@@ -9,12 +10,17 @@ import (
 )
 
 type Material struct {
-	ID       string `firestore:"-"`
-	CourseId string `firestore:"-"`
-	Title    string `firestore:"title"`
-	Order    int    `firestore:"order"`
-	URL      string `firestore:"url"`
-	FilePath string `firestore:"file_path"`
+	ID        string `firestore:"-"`
+	CourseId  string `firestore:"-"`
+	Title     string `firestore:"title"`
+	Order     int    `firestore:"order"`
+	SectionId string `firestore:"section_id"`
+	URL       string `firestore:"url"`
+	FilePath  string `firestore:"file_path"`
+	// when the material goes out, zero for right away
+	PublishAt time.Time `firestore:"publish_at"`
+	// filled in for the course page while the material isn't out
+	LockedUntil time.Time `firestore:"-"`
 }
 
 type MaterialModel struct {
@@ -85,6 +91,12 @@ func (m *MaterialModel) Delete(ctx context.Context, courseId, materialId string)
 	return nil
 }
 
+// Reorder sets the order of the course's materials to the order of ids,
+// which must list all of them.
+func (m *MaterialModel) Reorder(ctx context.Context, courseId string, ids []string) error {
+	return reorder(ctx, m.DB, m.DB.Collection("courses").Doc(courseId).Collection("materials"), ids)
+}
+
 func (m *MaterialModel) GetFree(ctx context.Context) (*[]Material, error) {
 	matsIter := m.DB.Collection("free_materials").Documents(ctx)
 	var mats []Material
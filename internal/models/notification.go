@@ -0,0 +1,115 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/firestore/apiv1/firestorepb"
+	"google.golang.org/api/iterator"
+)
+
+// Notification is an entry in a user's inbox, stored under
+// users/{userId}/notifications.
+type Notification struct {
+	ID        string    `firestore:"-"`
+	Kind      string    `firestore:"kind"`
+	Title     string    `firestore:"title"`
+	Body      string    `firestore:"body"`
+	Link      string    `firestore:"link"`
+	Read      bool      `firestore:"read"`
+	CreatedAt time.Time `firestore:"created_at"`
+}
+
+type NotificationModel struct {
+	DB *firestore.Client
+}
+
+func (n *NotificationModel) collection(userId string) *firestore.CollectionRef {
+	return n.DB.Collection("users").Doc(userId).Collection("notifications")
+}
+
+// GetAll returns the latest limit notifications, newest first.
+func (n *NotificationModel) GetAll(ctx context.Context, userId string, limit int) (*[]Notification, error) {
+	notIter := n.collection(userId).OrderBy("created_at", firestore.Desc).Limit(limit).Documents(ctx)
+	var notifications []Notification
+	for {
+		doc, err := notIter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var notification Notification
+		if err := doc.DataTo(&notification); err != nil {
+			return nil, err
+		}
+		notification.ID = doc.Ref.ID
+		notifications = append(notifications, notification)
+	}
+	return &notifications, nil
+}
+
+func (n *NotificationModel) CountUnread(ctx context.Context, userId string) (int, error) {
+	unread := n.collection(userId).Where("read", "==", false)
+	res, err := unread.NewAggregationQuery().WithCount("unread").Get(ctx)
+	if err != nil {
+		return 0, err
+	}
+	count, ok := res["unread"].(*firestorepb.Value)
+	if !ok {
+		return 0, nil
+	}
+	return int(count.GetIntegerValue()), nil
+}
+
+func (n *NotificationModel) Create(ctx context.Context, userId string, notification *Notification) (string, error) {
+	docRef, _, err := n.collection(userId).Add(ctx, notification)
+	if err != nil {
+		return "", err
+	}
+	return docRef.ID, nil
+}
+
+func (n *NotificationModel) Get(ctx context.Context, userId, notId string) (*Notification, error) {
+	doc, err := n.collection(userId).Doc(notId).Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var notification Notification
+	if err := doc.DataTo(&notification); err != nil {
+		return nil, err
+	}
+	notification.ID = doc.Ref.ID
+	return &notification, nil
+}
+
+func (n *NotificationModel) MarkRead(ctx context.Context, userId, notId string) error {
+	_, err := n.collection(userId).Doc(notId).Update(ctx, []firestore.Update{
+		{Path: "read", Value: true},
+	})
+	return err
+}
+
+func (n *NotificationModel) MarkAllRead(ctx context.Context, userId string) error {
+	unreadIter := n.collection(userId).Where("read", "==", false).Documents(ctx)
+	bulk := n.DB.BulkWriter(ctx)
+	for {
+		doc, err := unreadIter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			bulk.End()
+			return err
+		}
+		_, err = bulk.Update(doc.Ref, []firestore.Update{{Path: "read", Value: true}})
+		if err != nil {
+			bulk.End()
+			return err
+		}
+	}
+	bulk.End()
+	return nil
+}
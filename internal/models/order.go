@@ -0,0 +1,55 @@
+package models
+
+import (
+	"context"
+	"errors"
+
+	"cloud.google.com/go/firestore"
+)
+
+var ErrBadOrder = errors.New("models: the order must list every item once")
+
+// checkOrder makes sure ids lists each of the existing ids exactly once.
+func checkOrder(ids, existing []string) error {
+	if len(ids) != len(existing) {
+		return ErrBadOrder
+	}
+	left := make(map[string]bool, len(existing))
+	for _, id := range existing {
+		left[id] = true
+	}
+	for _, id := range ids {
+		if !left[id] {
+			return ErrBadOrder
+		}
+		delete(left, id)
+	}
+	return nil
+}
+
+// reorder numbers the documents of the collection from 1 in the order of
+// ids. It's one transaction so nobody sees half of the new order, and the
+// documents added or deleted in the meantime fail it with ErrBadOrder.
+func reorder(ctx context.Context, db *firestore.Client, coll *firestore.CollectionRef, ids []string) error {
+	return db.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		docs, err := tx.Documents(coll).GetAll()
+		if err != nil {
+			return err
+		}
+		existing := make([]string, len(docs))
+		for i, doc := range docs {
+			existing[i] = doc.Ref.ID
+		}
+		err = checkOrder(ids, existing)
+		if err != nil {
+			return err
+		}
+		for i, id := range ids {
+			err = tx.Update(coll.Doc(id), []firestore.Update{{Path: "order", Value: i + 1}})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
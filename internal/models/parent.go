@@ -0,0 +1,17 @@
+package models
+
+// Parent is a guardian logged in with the phone number stored as
+// ParentPhoneNumber on one or more users. Parents have no document of their
+// own, the session lives in redis only.
+type Parent struct {
+	PhoneNumber string `json:"phone_number"`
+	SessionId   string `json:"session_id"`
+}
+
+// Child is a student linked to a parent, with the courses they are
+// subscribed to. Each course carries the child's payments, balance and
+// answers in its User* fields.
+type Child struct {
+	User    User
+	Courses []Course
+}
@@ -64,6 +64,33 @@ func (p *PaymentModel) GetAll(ctx context.Context, userId, subId string) (*[]Pay
 	return &payments, nil
 }
 
+// GetExpiring returns the payments of every user whose valid_until falls in
+// [from, to).
+func (p *PaymentModel) GetExpiring(ctx context.Context, from, to time.Time) (*[]Payment, error) {
+	payIterator := p.DB.CollectionGroup("payments").Where("valid_until", ">=", from).Where("valid_until", "<", to).Documents(ctx)
+	var payments []Payment
+	for {
+		doc, err := payIterator.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var payment Payment
+		if err := doc.DataTo(&payment); err != nil {
+			return nil, err
+		}
+		// users/{userId}/subs/{subId}/payments/{paymentId}
+		subRef := doc.Ref.Parent.Parent
+		payment.ID = doc.Ref.ID
+		payment.SubId = subRef.ID
+		payment.UserId = subRef.Parent.Parent.ID
+		payments = append(payments, payment)
+	}
+	return &payments, nil
+}
+
 func (p *PaymentModel) Create(ctx context.Context, userId, subId string, payment *Payment) (string, error) {
 	doc, _, err := p.DB.Collection("users").Doc(userId).Collection("subs").Doc(subId).Collection("payments").Add(ctx, payment)
 	if err != nil {
@@ -0,0 +1,164 @@
+package models
+
+import "errors"
+
+var (
+	ErrPrerequisitesUnmet = errors.New("models: prerequisites not met")
+	ErrPrerequisiteCycle  = errors.New("models: prerequisites depend on each other")
+)
+
+const (
+	PrerequisiteExam = "exam"
+	PrerequisiteLec  = "lec"
+)
+
+// Prerequisite is something a student has to do before a lecture or exam
+// opens, get a high enough grade in an exam or watch a lecture.
+type Prerequisite struct {
+	Kind string `firestore:"kind"`
+	ID   string `firestore:"id"`
+	// the lowest grade that passes the exam, out of 100
+	MinPercent int `firestore:"min_percent"`
+	// the exam's or lecture's title, filled in for the student's pages
+	Title string `firestore:"-"`
+}
+
+// HasPrerequisites reports whether any of the course's lectures or exams,
+// which must be loaded, has prerequisites.
+func (c *Course) HasPrerequisites() bool {
+	for _, lec := range c.Lecs {
+		if len(lec.Prerequisites) > 0 {
+			return true
+		}
+	}
+	for _, exam := range c.Exams {
+		if len(exam.Prerequisites) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// UnmetPrerequisites returns the prerequisites the student hasn't met with
+// their titles, the course's lectures and exams must be loaded. Lectures
+// count as watched the way they do for completion and grades held back from
+// the student don't count until they're released. Prerequisites on
+// lectures or exams deleted since hold no one back.
+func UnmetPrerequisites(prereqs []Prerequisite, course *Course, progress []WatchProgress, answers []Answer) []Prerequisite {
+	if len(prereqs) == 0 {
+		return nil
+	}
+	watched := make(map[string]float64, len(progress))
+	for _, p := range progress {
+		watched[p.LecId] = p.Percent()
+	}
+	exams := make(map[string]Exam, len(course.Exams))
+	for _, exam := range course.Exams {
+		exams[exam.ID] = exam
+	}
+	graded := map[string]float64{}
+	for _, ans := range visibleAnswers(answers) {
+		exam, ok := exams[ans.ExamId]
+		if !ok {
+			continue
+		}
+		maxGrade := ans.MaxGrade
+		if maxGrade == 0 {
+			maxGrade = exam.FullMarks()
+		}
+		cell := GradebookCell{Corrected: ans.Corrected, Grade: ans.Grade, MaxGrade: maxGrade}
+		if cell.Graded() {
+			graded[ans.ExamId] = cell.Percent()
+		}
+	}
+
+	var unmet []Prerequisite
+	for _, p := range prereqs {
+		switch p.Kind {
+		case PrerequisiteLec:
+			lec, ok := findLec(course.Lecs, p.ID)
+			if !ok {
+				continue
+			}
+			if watched[p.ID] >= float64(course.minWatch()) {
+				continue
+			}
+			p.Title = lec.Title
+		case PrerequisiteExam:
+			exam, ok := exams[p.ID]
+			if !ok {
+				continue
+			}
+			percent, ok := graded[p.ID]
+			if ok && percent >= float64(p.MinPercent) {
+				continue
+			}
+			p.Title = exam.Title
+		default:
+			continue
+		}
+		unmet = append(unmet, p)
+	}
+	return unmet
+}
+
+// ApplyPrerequisites fills in Requires of the course's lectures and exams
+// with what the student still has to do before they open.
+func ApplyPrerequisites(course *Course, progress []WatchProgress, answers []Answer) {
+	for i := range course.Lecs {
+		course.Lecs[i].Requires = UnmetPrerequisites(course.Lecs[i].Prerequisites, course, progress, answers)
+	}
+	for i := range course.Exams {
+		course.Exams[i].Requires = UnmetPrerequisites(course.Exams[i].Prerequisites, course, progress, answers)
+	}
+}
+
+// CheckPrerequisites returns ErrPrerequisiteCycle when lectures and exams
+// require each other, directly or not, so none of them could ever open.
+func CheckPrerequisites(lecs []Lec, exams []Exam) error {
+	requires := map[string][]Prerequisite{}
+	for _, lec := range lecs {
+		requires[PrerequisiteLec+":"+lec.ID] = lec.Prerequisites
+	}
+	for _, exam := range exams {
+		requires[PrerequisiteExam+":"+exam.ID] = exam.Prerequisites
+	}
+
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	var visit func(node string) bool
+	visit = func(node string) bool {
+		switch state[node] {
+		case visiting:
+			return false
+		case done:
+			return true
+		}
+		state[node] = visiting
+		for _, p := range requires[node] {
+			if !visit(p.Kind + ":" + p.ID) {
+				return false
+			}
+		}
+		state[node] = done
+		return true
+	}
+	for node := range requires {
+		if !visit(node) {
+			return ErrPrerequisiteCycle
+		}
+	}
+	return nil
+}
+
+func findLec(lecs []Lec, id string) (Lec, bool) {
+	for _, lec := range lecs {
+		if lec.ID == id {
+			return lec, true
+		}
+	}
+	return Lec{}, false
+}
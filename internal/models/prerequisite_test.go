@@ -0,0 +1,54 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/alghurabi0/rehla/internal/assert"
+)
+
+func TestUnmetPrerequisites(t *testing.T) {
+	course := &Course{
+		Lecs:  []Lec{{ID: "l1", Title: "one"}, {ID: "l2", Title: "two"}},
+		Exams: []Exam{{ID: "e1", Title: "first"}, {ID: "e2", Title: "second"}},
+	}
+	// all of l1 and none of l2
+	progress := []WatchProgress{{LecId: "l1", Duration: 40, Watched: []byte{0xff}}, {LecId: "l2", Duration: 40}}
+	answers := []Answer{
+		{ExamId: "e1", Corrected: true, Grade: 7, MaxGrade: 10},
+		{ExamId: "e2", Corrected: true, Grade: 10, MaxGrade: 10, Withheld: true},
+	}
+
+	tests := []struct {
+		name   string
+		prereq Prerequisite
+		met    bool
+	}{
+		{"watched", Prerequisite{Kind: PrerequisiteLec, ID: "l1"}, true},
+		{"not watched", Prerequisite{Kind: PrerequisiteLec, ID: "l2"}, false},
+		{"passed", Prerequisite{Kind: PrerequisiteExam, ID: "e1", MinPercent: 70}, true},
+		{"failed", Prerequisite{Kind: PrerequisiteExam, ID: "e1", MinPercent: 71}, false},
+		{"withheld", Prerequisite{Kind: PrerequisiteExam, ID: "e2"}, false},
+		{"deleted", Prerequisite{Kind: PrerequisiteExam, ID: "gone"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unmet := UnmetPrerequisites([]Prerequisite{tt.prereq}, course, progress, answers)
+			assert.Equal(t, len(unmet) == 0, tt.met)
+		})
+	}
+
+	unmet := UnmetPrerequisites([]Prerequisite{{Kind: PrerequisiteLec, ID: "l2"}}, course, nil, nil)
+	assert.Equal(t, unmet[0].Title, "two")
+}
+
+func TestCheckPrerequisites(t *testing.T) {
+	lecs := []Lec{
+		{ID: "l1"},
+		{ID: "l2", Prerequisites: []Prerequisite{{Kind: PrerequisiteExam, ID: "e1"}}},
+	}
+	exams := []Exam{{ID: "e1", Prerequisites: []Prerequisite{{Kind: PrerequisiteLec, ID: "l1"}}}}
+	assert.Equal(t, CheckPrerequisites(lecs, exams), nil)
+
+	lecs[0].Prerequisites = []Prerequisite{{Kind: PrerequisiteLec, ID: "l2"}}
+	assert.Equal(t, CheckPrerequisites(lecs, exams), ErrPrerequisiteCycle)
+}
@@ -0,0 +1,185 @@
+package models
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+// question types, the correct answer is stored as a string in every case:
+// the choice index for mcq, "true" or "false" and a number for numeric.
+const (
+	QuestionMCQ       = "mcq"
+	QuestionTrueFalse = "true_false"
+	QuestionNumeric   = "numeric"
+)
+
+var QuestionTypes = []string{QuestionMCQ, QuestionTrueFalse, QuestionNumeric}
+
+type Question struct {
+	ID       string   `firestore:"-"`
+	CourseId string   `firestore:"-"`
+	Type     string   `firestore:"type"`
+	Text     string   `firestore:"text"`
+	Choices  []string `firestore:"choices"`
+	Answer   string   `firestore:"answer"`
+	// allowed difference for numeric answers
+	Tolerance float64 `firestore:"tolerance"`
+	Points    int     `firestore:"points"`
+	Order     int     `firestore:"order"`
+}
+
+type QuestionModel struct {
+	DB *firestore.Client
+}
+
+// Check reports whether response is a correct answer to the question.
+func (q *Question) Check(response string) bool {
+	response = strings.TrimSpace(response)
+	if response == "" {
+		return false
+	}
+	switch q.Type {
+	case QuestionNumeric:
+		want, err := strconv.ParseFloat(q.Answer, 64)
+		if err != nil {
+			return false
+		}
+		got, err := strconv.ParseFloat(response, 64)
+		if err != nil {
+			return false
+		}
+		return math.Abs(want-got) <= q.Tolerance
+	default:
+		return response == q.Answer
+	}
+}
+
+// GradeResponses grades the responses, keyed by question id, against the
+// questions and returns one item per question with the grade and the max
+// grade.
+func GradeResponses(questions []Question, responses map[string]string) ([]AnswerItem, int, int) {
+	items := make([]AnswerItem, 0, len(questions))
+	for _, q := range questions {
+		response := strings.TrimSpace(responses[q.ID])
+		item := AnswerItem{
+			QuestionId: q.ID,
+			Question:   q.Text,
+			Response:   response,
+			Correct:    q.Check(response),
+			Points:     q.Points,
+		}
+		if item.Correct {
+			item.Awarded = q.Points
+		}
+		items = append(items, item)
+	}
+	grade, maxGrade := SumItems(items)
+	return items, grade, maxGrade
+}
+
+// SumItems returns the awarded points and the max points of the items.
+func SumItems(items []AnswerItem) (int, int) {
+	var grade, maxGrade int
+	for _, item := range items {
+		grade += item.Awarded
+		maxGrade += item.Points
+	}
+	return grade, maxGrade
+}
+
+func (s *QuestionModel) Get(ctx context.Context, courseId, questionId string) (*Question, error) {
+	doc, err := s.DB.Collection("courses").Doc(courseId).Collection("questions").Doc(questionId).Get(ctx)
+	if err != nil {
+		return &Question{}, err
+	}
+	var question Question
+	err = doc.DataTo(&question)
+	if err != nil {
+		return &Question{}, err
+	}
+	question.ID = doc.Ref.ID
+	question.CourseId = courseId
+	return &question, nil
+}
+
+func (s *QuestionModel) GetAll(ctx context.Context, courseId string) (*[]Question, error) {
+	iter := s.DB.Collection("courses").Doc(courseId).Collection("questions").OrderBy("order", firestore.Asc).Documents(ctx)
+	var questions []Question
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var question Question
+		if err := doc.DataTo(&question); err != nil {
+			return nil, err
+		}
+		question.ID = doc.Ref.ID
+		question.CourseId = courseId
+		questions = append(questions, question)
+	}
+	return &questions, nil
+}
+
+// GetMany returns the questions in the order of ids, questions that were
+// deleted from the bank are skipped.
+func (s *QuestionModel) GetMany(ctx context.Context, courseId string, ids []string) (*[]Question, error) {
+	var questions []Question
+	if len(ids) == 0 {
+		return &questions, nil
+	}
+	col := s.DB.Collection("courses").Doc(courseId).Collection("questions")
+	refs := make([]*firestore.DocumentRef, 0, len(ids))
+	for _, id := range ids {
+		refs = append(refs, col.Doc(id))
+	}
+	docs, err := s.DB.GetAll(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range docs {
+		if !doc.Exists() {
+			continue
+		}
+		var question Question
+		if err := doc.DataTo(&question); err != nil {
+			return nil, err
+		}
+		question.ID = doc.Ref.ID
+		question.CourseId = courseId
+		questions = append(questions, question)
+	}
+	return &questions, nil
+}
+
+func (s *QuestionModel) Create(ctx context.Context, courseId string, question *Question) (string, error) {
+	doc, _, err := s.DB.Collection("courses").Doc(courseId).Collection("questions").Add(ctx, question)
+	if err != nil {
+		return "", err
+	}
+	return doc.ID, nil
+}
+
+func (s *QuestionModel) Update(ctx context.Context, courseId, questionId string, updates []firestore.Update) error {
+	_, err := s.DB.Collection("courses").Doc(courseId).Collection("questions").Doc(questionId).Update(ctx, updates)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *QuestionModel) Delete(ctx context.Context, courseId, questionId string) error {
+	_, err := s.DB.Collection("courses").Doc(courseId).Collection("questions").Doc(questionId).Delete(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
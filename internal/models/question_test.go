@@ -0,0 +1,47 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/alghurabi0/rehla/internal/assert"
+)
+
+func TestQuestionCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		question Question
+		response string
+		want     bool
+	}{
+		{"mcq correct", Question{Type: QuestionMCQ, Answer: "2"}, "2", true},
+		{"mcq wrong", Question{Type: QuestionMCQ, Answer: "2"}, "1", false},
+		{"true false", Question{Type: QuestionTrueFalse, Answer: "false"}, "false", true},
+		{"numeric exact", Question{Type: QuestionNumeric, Answer: "9.81"}, "9.81", true},
+		{"numeric within tolerance", Question{Type: QuestionNumeric, Answer: "9.81", Tolerance: 0.05}, " 9.8", true},
+		{"numeric outside tolerance", Question{Type: QuestionNumeric, Answer: "9.81", Tolerance: 0.005}, "9.8", false},
+		{"numeric not a number", Question{Type: QuestionNumeric, Answer: "3"}, "three", false},
+		{"empty response", Question{Type: QuestionTrueFalse, Answer: "true"}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.question.Check(tt.response), tt.want)
+		})
+	}
+}
+
+func TestGradeResponses(t *testing.T) {
+	questions := []Question{
+		{ID: "q1", Type: QuestionMCQ, Answer: "0", Points: 2},
+		{ID: "q2", Type: QuestionTrueFalse, Answer: "true", Points: 1},
+		{ID: "q3", Type: QuestionNumeric, Answer: "12", Points: 3},
+	}
+	responses := map[string]string{"q1": "0", "q3": "11"}
+
+	items, grade, maxGrade := GradeResponses(questions, responses)
+	assert.Equal(t, len(items), 3)
+	assert.Equal(t, grade, 2)
+	assert.Equal(t, maxGrade, 6)
+	assert.Equal(t, items[0].Awarded, 2)
+	assert.Equal(t, items[1].Correct, false)
+	assert.Equal(t, items[2].Response, "11")
+}
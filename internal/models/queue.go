@@ -0,0 +1,336 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+// ClaimDuration is how long a corrector keeps an answer to themselves after
+// opening it.
+const ClaimDuration = 30 * time.Minute
+
+var (
+	ErrClaimed    = errors.New("models: answer is claimed by another corrector")
+	ErrNotQueued  = errors.New("models: answer is not waiting for correction")
+	ErrQueueEmpty = errors.New("models: no answers waiting for correction")
+)
+
+// QueueItem is a student's answer to an upload exam waiting for correction,
+// it's added when an attempt is submitted and removed once every attempt is
+// corrected. Items live in courses/{courseId}/correction_queue/{examId}_{userId}.
+type QueueItem struct {
+	ID          string    `firestore:"-"`
+	CourseId    string    `firestore:"course_id"`
+	ExamId      string    `firestore:"exam_id"`
+	ExamTitle   string    `firestore:"exam_title"`
+	UserId      string    `firestore:"user_id"`
+	SubmittedAt time.Time `firestore:"submitted_at"`
+	// dashboard user the item was handed to, empty for anyone
+	AssignedTo string `firestore:"assigned_to"`
+	// the corrector working on it, the claim runs out at ClaimedUntil
+	ClaimedBy     string    `firestore:"claimed_by"`
+	ClaimedByName string    `firestore:"claimed_by_name"`
+	ClaimedUntil  time.Time `firestore:"claimed_until"`
+	// correctors who marked a double marked answer
+	MarkedBy []string `firestore:"marked_by"`
+}
+
+// ClaimedByOther reports whether someone other than correctorId holds the
+// item at now.
+func (q *QueueItem) ClaimedByOther(correctorId string, now time.Time) bool {
+	return q.ClaimedBy != "" && q.ClaimedBy != correctorId && now.Before(q.ClaimedUntil)
+}
+
+// IsClaimed reports whether anyone holds the item at now.
+func (q *QueueItem) IsClaimed(now time.Time) bool {
+	return q.ClaimedBy != "" && now.Before(q.ClaimedUntil)
+}
+
+// QueueDepth sums up an exam's queue for admins.
+type QueueDepth struct {
+	CourseId  string
+	ExamId    string
+	ExamTitle string
+	Waiting   int
+	Claimed   int
+	Oldest    time.Time
+}
+
+type QueueModel struct {
+	DB *firestore.Client
+}
+
+func queueRef(db *firestore.Client, courseId, examId, userId string) *firestore.DocumentRef {
+	return db.Collection("courses").Doc(courseId).Collection("correction_queue").Doc(fmt.Sprintf("%s_%s", examId, userId))
+}
+
+// NextItem picks the oldest item correctorId can take at now, items assigned
+// to them come before unassigned ones. Items held by others or already
+// marked by the corrector are skipped.
+func NextItem(items []QueueItem, correctorId string, now time.Time) (QueueItem, bool) {
+	sorted := make([]QueueItem, len(items))
+	copy(sorted, items)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].SubmittedAt.Before(sorted[j].SubmittedAt) })
+	for _, assigned := range []string{correctorId, ""} {
+		for _, item := range sorted {
+			if item.AssignedTo == assigned && !item.ClaimedByOther(correctorId, now) && !slices.Contains(item.MarkedBy, correctorId) {
+				return item, true
+			}
+		}
+	}
+	return QueueItem{}, false
+}
+
+// AssignRoundRobin hands the unassigned items to the correctors in turn,
+// oldest first, and returns the items it changed.
+func AssignRoundRobin(items []QueueItem, correctorIds []string) []QueueItem {
+	if len(correctorIds) == 0 {
+		return nil
+	}
+	var unassigned []QueueItem
+	for _, item := range items {
+		if item.AssignedTo == "" {
+			unassigned = append(unassigned, item)
+		}
+	}
+	sort.SliceStable(unassigned, func(i, j int) bool { return unassigned[i].SubmittedAt.Before(unassigned[j].SubmittedAt) })
+	for i := range unassigned {
+		unassigned[i].AssignedTo = correctorIds[i%len(correctorIds)]
+	}
+	return unassigned
+}
+
+// Depths groups the items by exam, oldest waiting first.
+func Depths(items []QueueItem, now time.Time) []QueueDepth {
+	byExam := map[string]*QueueDepth{}
+	var depths []*QueueDepth
+	for _, item := range items {
+		key := item.CourseId + "/" + item.ExamId
+		depth, ok := byExam[key]
+		if !ok {
+			depth = &QueueDepth{CourseId: item.CourseId, ExamId: item.ExamId, ExamTitle: item.ExamTitle, Oldest: item.SubmittedAt}
+			byExam[key] = depth
+			depths = append(depths, depth)
+		}
+		depth.Waiting++
+		if item.IsClaimed(now) {
+			depth.Claimed++
+		}
+		if item.SubmittedAt.Before(depth.Oldest) {
+			depth.Oldest = item.SubmittedAt
+		}
+	}
+	out := make([]QueueDepth, 0, len(depths))
+	for _, depth := range depths {
+		out = append(out, *depth)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Oldest.Before(out[j].Oldest) })
+	return out
+}
+
+// GetExam returns the items waiting for correction in an exam.
+func (s *QueueModel) GetExam(ctx context.Context, courseId, examId string) (*[]QueueItem, error) {
+	docs, err := s.DB.Collection("courses").Doc(courseId).Collection("correction_queue").Where("exam_id", "==", examId).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	return toQueueItems(docs)
+}
+
+// GetAll returns every item waiting for correction in every course.
+func (s *QueueModel) GetAll(ctx context.Context) (*[]QueueItem, error) {
+	docs, err := s.DB.CollectionGroup("correction_queue").Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	return toQueueItems(docs)
+}
+
+// Claim locks the item for the corrector until ClaimDuration from now, a
+// corrector claiming their own item again extends the lock.
+func (s *QueueModel) Claim(ctx context.Context, courseId, examId, userId, correctorId, correctorName string, now time.Time) (*QueueItem, error) {
+	ref := queueRef(s.DB, courseId, examId, userId)
+	var item QueueItem
+	err := s.DB.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if doc != nil && !doc.Exists() {
+			return ErrNotQueued
+		}
+		if err != nil {
+			return err
+		}
+		err = doc.DataTo(&item)
+		if err != nil {
+			return err
+		}
+		if item.ClaimedByOther(correctorId, now) {
+			return ErrClaimed
+		}
+		item.ClaimedBy = correctorId
+		item.ClaimedByName = correctorName
+		item.ClaimedUntil = now.Add(ClaimDuration)
+		return tx.Update(ref, []firestore.Update{
+			{Path: "claimed_by", Value: item.ClaimedBy},
+			{Path: "claimed_by_name", Value: item.ClaimedByName},
+			{Path: "claimed_until", Value: item.ClaimedUntil},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	item.ID = ref.ID
+	return &item, nil
+}
+
+// ClaimNext claims the next item the corrector can take in the exam, the
+// student skipUserId is passed over so correctors can skip an answer.
+func (s *QueueModel) ClaimNext(ctx context.Context, courseId, examId, skipUserId, correctorId, correctorName string, now time.Time) (*QueueItem, error) {
+	items, err := s.GetExam(ctx, courseId, examId)
+	if err != nil {
+		return nil, err
+	}
+	var remaining []QueueItem
+	for _, item := range *items {
+		if item.UserId != skipUserId {
+			remaining = append(remaining, item)
+		}
+	}
+	for {
+		next, ok := NextItem(remaining, correctorId, now)
+		if !ok {
+			return nil, ErrQueueEmpty
+		}
+		item, err := s.Claim(ctx, courseId, examId, next.UserId, correctorId, correctorName, now)
+		// someone got to it first
+		if errors.Is(err, ErrClaimed) || errors.Is(err, ErrNotQueued) {
+			remaining = withoutItem(remaining, next.ID)
+			continue
+		}
+		return item, err
+	}
+}
+
+// Release gives up the corrector's claim on the item.
+func (s *QueueModel) Release(ctx context.Context, courseId, examId, userId, correctorId string) error {
+	ref := queueRef(s.DB, courseId, examId, userId)
+	return s.DB.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if doc != nil && !doc.Exists() {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var item QueueItem
+		err = doc.DataTo(&item)
+		if err != nil {
+			return err
+		}
+		if item.ClaimedBy != correctorId {
+			return nil
+		}
+		return tx.Update(ref, []firestore.Update{
+			{Path: "claimed_by", Value: ""},
+			{Path: "claimed_by_name", Value: ""},
+			{Path: "claimed_until", Value: time.Time{}},
+		})
+	})
+}
+
+// Assign sets who the items are assigned to.
+func (s *QueueModel) Assign(ctx context.Context, items []QueueItem) error {
+	bw := s.DB.BulkWriter(ctx)
+	for _, item := range items {
+		ref := queueRef(s.DB, item.CourseId, item.ExamId, item.UserId)
+		_, err := bw.Update(ref, []firestore.Update{{Path: "assigned_to", Value: item.AssignedTo}})
+		if err != nil {
+			bw.End()
+			return err
+		}
+	}
+	bw.End()
+	return nil
+}
+
+// Sync makes the exam's queue match its answers, answers submitted before
+// the queue existed are added and corrected ones removed.
+func (s *QueueModel) Sync(ctx context.Context, courseId, examId string, answers []Answer) error {
+	items, err := s.GetExam(ctx, courseId, examId)
+	if err != nil {
+		return err
+	}
+	queued := map[string]bool{}
+	for _, item := range *items {
+		queued[item.UserId] = true
+	}
+	bw := s.DB.BulkWriter(ctx)
+	defer bw.End()
+	for _, answer := range answers {
+		ref := queueRef(s.DB, courseId, examId, answer.UserId)
+		if answer.Corrected && queued[answer.UserId] {
+			_, err = bw.Delete(ref)
+		} else if !answer.Corrected && !queued[answer.UserId] {
+			_, err = bw.Create(ref, newQueueItem(&answer))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteExam empties the exam's queue, used when the exam is deleted.
+func (s *QueueModel) DeleteExam(ctx context.Context, courseId, examId string) error {
+	items, err := s.GetExam(ctx, courseId, examId)
+	if err != nil {
+		return err
+	}
+	bw := s.DB.BulkWriter(ctx)
+	defer bw.End()
+	for _, item := range *items {
+		_, err = bw.Delete(queueRef(s.DB, courseId, examId, item.UserId))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newQueueItem(answer *Answer) QueueItem {
+	return QueueItem{
+		CourseId:    answer.CourseId,
+		ExamId:      answer.ExamId,
+		ExamTitle:   answer.ExamTitle,
+		UserId:      answer.UserId,
+		SubmittedAt: answer.DateOfSubmission,
+	}
+}
+
+func withoutItem(items []QueueItem, id string) []QueueItem {
+	out := make([]QueueItem, 0, len(items))
+	for _, item := range items {
+		if item.ID != id {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func toQueueItems(docs []*firestore.DocumentSnapshot) (*[]QueueItem, error) {
+	var items []QueueItem
+	for _, doc := range docs {
+		var item QueueItem
+		if err := doc.DataTo(&item); err != nil {
+			return nil, err
+		}
+		item.ID = doc.Ref.ID
+		items = append(items, item)
+	}
+	return &items, nil
+}
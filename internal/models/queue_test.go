@@ -0,0 +1,63 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alghurabi0/rehla/internal/assert"
+)
+
+func TestNextItem(t *testing.T) {
+	now := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	items := []QueueItem{
+		{ID: "held", SubmittedAt: now.Add(-4 * time.Hour), ClaimedBy: "b", ClaimedUntil: now.Add(time.Minute)},
+		{ID: "expired", SubmittedAt: now.Add(-3 * time.Hour), ClaimedBy: "b", ClaimedUntil: now.Add(-time.Minute)},
+		{ID: "theirs", SubmittedAt: now.Add(-5 * time.Hour), AssignedTo: "b"},
+		{ID: "mine", SubmittedAt: now.Add(-time.Hour), AssignedTo: "a"},
+	}
+
+	next, ok := NextItem(items, "a", now)
+	assert.Equal(t, ok, true)
+	assert.Equal(t, next.ID, "mine")
+
+	next, _ = NextItem(items[:3], "a", now)
+	assert.Equal(t, next.ID, "expired")
+
+	// the holder gets their own claim back
+	next, _ = NextItem(items[:1], "b", now)
+	assert.Equal(t, next.ID, "held")
+
+	_, ok = NextItem(items[:1], "a", now)
+	assert.Equal(t, ok, false)
+}
+
+func TestAssignRoundRobin(t *testing.T) {
+	now := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	items := []QueueItem{
+		{ID: "3", SubmittedAt: now.Add(3 * time.Minute)},
+		{ID: "1", SubmittedAt: now.Add(time.Minute)},
+		{ID: "kept", SubmittedAt: now, AssignedTo: "c"},
+		{ID: "2", SubmittedAt: now.Add(2 * time.Minute)},
+	}
+
+	changed := AssignRoundRobin(items, []string{"a", "b"})
+	assert.Equal(t, len(changed), 3)
+	for i, want := range []string{"a", "b", "a"} {
+		assert.Equal(t, changed[i].AssignedTo, want)
+	}
+	assert.Equal(t, len(AssignRoundRobin(items, nil)), 0)
+}
+
+func TestDepths(t *testing.T) {
+	now := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	items := []QueueItem{
+		{CourseId: "c", ExamId: "e1", SubmittedAt: now.Add(-time.Hour)},
+		{CourseId: "c", ExamId: "e2", SubmittedAt: now.Add(-3 * time.Hour), ClaimedBy: "a", ClaimedUntil: now.Add(time.Minute)},
+		{CourseId: "c", ExamId: "e1", SubmittedAt: now.Add(-2 * time.Hour), ClaimedBy: "a", ClaimedUntil: now.Add(-time.Minute)},
+	}
+
+	depths := Depths(items, now)
+	assert.Equal(t, len(depths), 2)
+	assert.Equal(t, depths[0], QueueDepth{CourseId: "c", ExamId: "e2", Waiting: 1, Claimed: 1, Oldest: now.Add(-3 * time.Hour)})
+	assert.Equal(t, depths[1], QueueDepth{CourseId: "c", ExamId: "e1", Waiting: 2, Oldest: now.Add(-2 * time.Hour)})
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrNotReleased = errors.New("models: not released yet")
+
+// Released reports whether content going out at at is out by now, zero at
+// means it always was.
+func Released(at, now time.Time) bool {
+	return !now.Before(at)
+}
+
+// ReleasedAt returns when the lecture opens to a student whose drip
+// schedule started at start, zero start when the course doesn't drip. Drip
+// courses open lecture N on day N, N being the lecture's order.
+func (l *Lec) ReleasedAt(start time.Time) time.Time {
+	at := l.PublishAt
+	if !start.IsZero() && l.Order > 1 {
+		day := start.AddDate(0, 0, l.Order-1)
+		if day.After(at) {
+			at = day
+		}
+	}
+	return at
+}
+
+// ApplyRelease fills in LockedUntil of the course's lectures, exams and
+// materials that aren't out at now, start being when the student's drip
+// schedule started.
+func ApplyRelease(course *Course, start, now time.Time) {
+	for i := range course.Lecs {
+		at := course.Lecs[i].ReleasedAt(start)
+		if !Released(at, now) {
+			course.Lecs[i].LockedUntil = at
+		}
+	}
+	for i := range course.Exams {
+		if !Released(course.Exams[i].PublishAt, now) {
+			course.Exams[i].LockedUntil = course.Exams[i].PublishAt
+		}
+	}
+	for i := range course.Materials {
+		if !Released(course.Materials[i].PublishAt, now) {
+			course.Materials[i].LockedUntil = course.Materials[i].PublishAt
+		}
+	}
+}
+
+// ReleasedMaterials returns the materials that are out at now.
+func ReleasedMaterials(materials []Material, now time.Time) []Material {
+	released := []Material{}
+	for _, mat := range materials {
+		if Released(mat.PublishAt, now) {
+			released = append(released, mat)
+		}
+	}
+	return released
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alghurabi0/rehla/internal/assert"
+)
+
+func TestLecReleasedAt(t *testing.T) {
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, ExamTimezone)
+	publish := start.Add(10 * 24 * time.Hour)
+
+	assert.Equal(t, (&Lec{Order: 3}).ReleasedAt(time.Time{}).IsZero(), true)
+	assert.Equal(t, (&Lec{Order: 1}).ReleasedAt(start).IsZero(), true)
+	assert.Equal(t, (&Lec{Order: 3}).ReleasedAt(start), start.AddDate(0, 0, 2))
+	// the later of the two wins
+	assert.Equal(t, (&Lec{Order: 3, PublishAt: publish}).ReleasedAt(start), publish)
+	assert.Equal(t, (&Lec{Order: 20, PublishAt: publish}).ReleasedAt(start), start.AddDate(0, 0, 19))
+}
+
+func TestApplyRelease(t *testing.T) {
+	now := time.Date(2024, 5, 3, 12, 0, 0, 0, ExamTimezone)
+	start := now.AddDate(0, 0, -2)
+	later := now.Add(time.Hour)
+	course := &Course{
+		Lecs:      []Lec{{ID: "l1", Order: 1}, {ID: "l3", Order: 3}, {ID: "l4", Order: 4}},
+		Exams:     []Exam{{ID: "e1", PublishAt: later}, {ID: "e2", PublishAt: now}},
+		Materials: []Material{{ID: "m1", PublishAt: later}, {ID: "m2"}},
+	}
+
+	ApplyRelease(course, start, now)
+	assert.Equal(t, course.Lecs[0].LockedUntil.IsZero(), true)
+	assert.Equal(t, course.Lecs[1].LockedUntil.IsZero(), true)
+	assert.Equal(t, course.Lecs[2].LockedUntil, start.AddDate(0, 0, 3))
+	assert.Equal(t, course.Exams[0].LockedUntil, later)
+	assert.Equal(t, course.Exams[1].LockedUntil.IsZero(), true)
+	assert.Equal(t, course.Materials[0].LockedUntil, later)
+
+	released := ReleasedMaterials(course.Materials, now)
+	assert.Equal(t, len(released), 1)
+	assert.Equal(t, released[0].ID, "m2")
+}
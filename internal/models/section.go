@@ -0,0 +1,177 @@
+package models
+
+import (
+	"context"
+	"sort"
+
+	"cloud.google.com/go/firestore"
+)
+
+// Section is a unit of a course grouping lectures, exams and materials.
+type Section struct {
+	ID          string `firestore:"-"`
+	CourseId    string `firestore:"-"`
+	Title       string `firestore:"title"`
+	Description string `firestore:"description"`
+	Order       int    `firestore:"order"`
+	// lectures of a preview section play without a subscription
+	Preview bool `firestore:"preview"`
+	// the section's content, filled in for the course page
+	Lecs      []Lec      `firestore:"-"`
+	Exams     []Exam     `firestore:"-"`
+	Materials []Material `firestore:"-"`
+}
+
+// previewLecs is how many lectures of a course without sections play
+// without a subscription, the way courses previewed before sections.
+const previewLecs = 3
+
+// Previewable reports whether the lecture plays without a subscription,
+// sections being its course's. Once a course has sections only the lectures
+// of its preview sections do.
+func (l *Lec) Previewable(sections []Section) bool {
+	if l.Free {
+		return true
+	}
+	if len(sections) == 0 {
+		return l.Order <= previewLecs
+	}
+	for _, section := range sections {
+		if section.ID == l.SectionId {
+			return section.Preview
+		}
+	}
+	return false
+}
+
+// GroupSections puts the course's content in its sections, sections and
+// their content in order. Content outside of any section, or in one that
+// was deleted, goes to a last section without an ID.
+func GroupSections(sections []Section, lecs []Lec, exams []Exam, materials []Material) []Section {
+	grouped := append([]Section(nil), sections...)
+	sort.SliceStable(grouped, func(i, j int) bool { return grouped[i].Order < grouped[j].Order })
+	at := make(map[string]int, len(grouped))
+	for i := range grouped {
+		grouped[i].Lecs, grouped[i].Exams, grouped[i].Materials = nil, nil, nil
+		at[grouped[i].ID] = i
+	}
+	rest := -1
+	sectionOf := func(id string) *Section {
+		if i, ok := at[id]; ok {
+			return &grouped[i]
+		}
+		if rest < 0 {
+			grouped = append(grouped, Section{})
+			rest = len(grouped) - 1
+		}
+		return &grouped[rest]
+	}
+
+	lecs = append([]Lec(nil), lecs...)
+	sort.SliceStable(lecs, func(i, j int) bool { return lecs[i].Order < lecs[j].Order })
+	for _, lec := range lecs {
+		s := sectionOf(lec.SectionId)
+		s.Lecs = append(s.Lecs, lec)
+	}
+	exams = append([]Exam(nil), exams...)
+	sort.SliceStable(exams, func(i, j int) bool { return exams[i].Order < exams[j].Order })
+	for _, exam := range exams {
+		s := sectionOf(exam.SectionId)
+		s.Exams = append(s.Exams, exam)
+	}
+	materials = append([]Material(nil), materials...)
+	sort.SliceStable(materials, func(i, j int) bool { return materials[i].Order < materials[j].Order })
+	for _, mat := range materials {
+		s := sectionOf(mat.SectionId)
+		s.Materials = append(s.Materials, mat)
+	}
+	return grouped
+}
+
+type SectionModel struct {
+	DB *firestore.Client
+}
+
+func (s *SectionModel) coll(courseId string) *firestore.CollectionRef {
+	return s.DB.Collection("courses").Doc(courseId).Collection("sections")
+}
+
+func toSection(doc *firestore.DocumentSnapshot, courseId string) (*Section, error) {
+	var section Section
+	err := doc.DataTo(&section)
+	if err != nil {
+		return nil, err
+	}
+	section.ID = doc.Ref.ID
+	section.CourseId = courseId
+	return &section, nil
+}
+
+func (s *SectionModel) Get(ctx context.Context, courseId, sectionId string) (*Section, error) {
+	doc, err := s.coll(courseId).Doc(sectionId).Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return toSection(doc, courseId)
+}
+
+// GetAll returns the course's sections in order.
+func (s *SectionModel) GetAll(ctx context.Context, courseId string) (*[]Section, error) {
+	docs, err := s.coll(courseId).OrderBy("order", firestore.Asc).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	sections := []Section{}
+	for _, doc := range docs {
+		section, err := toSection(doc, courseId)
+		if err != nil {
+			return nil, err
+		}
+		sections = append(sections, *section)
+	}
+	return &sections, nil
+}
+
+func (s *SectionModel) Create(ctx context.Context, courseId string, section *Section) (string, error) {
+	doc, _, err := s.coll(courseId).Add(ctx, section)
+	if err != nil {
+		return "", err
+	}
+	return doc.ID, nil
+}
+
+func (s *SectionModel) Update(ctx context.Context, courseId, sectionId string, updates []firestore.Update) error {
+	_, err := s.coll(courseId).Doc(sectionId).Update(ctx, updates)
+	return err
+}
+
+// Delete deletes the section, its lectures, exams and materials stay in the
+// course outside of any section.
+func (s *SectionModel) Delete(ctx context.Context, courseId, sectionId string) error {
+	course := s.DB.Collection("courses").Doc(courseId)
+	return s.DB.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		var refs []*firestore.DocumentRef
+		for _, kind := range []string{"lecs", "exams", "materials"} {
+			docs, err := tx.Documents(course.Collection(kind).Where("section_id", "==", sectionId)).GetAll()
+			if err != nil {
+				return err
+			}
+			for _, doc := range docs {
+				refs = append(refs, doc.Ref)
+			}
+		}
+		for _, ref := range refs {
+			err := tx.Update(ref, []firestore.Update{{Path: "section_id", Value: ""}})
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Delete(s.coll(courseId).Doc(sectionId))
+	})
+}
+
+// Reorder sets the order of the course's sections to the order of ids,
+// which must list all of them.
+func (s *SectionModel) Reorder(ctx context.Context, courseId string, ids []string) error {
+	return reorder(ctx, s.DB, s.coll(courseId), ids)
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alghurabi0/rehla/internal/assert"
+)
+
+func TestGroupSections(t *testing.T) {
+	sections := []Section{
+		{ID: "b", Title: "Second", Order: 2},
+		{ID: "a", Title: "First", Order: 1, Preview: true},
+		{ID: "empty", Order: 3},
+	}
+	lecs := []Lec{
+		{ID: "l3", Order: 3, SectionId: "b"},
+		{ID: "l1", Order: 1, SectionId: "a"},
+		{ID: "l2", Order: 2, SectionId: "a"},
+		{ID: "old", Order: 4},
+		{ID: "deleted", Order: 5, SectionId: "gone"},
+	}
+	exams := []Exam{{ID: "e1", SectionId: "a"}}
+	materials := []Material{{ID: "m1", SectionId: "b"}}
+
+	grouped := GroupSections(sections, lecs, exams, materials)
+	assert.Equal(t, len(grouped), 4)
+	assert.Equal(t, grouped[0].Title, "First")
+	ids := func(lecs []Lec) string {
+		var s []string
+		for _, lec := range lecs {
+			s = append(s, lec.ID)
+		}
+		return fmt.Sprint(s)
+	}
+	assert.Equal(t, ids(grouped[0].Lecs), "[l1 l2]")
+	assert.Equal(t, grouped[0].Exams[0].ID, "e1")
+	assert.Equal(t, ids(grouped[1].Lecs), "[l3]")
+	assert.Equal(t, grouped[1].Materials[0].ID, "m1")
+	assert.Equal(t, len(grouped[2].Lecs), 0)
+	// the rest goes last, in a section of its own
+	assert.Equal(t, grouped[3].ID, "")
+	assert.Equal(t, ids(grouped[3].Lecs), "[old deleted]")
+	// the sections given are left alone
+	assert.Equal(t, sections[0].ID, "b")
+
+	assert.Equal(t, len(GroupSections(sections, nil, nil, nil)), 3)
+	assert.Equal(t, len(GroupSections(nil, nil, nil, nil)), 0)
+}
+
+func TestLecPreviewable(t *testing.T) {
+	sections := []Section{{ID: "s"}, {ID: "p", Preview: true}}
+	lec := &Lec{Order: 5, SectionId: "s"}
+	assert.Equal(t, lec.Previewable(sections), false)
+	lec.SectionId = "p"
+	assert.Equal(t, lec.Previewable(sections), true)
+	// outside of any section once the course has them
+	lec.SectionId = ""
+	assert.Equal(t, lec.Previewable(sections), false)
+	lec.Free = true
+	assert.Equal(t, lec.Previewable(sections), true)
+}
+
+func TestLecPreviewableWithoutSections(t *testing.T) {
+	// courses from before sections keep previewing their first lectures
+	assert.Equal(t, (&Lec{Order: 1}).Previewable(nil), true)
+	assert.Equal(t, (&Lec{Order: 3}).Previewable(nil), true)
+	assert.Equal(t, (&Lec{Order: 4}).Previewable(nil), false)
+	assert.Equal(t, (&Lec{Order: 4, Free: true}).Previewable(nil), true)
+}
+
+func TestCheckOrder(t *testing.T) {
+	existing := []string{"a", "b", "c"}
+	assert.Equal(t, checkOrder([]string{"c", "a", "b"}, existing), nil)
+	assert.Equal(t, checkOrder([]string{"a", "b"}, existing), ErrBadOrder)
+	assert.Equal(t, checkOrder([]string{"a", "a", "b"}, existing), ErrBadOrder)
+	assert.Equal(t, checkOrder([]string{"a", "b", "d"}, existing), ErrBadOrder)
+	assert.Equal(t, checkOrder(nil, nil), nil)
+}
@@ -0,0 +1,339 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/alghurabi0/rehla/internal/pagehash"
+)
+
+// MaxPageDistance is how many of the 256 bits of two perceptual hashes may
+// differ for the pages to count as the same photo.
+const MaxPageDistance = 25
+
+// PageHash is the fingerprint of one uploaded page.
+type PageHash struct {
+	Content    string `firestore:"content"`
+	Perceptual string `firestore:"perceptual"`
+}
+
+// Fingerprint holds the page hashes of an uploaded attempt, each exam's
+// new uploads are compared against them.
+type Fingerprint struct {
+	ID        string     `firestore:"-"`
+	UserId    string     `firestore:"user_id"`
+	ExamId    string     `firestore:"exam_id"`
+	Attempt   int        `firestore:"attempt"`
+	URL       string     `firestore:"url"`
+	Pages     []PageHash `firestore:"pages"`
+	CreatedAt time.Time  `firestore:"created_at"`
+}
+
+// NewFingerprint hashes the uploaded pages of an attempt.
+func NewFingerprint(answer *Answer, parts [][]byte) Fingerprint {
+	fp := Fingerprint{
+		UserId:    answer.UserId,
+		ExamId:    answer.ExamId,
+		Attempt:   answer.Attempt,
+		URL:       answer.URL,
+		CreatedAt: answer.DateOfSubmission,
+	}
+	for _, part := range parts {
+		page := pagehash.Hash(part)
+		fp.Pages = append(fp.Pages, PageHash{Content: page.Content, Perceptual: page.Perceptual})
+	}
+	return fp
+}
+
+// PagePair is a page of one answer that looks like a page of the other,
+// pages are counted from 1.
+type PagePair struct {
+	Page      int  `firestore:"page"`
+	OtherPage int  `firestore:"other_page"`
+	Identical bool `firestore:"identical"`
+	Distance  int  `firestore:"distance"`
+}
+
+// AnswerMatch is a pair of attempts by two students of the same exam that
+// share pages. The earlier upload comes first.
+type AnswerMatch struct {
+	ID        string     `firestore:"-"`
+	CourseId  string     `firestore:"course_id"`
+	ExamId    string     `firestore:"exam_id"`
+	ExamTitle string     `firestore:"exam_title"`
+	UserIds   []string   `firestore:"user_ids"`
+	Attempts  []int      `firestore:"attempts"`
+	URLs      []string   `firestore:"urls"`
+	Pages     []PagePair `firestore:"pages"`
+	// some page is the very same file
+	Identical bool `firestore:"identical"`
+	// of the closest pages
+	Distance  int       `firestore:"distance"`
+	CreatedAt time.Time `firestore:"created_at"`
+	// how the students are shown, pseudonyms in anonymous exams
+	Names []string `firestore:"-"`
+}
+
+// Other returns the student the answer of userId matched with.
+func (m *AnswerMatch) Other(userId string) string {
+	if len(m.UserIds) < 2 {
+		return ""
+	}
+	if m.UserIds[0] == userId {
+		return m.UserIds[1]
+	}
+	return m.UserIds[0]
+}
+
+// ComparePages pairs up the pages of two uploads that are the same file or
+// the same photo.
+func ComparePages(a, b []PageHash) []PagePair {
+	var pairs []PagePair
+	for i, pa := range a {
+		for j, pb := range b {
+			if pa.Content != "" && pa.Content == pb.Content {
+				pairs = append(pairs, PagePair{Page: i + 1, OtherPage: j + 1, Identical: true})
+				continue
+			}
+			d, ok := pagehash.Distance(pa.Perceptual, pb.Perceptual)
+			if ok && d <= MaxPageDistance {
+				pairs = append(pairs, PagePair{Page: i + 1, OtherPage: j + 1, Distance: d})
+			}
+		}
+	}
+	return pairs
+}
+
+// FindMatches compares a new upload against the exam's earlier ones,
+// the student's own attempts aside.
+func FindMatches(courseId, examTitle string, fp Fingerprint, earlier []Fingerprint) []AnswerMatch {
+	var matches []AnswerMatch
+	for _, other := range earlier {
+		if other.UserId == fp.UserId || other.ExamId != fp.ExamId {
+			continue
+		}
+		pairs := ComparePages(other.Pages, fp.Pages)
+		if len(pairs) == 0 {
+			continue
+		}
+		m := AnswerMatch{
+			CourseId:  courseId,
+			ExamId:    fp.ExamId,
+			ExamTitle: examTitle,
+			UserIds:   []string{other.UserId, fp.UserId},
+			Attempts:  []int{other.Attempt, fp.Attempt},
+			URLs:      []string{other.URL, fp.URL},
+			Pages:     pairs,
+			Distance:  pairs[0].Distance,
+			CreatedAt: fp.CreatedAt,
+		}
+		for _, p := range pairs {
+			m.Identical = m.Identical || p.Identical
+			m.Distance = min(m.Distance, p.Distance)
+		}
+		m.ID = matchId(m.ExamId, other, fp)
+		matches = append(matches, m)
+	}
+	return matches
+}
+
+func matchId(examId string, a, b Fingerprint) string {
+	return fmt.Sprintf("%s_%s_%d_%s_%d", examId, a.UserId, a.Attempt, b.UserId, b.Attempt)
+}
+
+// MatchCluster is a group of students of an exam whose answers match one
+// another, directly or through someone else in the group.
+type MatchCluster struct {
+	CourseId  string
+	ExamId    string
+	ExamTitle string
+	UserIds   []string
+	Matches   []AnswerMatch
+	Identical bool
+}
+
+// Clusters groups the matches of each exam into clusters of students, the
+// largest first.
+func Clusters(matches []AnswerMatch) []MatchCluster {
+	parent := map[string]string{}
+	var find func(string) string
+	find = func(k string) string {
+		if parent[k] == k {
+			return k
+		}
+		parent[k] = find(parent[k])
+		return parent[k]
+	}
+	key := func(m AnswerMatch, userId string) string {
+		return m.CourseId + "/" + m.ExamId + "/" + userId
+	}
+	for _, m := range matches {
+		if len(m.UserIds) < 2 {
+			continue
+		}
+		a, b := key(m, m.UserIds[0]), key(m, m.UserIds[1])
+		for _, k := range []string{a, b} {
+			if _, ok := parent[k]; !ok {
+				parent[k] = k
+			}
+		}
+		parent[find(a)] = find(b)
+	}
+
+	byRoot := map[string]*MatchCluster{}
+	var roots []string
+	for _, m := range matches {
+		if len(m.UserIds) < 2 {
+			continue
+		}
+		root := find(key(m, m.UserIds[0]))
+		c, ok := byRoot[root]
+		if !ok {
+			c = &MatchCluster{CourseId: m.CourseId, ExamId: m.ExamId, ExamTitle: m.ExamTitle}
+			byRoot[root] = c
+			roots = append(roots, root)
+		}
+		c.Matches = append(c.Matches, m)
+		c.Identical = c.Identical || m.Identical
+		for _, userId := range m.UserIds {
+			if !slices.Contains(c.UserIds, userId) {
+				c.UserIds = append(c.UserIds, userId)
+			}
+		}
+	}
+	clusters := make([]MatchCluster, 0, len(roots))
+	for _, root := range roots {
+		c := byRoot[root]
+		sort.Strings(c.UserIds)
+		clusters = append(clusters, *c)
+	}
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return len(clusters[i].UserIds) > len(clusters[j].UserIds)
+	})
+	return clusters
+}
+
+type SimilarityModel struct {
+	DB *firestore.Client
+}
+
+func (s *SimilarityModel) fingerprints(courseId string) *firestore.CollectionRef {
+	return s.DB.Collection("courses").Doc(courseId).Collection("fingerprints")
+}
+
+func (s *SimilarityModel) matches(courseId string) *firestore.CollectionRef {
+	return s.DB.Collection("courses").Doc(courseId).Collection("answer_matches")
+}
+
+// Check compares the attempt's pages with the exam's earlier uploads, then
+// keeps its fingerprint and the matches it found.
+func (s *SimilarityModel) Check(ctx context.Context, courseId, examTitle string, fp Fingerprint) ([]AnswerMatch, error) {
+	docs, err := s.fingerprints(courseId).Where("exam_id", "==", fp.ExamId).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	earlier := make([]Fingerprint, 0, len(docs))
+	for _, doc := range docs {
+		var other Fingerprint
+		if err := doc.DataTo(&other); err != nil {
+			return nil, err
+		}
+		other.ID = doc.Ref.ID
+		earlier = append(earlier, other)
+	}
+	matches := FindMatches(courseId, examTitle, fp, earlier)
+
+	bw := s.DB.BulkWriter(ctx)
+	defer bw.End()
+	id := fmt.Sprintf("%s_%s_%d", fp.ExamId, fp.UserId, fp.Attempt)
+	_, err = bw.Set(s.fingerprints(courseId).Doc(id), fp)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range matches {
+		_, err = bw.Set(s.matches(courseId).Doc(m.ID), m)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return matches, nil
+}
+
+func (s *SimilarityModel) GetMatch(ctx context.Context, courseId, matchId string) (*AnswerMatch, error) {
+	doc, err := s.matches(courseId).Doc(matchId).Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return toAnswerMatch(doc)
+}
+
+// GetExamMatches returns the matches found among the exam's answers.
+func (s *SimilarityModel) GetExamMatches(ctx context.Context, courseId, examId string) (*[]AnswerMatch, error) {
+	docs, err := s.matches(courseId).Where("exam_id", "==", examId).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	return toAnswerMatches(docs)
+}
+
+// GetAllMatches returns the matches of every exam, newest first.
+func (s *SimilarityModel) GetAllMatches(ctx context.Context) (*[]AnswerMatch, error) {
+	docs, err := s.DB.CollectionGroup("answer_matches").Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	matches, err := toAnswerMatches(docs)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(*matches, func(i, j int) bool {
+		return (*matches)[i].CreatedAt.After((*matches)[j].CreatedAt)
+	})
+	return matches, nil
+}
+
+// DeleteExam drops the exam's fingerprints and matches, used when the exam
+// is deleted.
+func (s *SimilarityModel) DeleteExam(ctx context.Context, courseId, examId string) error {
+	bw := s.DB.BulkWriter(ctx)
+	defer bw.End()
+	for _, col := range []*firestore.CollectionRef{s.fingerprints(courseId), s.matches(courseId)} {
+		docs, err := col.Where("exam_id", "==", examId).Documents(ctx).GetAll()
+		if err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			_, err = bw.Delete(doc.Ref)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func toAnswerMatches(docs []*firestore.DocumentSnapshot) (*[]AnswerMatch, error) {
+	matches := make([]AnswerMatch, 0, len(docs))
+	for _, doc := range docs {
+		m, err := toAnswerMatch(doc)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, *m)
+	}
+	return &matches, nil
+}
+
+func toAnswerMatch(doc *firestore.DocumentSnapshot) (*AnswerMatch, error) {
+	var m AnswerMatch
+	err := doc.DataTo(&m)
+	if err != nil {
+		return nil, err
+	}
+	m.ID = doc.Ref.ID
+	return &m, nil
+}
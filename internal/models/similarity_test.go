@@ -0,0 +1,73 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alghurabi0/rehla/internal/assert"
+)
+
+// hash is a perceptual hash with the first n bits flipped.
+func hash(n int) string {
+	b := []byte(strings.Repeat("0", 64))
+	for i := 0; i < n/4; i++ {
+		b[i] = 'f'
+	}
+	return string(b)
+}
+
+func TestFindMatches(t *testing.T) {
+	earlier := []Fingerprint{
+		{UserId: "amal", ExamId: "e1", Attempt: 1, Pages: []PageHash{{Content: "a1", Perceptual: hash(0)}, {Content: "a2", Perceptual: hash(200)}}},
+		{UserId: "bashir", ExamId: "e1", Attempt: 1, Pages: []PageHash{{Content: "b1", Perceptual: hash(100)}}},
+		{UserId: "dana", ExamId: "e1", Attempt: 2, Pages: []PageHash{{Content: "x", Perceptual: ""}}},
+		// the student's own earlier attempt
+		{UserId: "sara", ExamId: "e1", Attempt: 1, Pages: []PageHash{{Content: "x"}}},
+	}
+	fp := Fingerprint{UserId: "sara", ExamId: "e1", Attempt: 2, Pages: []PageHash{
+		{Content: "s1", Perceptual: hash(200)},
+		{Content: "x"},
+	}}
+	matches := FindMatches("c1", "Exam", fp, earlier)
+	assert.Equal(t, len(matches), 2)
+
+	// a resized photo of amal's second page
+	assert.Equal(t, strings.Join(matches[0].UserIds, ","), "amal,sara")
+	assert.Equal(t, len(matches[0].Pages), 1)
+	assert.Equal(t, matches[0].Pages[0], PagePair{Page: 2, OtherPage: 1, Distance: 0})
+	assert.Equal(t, matches[0].Identical, false)
+	assert.Equal(t, matches[0].ID, "e1_amal_1_sara_2")
+	assert.Equal(t, matches[0].Other("sara"), "amal")
+
+	// the same pdf dana sent
+	assert.Equal(t, strings.Join(matches[1].UserIds, ","), "dana,sara")
+	assert.Equal(t, matches[1].Identical, true)
+	assert.Equal(t, matches[1].Attempts[0], 2)
+
+	near := ComparePages([]PageHash{{Perceptual: hash(0)}}, []PageHash{{Perceptual: hash(24)}})
+	assert.Equal(t, len(near), 1)
+	assert.Equal(t, near[0], PagePair{Page: 1, OtherPage: 1, Distance: 24})
+	assert.Equal(t, len(ComparePages([]PageHash{{Perceptual: hash(0)}}, []PageHash{{Perceptual: hash(28)}})), 0)
+}
+
+func TestClusters(t *testing.T) {
+	match := func(exam, a, b string, identical bool) AnswerMatch {
+		return AnswerMatch{CourseId: "c1", ExamId: exam, UserIds: []string{a, b}, Identical: identical}
+	}
+	clusters := Clusters([]AnswerMatch{
+		match("e1", "amal", "bashir", false),
+		match("e1", "dana", "sara", true),
+		match("e1", "sara", "amal", false),
+		match("e2", "omar", "amal", false),
+		match("e1", "hadi", "zaid", false),
+	})
+	assert.Equal(t, len(clusters), 3)
+	assert.Equal(t, strings.Join(clusters[0].UserIds, ","), "amal,bashir,dana,sara")
+	assert.Equal(t, len(clusters[0].Matches), 3)
+	assert.Equal(t, clusters[0].Identical, true)
+	// the same student in another exam is another cluster
+	assert.Equal(t, clusters[1].ExamId, "e2")
+	assert.Equal(t, strings.Join(clusters[1].UserIds, ","), "amal,omar")
+	assert.Equal(t, strings.Join(clusters[2].UserIds, ","), "hadi,zaid")
+	assert.Equal(t, clusters[2].Identical, false)
+}
@@ -2,17 +2,21 @@ package models
 
 import (
 	"context"
+	"time"
 
 	"cloud.google.com/go/firestore"
 	"google.golang.org/api/iterator"
 )
 
 type Subscription struct {
-	ID          string    `firestore:"-"`
-	UserId      string    `firestore:"-"`
-	CourseTitle string    `firestore:"course_title"`
-	Active      bool      `firestore:"active"`
-	Answers     *[]Answer `firestore:"-"`
+	ID          string `firestore:"-"`
+	UserId      string `firestore:"-"`
+	CourseTitle string `firestore:"course_title"`
+	Active      bool   `firestore:"active"`
+	// drip courses count their days from here, older subscriptions from
+	// their first payment
+	StartedAt time.Time `firestore:"started_at"`
+	Answers   *[]Answer `firestore:"-"`
 }
 
 type SubscriptionModel struct {
@@ -83,3 +87,27 @@ func (s *SubscriptionModel) IsActive(ctx context.Context, userId, subId string)
 	}
 	return false
 }
+
+// StartedAt returns when the user's subscription to the course started, the
+// first payment for subscriptions from before it was kept. Zero when there's
+// neither.
+func (s *SubscriptionModel) StartedAt(ctx context.Context, userId, courseId string) (time.Time, error) {
+	sub, err := s.Get(ctx, userId, courseId)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !sub.StartedAt.IsZero() {
+		return sub.StartedAt, nil
+	}
+	docs, err := s.DB.Collection("users").Doc(userId).Collection("subs").Doc(courseId).Collection("payments").
+		OrderBy("date_of_payment", firestore.Asc).Limit(1).Documents(ctx).GetAll()
+	if err != nil || len(docs) == 0 {
+		return time.Time{}, err
+	}
+	var payment Payment
+	err = docs[0].DataTo(&payment)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return payment.DateOfPayment, nil
+}
@@ -23,6 +23,8 @@ type User struct {
 	ImgPath       string   `firestore:"img_path"`
 	NumSubs       int      `firestore:"-"`
 	SessionId     string   `firestore:"session_id"`
+	// notification kinds the user opted out of
+	MutedNotifications []string `firestore:"muted_notifications"`
 }
 
 type UserModel struct {
@@ -67,6 +69,29 @@ func (u *UserModel) GetAll(ctx context.Context, offset int) (*[]User, error) {
 	return &users, nil
 }
 
+// GetByParentPhone returns every user whose parent_phone_number matches phone.
+func (u *UserModel) GetByParentPhone(ctx context.Context, phone string) (*[]User, error) {
+	usersIter := u.DB.Collection("users").Where("parent_phone_number", "==", phone).Documents(ctx)
+	var users []User
+	for {
+		doc, err := usersIter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var user User
+		if err := doc.DataTo(&user); err != nil {
+			return nil, err
+		}
+		user.ID = doc.Ref.ID
+		user.NumSubs = len(user.Subscriptions)
+		users = append(users, user)
+	}
+	return &users, nil
+}
+
 func (u *UserModel) Create(ctx context.Context, user *User) (string, error) {
 	doc, _, err := u.DB.Collection("users").Add(ctx, user)
 	if err != nil {
@@ -83,6 +108,39 @@ func (u *UserModel) Update(ctx context.Context, userId string, updates []firesto
 	return nil
 }
 
+// GetSubscribed returns the users that have courseId in their subscriptions.
+func (u *UserModel) GetSubscribed(ctx context.Context, courseId string) (*[]User, error) {
+	usersIter := u.DB.Collection("users").Where("subscriptions", "array-contains", courseId).Documents(ctx)
+	var users []User
+	for {
+		doc, err := usersIter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var user User
+		if err := doc.DataTo(&user); err != nil {
+			return nil, err
+		}
+		user.ID = doc.Ref.ID
+		user.NumSubs = len(user.Subscriptions)
+		users = append(users, user)
+	}
+	return &users, nil
+}
+
+// IsMuted reports whether the user opted out of notifications of this kind.
+func (u *User) IsMuted(kind string) bool {
+	for _, muted := range u.MutedNotifications {
+		if muted == kind {
+			return true
+		}
+	}
+	return false
+}
+
 func DeleteAll(ctx context.Context, docRef *firestore.DocumentRef) error {
 	subcollections := docRef.Collections(ctx)
 	for {
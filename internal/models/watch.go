@@ -0,0 +1,220 @@
+package models
+
+import (
+	"context"
+	"math"
+	"math/bits"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+// WatchBucket is how many seconds of a lecture each bit of watch progress
+// stands for.
+const WatchBucket = 5
+
+// MaxPlaybackRate is the fastest the player plays, heartbeats that don't
+// report their rate are allowed to move this fast.
+const MaxPlaybackRate = 2.0
+
+// MaxLecDuration bounds the positions players report, in seconds.
+const MaxLecDuration = 12 * 60 * 60
+
+const (
+	// a lecture counts as finished once this much of it was watched
+	finishedPercent = 90
+	// students who stopped this close to the end start over next time
+	endMargin = 15
+)
+
+// Heartbeat is a sample of the lecture player, positions are in seconds.
+type Heartbeat struct {
+	Position float64 `json:"position"`
+	Duration float64 `json:"duration"`
+	Rate     float64 `json:"rate"`
+	// seconds since the previous heartbeat of the batch
+	Elapsed float64 `json:"elapsed"`
+}
+
+// Valid reports whether the heartbeat could come from a playing lecture.
+func (h Heartbeat) Valid() bool {
+	if h.Position < 0 || h.Position > MaxLecDuration || h.Duration < 0 || h.Duration > MaxLecDuration {
+		return false
+	}
+	// the duration is 0 until the player loaded it
+	if h.Duration > 0 && h.Position > h.Duration+1 {
+		return false
+	}
+	return h.Elapsed >= 0 && h.Rate >= 0
+}
+
+// WatchedBuckets returns the buckets the heartbeats played through, in
+// order. Only playback between consecutive heartbeats counts, a jump further
+// than the player could play in the time between them was a seek.
+func WatchedBuckets(beats []Heartbeat) []int {
+	seen := map[int]bool{}
+	for i := 1; i < len(beats); i++ {
+		prev, cur := beats[i-1], beats[i]
+		played := cur.Position - prev.Position
+		if played <= 0 || cur.Elapsed <= 0 {
+			continue
+		}
+		rate := cur.Rate
+		if rate <= 0 || rate > MaxPlaybackRate {
+			rate = MaxPlaybackRate
+		}
+		// a second of slack for the timers of the player and the page
+		if played > cur.Elapsed*rate+1 {
+			continue
+		}
+		end := cur.Position
+		if cur.Duration > 0 {
+			end = min(end, cur.Duration)
+		}
+		for b := int(prev.Position / WatchBucket); b < int(math.Ceil(end/WatchBucket)); b++ {
+			seen[b] = true
+		}
+	}
+	buckets := make([]int, 0, len(seen))
+	for b := range seen {
+		buckets = append(buckets, b)
+	}
+	sort.Ints(buckets)
+	return buckets
+}
+
+// WatchProgress is how much of a lecture a student watched and where they
+// left off.
+type WatchProgress struct {
+	UserId   string `firestore:"user_id"`
+	CourseId string `firestore:"course_id"`
+	LecId    string `firestore:"lec_id"`
+	// seconds
+	Position float64 `firestore:"position"`
+	Duration float64 `firestore:"duration"`
+	// one bit per WatchBucket seconds, the first bucket is the high bit of
+	// the first byte like in redis bitmaps
+	Watched   []byte    `firestore:"watched"`
+	UpdatedAt time.Time `firestore:"updated_at"`
+}
+
+// Mark sets the bucket as watched.
+func (p *WatchProgress) Mark(bucket int) {
+	for len(p.Watched) <= bucket/8 {
+		p.Watched = append(p.Watched, 0)
+	}
+	p.Watched[bucket/8] |= 1 << (7 - bucket%8)
+}
+
+// Percent is how much of the lecture was watched, out of 100.
+func (p *WatchProgress) Percent() float64 {
+	total := int((p.Duration + WatchBucket - 1) / WatchBucket)
+	if total <= 0 {
+		return 0
+	}
+	watched := 0
+	for i, b := range p.Watched {
+		if i*8 >= total {
+			break
+		}
+		// buckets past the end of the lecture don't count
+		if left := total - i*8; left < 8 {
+			b &= 0xff << (8 - left)
+		}
+		watched += bits.OnesCount8(b)
+	}
+	return float64(watched) * 100 / float64(total)
+}
+
+// Finished reports whether the student watched most of the lecture.
+func (p *WatchProgress) Finished() bool {
+	return p.Percent() >= finishedPercent
+}
+
+// ResumeAt is the second the player picks up from.
+func (p *WatchProgress) ResumeAt() int {
+	if p.Duration > 0 && p.Position >= p.Duration-endMargin {
+		return 0
+	}
+	return int(p.Position)
+}
+
+// ApplyWatchProgress sets how much of each lecture the student watched and
+// returns how much of the course they watched, every lecture counts the
+// same. When a lecture has more than one progress the last one counts.
+func ApplyWatchProgress(lecs []Lec, progress []WatchProgress) float64 {
+	byLec := make(map[string]WatchProgress, len(progress))
+	for _, p := range progress {
+		byLec[p.LecId] = p
+	}
+	var watched float64
+	for i := range lecs {
+		p, ok := byLec[lecs[i].ID]
+		if ok {
+			lecs[i].Watched = p.Percent()
+			watched += lecs[i].Watched
+		}
+	}
+	if len(lecs) == 0 {
+		return 0
+	}
+	return watched / float64(len(lecs))
+}
+
+type WatchModel struct {
+	DB *firestore.Client
+}
+
+func (s *WatchModel) col(userId, courseId string) *firestore.CollectionRef {
+	return s.DB.Collection("users").Doc(userId).Collection("subs").Doc(courseId).Collection("watch")
+}
+
+// Get returns the student's progress in the lecture, nil when they never
+// played it.
+func (s *WatchModel) Get(ctx context.Context, userId, courseId, lecId string) (*WatchProgress, error) {
+	doc, err := s.col(userId, courseId).Doc(lecId).Get(ctx)
+	if doc != nil && !doc.Exists() {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var p WatchProgress
+	err = doc.DataTo(&p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetCourse returns the student's progress in every lecture of the course
+// they played.
+func (s *WatchModel) GetCourse(ctx context.Context, userId, courseId string) (*[]WatchProgress, error) {
+	docs, err := s.col(userId, courseId).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	progress := make([]WatchProgress, 0, len(docs))
+	for _, doc := range docs {
+		var p WatchProgress
+		if err := doc.DataTo(&p); err != nil {
+			return nil, err
+		}
+		progress = append(progress, p)
+	}
+	return &progress, nil
+}
+
+// SaveAll writes the progress flushed from the buffer.
+func (s *WatchModel) SaveAll(ctx context.Context, progress []WatchProgress) error {
+	bw := s.DB.BulkWriter(ctx)
+	defer bw.End()
+	for _, p := range progress {
+		_, err := bw.Set(s.col(p.UserId, p.CourseId).Doc(p.LecId), p)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
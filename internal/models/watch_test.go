@@ -0,0 +1,91 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alghurabi0/rehla/internal/assert"
+)
+
+func TestWatchedBuckets(t *testing.T) {
+	beats := []Heartbeat{
+		{Position: 0, Duration: 100},
+		{Position: 5, Duration: 100, Elapsed: 5},
+		{Position: 11, Duration: 100, Elapsed: 5, Rate: 1.25},
+		// skipped ahead
+		{Position: 60, Duration: 100, Elapsed: 5, Rate: 1},
+		{Position: 64, Duration: 100, Elapsed: 5, Rate: 1},
+		// went back
+		{Position: 30, Duration: 100, Elapsed: 5},
+	}
+	assert.Equal(t, fmt.Sprint(WatchedBuckets(beats)), "[0 1 2 12]")
+
+	// without a rate the player may have played twice as fast
+	fast := []Heartbeat{{Position: 0}, {Position: 10, Elapsed: 5}}
+	assert.Equal(t, fmt.Sprint(WatchedBuckets(fast)), "[0 1]")
+	fast[1].Position = 12
+	assert.Equal(t, len(WatchedBuckets(fast)), 0)
+
+	assert.Equal(t, len(WatchedBuckets(beats[:1])), 0)
+}
+
+func TestWatchProgress(t *testing.T) {
+	p := WatchProgress{Duration: 52}
+	for _, b := range []int{0, 1, 2, 10} {
+		p.Mark(b)
+	}
+	assert.Equal(t, len(p.Watched), 2)
+	assert.Equal(t, p.Watched[0], byte(0xe0))
+	// 11 buckets, the last is 2 seconds long
+	assert.Equal(t, fmt.Sprintf("%.1f", p.Percent()), "36.4")
+	assert.Equal(t, p.Finished(), false)
+
+	// buckets past the end don't count
+	p.Mark(11)
+	assert.Equal(t, fmt.Sprintf("%.1f", p.Percent()), "36.4")
+	for b := 3; b < 10; b++ {
+		p.Mark(b)
+	}
+	assert.Equal(t, p.Percent(), 100.0)
+	assert.Equal(t, p.Finished(), true)
+
+	p.Position = 30
+	assert.Equal(t, p.ResumeAt(), 30)
+	p.Position = 40
+	assert.Equal(t, p.ResumeAt(), 0)
+
+	assert.Equal(t, (&WatchProgress{}).Percent(), 0.0)
+}
+
+func TestApplyWatchProgress(t *testing.T) {
+	lecs := []Lec{{ID: "l1"}, {ID: "l2"}, {ID: "l3"}, {ID: "l4"}}
+	full := WatchProgress{LecId: "l1", Duration: 10, Watched: []byte{0xc0}}
+	half := WatchProgress{LecId: "l2", Duration: 20, Watched: []byte{0xc0}}
+	stale := WatchProgress{LecId: "l2", Duration: 20}
+	course := ApplyWatchProgress(lecs, []WatchProgress{full, stale, half})
+	assert.Equal(t, lecs[0].Watched, 100.0)
+	assert.Equal(t, lecs[1].Watched, 50.0)
+	assert.Equal(t, lecs[2].Watched, 0.0)
+	assert.Equal(t, course, 37.5)
+	assert.Equal(t, ApplyWatchProgress(nil, []WatchProgress{full}), 0.0)
+}
+
+func TestHeartbeatValid(t *testing.T) {
+	tests := []struct {
+		name string
+		beat Heartbeat
+		want bool
+	}{
+		{"playing", Heartbeat{Position: 30, Duration: 600, Elapsed: 5, Rate: 1}, true},
+		{"duration not loaded", Heartbeat{Position: 3}, true},
+		{"past the end", Heartbeat{Position: 700, Duration: 600}, false},
+		{"negative", Heartbeat{Position: -1, Duration: 600}, false},
+		{"too long", Heartbeat{Position: 1, Duration: MaxLecDuration + 1}, false},
+		{"negative elapsed", Heartbeat{Position: 1, Elapsed: -5}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.beat.Valid(), tt.want)
+		})
+	}
+}
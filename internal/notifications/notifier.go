@@ -0,0 +1,221 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alghurabi0/rehla/internal/models"
+)
+
+// notification kinds, users can opt out of each one separately
+const (
+	KindExamCorrected        = "exam_corrected"
+	KindNewLecture           = "new_lecture"
+	KindSubscriptionExpiring = "subscription_expiring"
+	KindSubscriptionActive   = "subscription_active"
+	KindNewMaterial          = "new_material"
+	KindPaymentRecorded      = "payment_recorded"
+	KindInquiryReply         = "inquiry_reply"
+	KindCertificateIssued    = "certificate_issued"
+	KindNewExam              = "new_exam"
+)
+
+var Kinds = []string{
+	KindExamCorrected,
+	KindNewLecture,
+	KindSubscriptionExpiring,
+	KindSubscriptionActive,
+	KindNewMaterial,
+	KindPaymentRecorded,
+	KindInquiryReply,
+	KindCertificateIssued,
+	KindNewExam,
+}
+
+// Notifier is the single entry point for student facing events. Every
+// message is stored in the user's inbox, then pushed to their devices unless
+// the user muted that kind of notification.
+type Notifier struct {
+	Sender  Sender
+	Users   *models.UserModel
+	Devices *models.DeviceModel
+	Inbox   *models.NotificationModel
+}
+
+func (n *Notifier) Notify(ctx context.Context, userId string, msg Message) error {
+	user, err := n.Users.Get(ctx, userId)
+	if err != nil {
+		return err
+	}
+	return n.notify(ctx, user, msg)
+}
+
+// NotifyAll sends msg to every user, it keeps going when one of them fails
+// and returns the last error.
+func (n *Notifier) NotifyAll(ctx context.Context, users []models.User, msg Message) error {
+	var lastErr error
+	for i := range users {
+		err := n.notify(ctx, &users[i], msg)
+		if err != nil {
+			lastErr = fmt.Errorf("user %s: %v", users[i].ID, err)
+		}
+	}
+	return lastErr
+}
+
+func (n *Notifier) notify(ctx context.Context, user *models.User, msg Message) error {
+	_, err := n.Inbox.Create(ctx, user.ID, &models.Notification{
+		Kind:      msg.Kind,
+		Title:     msg.Title,
+		Body:      msg.Body,
+		Link:      msg.Link,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if user.IsMuted(msg.Kind) {
+		return nil
+	}
+	devices, err := n.Devices.GetAll(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	stale, err := n.deliver(ctx, user, *devices, msg)
+	for _, deviceId := range stale {
+		delErr := n.Devices.Delete(ctx, user.ID, deviceId)
+		if delErr != nil && err == nil {
+			err = delErr
+		}
+	}
+	return err
+}
+
+// deliver sends msg to the devices and returns the ids of the devices whose
+// tokens are no longer valid.
+func (n *Notifier) deliver(ctx context.Context, user *models.User, devices []models.Device, msg Message) ([]string, error) {
+	if user.IsMuted(msg.Kind) || len(devices) == 0 {
+		return nil, nil
+	}
+	tokens := make([]string, 0, len(devices))
+	deviceByToken := make(map[string]string, len(devices))
+	for _, device := range devices {
+		tokens = append(tokens, device.Token)
+		deviceByToken[device.Token] = device.ID
+	}
+	staleTokens, err := n.Sender.Send(ctx, tokens, msg)
+	var stale []string
+	for _, token := range staleTokens {
+		if deviceId, ok := deviceByToken[token]; ok {
+			stale = append(stale, deviceId)
+		}
+	}
+	return stale, err
+}
+
+func ExamCorrected(courseId, examId, examTitle string, grade, maxGrade int, notes string) Message {
+	body := fmt.Sprintf("تم تصحيح %s, درجتك %d", examTitle, grade)
+	if maxGrade > 0 {
+		body = fmt.Sprintf("%s من %d", body, maxGrade)
+	}
+	if notes != "" {
+		body = fmt.Sprintf("%s\n%s", body, notes)
+	}
+	return Message{
+		Kind:  KindExamCorrected,
+		Title: "تم تصحيح الامتحان",
+		Body:  body,
+		Link:  fmt.Sprintf("/progress/%s/%s", courseId, examId),
+	}
+}
+
+func NewLecture(courseId, lecId, courseTitle, lecTitle string) Message {
+	return Message{
+		Kind:  KindNewLecture,
+		Title: fmt.Sprintf("محاضرة جديدة في %s", courseTitle),
+		Body:  lecTitle,
+		Link:  fmt.Sprintf("/courses/%s/lec/%s", courseId, lecId),
+	}
+}
+
+func SubscriptionExpiring(courseId, courseTitle string, validUntil time.Time) Message {
+	return Message{
+		Kind:  KindSubscriptionExpiring,
+		Title: "اشتراكك على وشك الانتهاء",
+		Body:  fmt.Sprintf("ينتهي اشتراكك في %s بتاريخ %s", courseTitle, validUntil.Format("2006-01-02")),
+		Link:  fmt.Sprintf("/mycourses/%s", courseId),
+	}
+}
+
+func SubscriptionActive(courseId, courseTitle string) Message {
+	return Message{
+		Kind:  KindSubscriptionActive,
+		Title: "تم تفعيل اشتراكك",
+		Body:  fmt.Sprintf("تم تفعيل اشتراكك في %s", courseTitle),
+		Link:  fmt.Sprintf("/courses/%s", courseId),
+	}
+}
+
+func NewMaterial(courseId, courseTitle, materialTitle string) Message {
+	return Message{
+		Kind:  KindNewMaterial,
+		Title: fmt.Sprintf("ملزمة جديدة في %s", courseTitle),
+		Body:  materialTitle,
+		Link:  fmt.Sprintf("/materials/%s", courseId),
+	}
+}
+
+func NewExam(courseId, examId, courseTitle, examTitle string) Message {
+	return Message{
+		Kind:  KindNewExam,
+		Title: fmt.Sprintf("اختبار جديد في %s", courseTitle),
+		Body:  examTitle,
+		Link:  fmt.Sprintf("/courses/%s/exam/%s", courseId, examId),
+	}
+}
+
+func PaymentRecorded(courseId, courseTitle string, amount int, validUntil time.Time) Message {
+	return Message{
+		Kind:  KindPaymentRecorded,
+		Title: "تم تسجيل دفعة",
+		Body:  fmt.Sprintf("تم تسجيل %d دينار لـ %s, صالحة حتى %s", amount, courseTitle, validUntil.Format("2006-01-02")),
+		Link:  fmt.Sprintf("/payments/%s", courseId),
+	}
+}
+
+func InquiryReply(inquiryId, body string) Message {
+	return Message{
+		Kind:  KindInquiryReply,
+		Title: "رد جديد من الدعم",
+		Body:  body,
+		Link:  fmt.Sprintf("/contact/%s", inquiryId),
+	}
+}
+
+func CertificateIssued(courseId, courseTitle string) Message {
+	return Message{
+		Kind:  KindCertificateIssued,
+		Title: "حصلت على شهادة إتمام",
+		Body:  fmt.Sprintf("أتممت %s, شهادتك جاهزة للتحميل", courseTitle),
+		Link:  fmt.Sprintf("/courses/%s", courseId),
+	}
+}
+
+// AppealDecided tells the student how their appeal went, it's muted along
+// with exam corrections.
+func AppealDecided(courseId, examId, examTitle, status string, grade, maxGrade int) Message {
+	body := fmt.Sprintf("تمت مراجعة درجة %s ولم تتغير", examTitle)
+	if status == models.AppealRegraded {
+		body = fmt.Sprintf("تمت مراجعة درجة %s, درجتك الجديدة %d", examTitle, grade)
+		if maxGrade > 0 {
+			body = fmt.Sprintf("%s من %d", body, maxGrade)
+		}
+	}
+	return Message{
+		Kind:  KindExamCorrected,
+		Title: "تم البت في طلب المراجعة",
+		Body:  body,
+		Link:  fmt.Sprintf("/progress/%s/%s", courseId, examId),
+	}
+}
@@ -0,0 +1,52 @@
+package notifications
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alghurabi0/rehla/internal/assert"
+	"github.com/alghurabi0/rehla/internal/models"
+)
+
+func TestDeliver(t *testing.T) {
+	devices := []models.Device{
+		{ID: "phone", Token: "token-1"},
+		{ID: "laptop", Token: "token-2"},
+	}
+	msg := ExamCorrected("c1", "e1", "الامتحان الاول", 8, 10, "")
+
+	t.Run("sends to every device", func(t *testing.T) {
+		sender := &FakeSender{Stale: []string{"token-2"}}
+		n := &Notifier{Sender: sender}
+		stale, err := n.deliver(context.Background(), &models.User{}, devices, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, len(sender.Sent), 1)
+		assert.Equal(t, len(sender.Sent[0].Tokens), 2)
+		assert.Equal(t, sender.Sent[0].Message.Kind, KindExamCorrected)
+		assert.Equal(t, len(stale), 1)
+		assert.Equal(t, stale[0], "laptop")
+	})
+
+	t.Run("skips muted kinds", func(t *testing.T) {
+		sender := &FakeSender{}
+		n := &Notifier{Sender: sender}
+		user := &models.User{MutedNotifications: []string{KindExamCorrected}}
+		_, err := n.deliver(context.Background(), user, devices, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, len(sender.Sent), 0)
+	})
+
+	t.Run("skips users without devices", func(t *testing.T) {
+		sender := &FakeSender{}
+		n := &Notifier{Sender: sender}
+		_, err := n.deliver(context.Background(), &models.User{}, nil, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, len(sender.Sent), 0)
+	})
+}
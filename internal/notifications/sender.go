@@ -0,0 +1,87 @@
+package notifications
+
+import (
+	"context"
+	"sync"
+
+	"firebase.google.com/go/messaging"
+)
+
+// Message is a single push notification, independent of the transport.
+type Message struct {
+	Kind  string `json:"kind"`
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	// Link is opened when the notification is clicked
+	Link string `json:"link"`
+}
+
+// Sender delivers a message to a set of device tokens. It returns the tokens
+// that are no longer registered so the caller can forget them.
+type Sender interface {
+	Send(ctx context.Context, tokens []string, msg Message) ([]string, error)
+}
+
+// FCMSender sends through Firebase Cloud Messaging.
+type FCMSender struct {
+	Client *messaging.Client
+}
+
+// fcm accepts up to 500 tokens per multicast request
+const maxTokensPerRequest = 500
+
+func (s *FCMSender) Send(ctx context.Context, tokens []string, msg Message) ([]string, error) {
+	var stale []string
+	for start := 0; start < len(tokens); start += maxTokensPerRequest {
+		end := min(start+maxTokensPerRequest, len(tokens))
+		batch := tokens[start:end]
+		res, err := s.Client.SendMulticast(ctx, &messaging.MulticastMessage{
+			Tokens: batch,
+			Data: map[string]string{
+				"kind": msg.Kind,
+			},
+			Notification: &messaging.Notification{
+				Title: msg.Title,
+				Body:  msg.Body,
+			},
+			Webpush: &messaging.WebpushConfig{
+				FcmOptions: &messaging.WebpushFcmOptions{
+					Link: msg.Link,
+				},
+			},
+		})
+		if err != nil {
+			return stale, err
+		}
+		for i, resp := range res.Responses {
+			if resp.Error != nil && messaging.IsRegistrationTokenNotRegistered(resp.Error) {
+				stale = append(stale, batch[i])
+			}
+		}
+	}
+	return stale, nil
+}
+
+// FakeSender records messages instead of sending them, for tests.
+type FakeSender struct {
+	mu   sync.Mutex
+	Sent []FakeDelivery
+	// Stale is returned from every Send call
+	Stale []string
+	Err   error
+}
+
+type FakeDelivery struct {
+	Tokens  []string
+	Message Message
+}
+
+func (s *FakeSender) Send(ctx context.Context, tokens []string, msg Message) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	s.Sent = append(s.Sent, FakeDelivery{Tokens: tokens, Message: msg})
+	return s.Stale, nil
+}
@@ -0,0 +1,116 @@
+// Package pagehash fingerprints uploaded answer pages so copies can be found.
+// The content hash finds the same file sent twice, the perceptual hash finds
+// the same photo after it was resized or recompressed by a messaging app.
+package pagehash
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"math/bits"
+)
+
+// the perceptual hash compares the brightness of neighbouring cells in a
+// size x size grid, 256 bits
+const size = 16
+
+// pages whose cells hardly differ in brightness are blank, their hashes
+// would match every other blank page
+const minContrast = 4.0
+
+// larger images are only hashed by content, like pdfs
+const maxPixels = 50_000_000
+
+// Page is the fingerprint of one uploaded file. Perceptual is empty for pdfs
+// and blank pages.
+type Page struct {
+	Content    string
+	Perceptual string
+}
+
+// Hash fingerprints an uploaded file.
+func Hash(data []byte) Page {
+	sum := sha256.Sum256(data)
+	page := Page{Content: hex.EncodeToString(sum[:])}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width*cfg.Height > maxPixels {
+		return page
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return page
+	}
+	page.Perceptual = perceptual(img)
+	return page
+}
+
+// perceptual is a difference hash, each bit says whether a cell of the
+// image is brighter than the one to its right.
+func perceptual(img image.Image) string {
+	grid := shrink(img, size+1, size)
+	var mean, variance float64
+	for _, v := range grid {
+		mean += v
+	}
+	mean /= float64(len(grid))
+	for _, v := range grid {
+		variance += (v - mean) * (v - mean)
+	}
+	if math.Sqrt(variance/float64(len(grid))) < minContrast {
+		return ""
+	}
+	hash := make([]byte, size*size/8)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if grid[y*(size+1)+x] > grid[y*(size+1)+x+1] {
+				i := y*size + x
+				hash[i/8] |= 1 << (7 - i%8)
+			}
+		}
+	}
+	return hex.EncodeToString(hash)
+}
+
+// shrink averages the brightness of the image over a w x h grid. Big photos
+// are sampled rather than read pixel by pixel.
+func shrink(img image.Image, w, h int) []float64 {
+	b := img.Bounds()
+	step := max(1, min(b.Dx(), b.Dy())/(h*16))
+	sums := make([]float64, w*h)
+	counts := make([]float64, w*h)
+	for y := b.Min.Y; y < b.Max.Y; y += step {
+		gy := (y - b.Min.Y) * h / b.Dy()
+		for x := b.Min.X; x < b.Max.X; x += step {
+			gx := (x - b.Min.X) * w / b.Dx()
+			r, g, bl, _ := img.At(x, y).RGBA()
+			// luma out of 255
+			sums[gy*w+gx] += (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)) / 257
+			counts[gy*w+gx]++
+		}
+	}
+	for i := range sums {
+		if counts[i] > 0 {
+			sums[i] /= counts[i]
+		}
+	}
+	return sums
+}
+
+// Distance is how many bits two perceptual hashes differ in, false when
+// either page has none.
+func Distance(a, b string) (int, bool) {
+	ha, errA := hex.DecodeString(a)
+	hb, errB := hex.DecodeString(b)
+	if errA != nil || errB != nil || len(ha) == 0 || len(ha) != len(hb) {
+		return 0, false
+	}
+	d := 0
+	for i := range ha {
+		d += bits.OnesCount8(ha[i] ^ hb[i])
+	}
+	return d, true
+}
@@ -0,0 +1,90 @@
+package pagehash
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/alghurabi0/rehla/internal/assert"
+)
+
+// page draws a sheet with dark strokes where ink(x, y) says so.
+func page(w, h int, ink func(x, y int) bool) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.Gray{Y: 235}
+			if ink(x, y) {
+				c = color.Gray{Y: 30}
+			}
+			img.SetGray(x, y, c)
+		}
+	}
+	return img
+}
+
+// half scales img down by averaging every 2x2 block, like a messaging app
+// shrinking a photo.
+func half(img *image.Gray) *image.Gray {
+	b := img.Bounds()
+	out := image.NewGray(image.Rect(0, 0, b.Dx()/2, b.Dy()/2))
+	for y := 0; y < b.Dy()/2; y++ {
+		for x := 0; x < b.Dx()/2; x++ {
+			sum := int(img.GrayAt(2*x, 2*y).Y) + int(img.GrayAt(2*x+1, 2*y).Y) +
+				int(img.GrayAt(2*x, 2*y+1).Y) + int(img.GrayAt(2*x+1, 2*y+1).Y)
+			out.SetGray(x, y, color.Gray{Y: uint8(sum / 4)})
+		}
+	}
+	return out
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 40}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestHash(t *testing.T) {
+	// lines of handwriting of different lengths
+	solved := func(x, y int) bool { return y%40 < 12 && x < 60+(y/40*137)%300 }
+	other := func(x, y int) bool { return (x/30+y/45)%3 == 0 }
+
+	original := Hash(encodePNG(t, page(400, 560, solved)))
+	assert.Equal(t, len(original.Content), 64)
+	assert.Equal(t, len(original.Perceptual), 64)
+
+	// the same file has the same content hash
+	assert.Equal(t, Hash(encodePNG(t, page(400, 560, solved))).Content, original.Content)
+
+	// a smaller recompressed copy only matches perceptually
+	shared := Hash(encodeJPEG(t, half(page(400, 560, solved))))
+	assert.Equal(t, shared.Content == original.Content, false)
+	d, ok := Distance(original.Perceptual, shared.Perceptual)
+	assert.Equal(t, ok, true)
+	assert.Equal(t, d < 26, true)
+
+	d, _ = Distance(original.Perceptual, Hash(encodePNG(t, page(400, 560, other))).Perceptual)
+	assert.Equal(t, d > 60, true)
+
+	blank := Hash(encodePNG(t, page(400, 560, func(x, y int) bool { return false })))
+	assert.Equal(t, blank.Perceptual, "")
+	_, ok = Distance(blank.Perceptual, original.Perceptual)
+	assert.Equal(t, ok, false)
+
+	pdf := Hash([]byte("%PDF-1.4\n%%EOF"))
+	assert.Equal(t, len(pdf.Content), 64)
+	assert.Equal(t, pdf.Perceptual, "")
+}
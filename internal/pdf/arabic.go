@@ -0,0 +1,222 @@
+package pdf
+
+import "unicode"
+
+// arabicForms holds the presentation forms of the Arabic letters, isolated
+// first. Letters that only join the letter before them have two forms, the
+// others four: isolated, final, initial and medial.
+var arabicForms = map[rune][]rune{
+	0x0621: {0xfe80},
+	0x0622: {0xfe81, 0xfe82},
+	0x0623: {0xfe83, 0xfe84},
+	0x0624: {0xfe85, 0xfe86},
+	0x0625: {0xfe87, 0xfe88},
+	0x0626: {0xfe89, 0xfe8a, 0xfe8b, 0xfe8c},
+	0x0627: {0xfe8d, 0xfe8e},
+	0x0628: {0xfe8f, 0xfe90, 0xfe91, 0xfe92},
+	0x0629: {0xfe93, 0xfe94},
+	0x062a: {0xfe95, 0xfe96, 0xfe97, 0xfe98},
+	0x062b: {0xfe99, 0xfe9a, 0xfe9b, 0xfe9c},
+	0x062c: {0xfe9d, 0xfe9e, 0xfe9f, 0xfea0},
+	0x062d: {0xfea1, 0xfea2, 0xfea3, 0xfea4},
+	0x062e: {0xfea5, 0xfea6, 0xfea7, 0xfea8},
+	0x062f: {0xfea9, 0xfeaa},
+	0x0630: {0xfeab, 0xfeac},
+	0x0631: {0xfead, 0xfeae},
+	0x0632: {0xfeaf, 0xfeb0},
+	0x0633: {0xfeb1, 0xfeb2, 0xfeb3, 0xfeb4},
+	0x0634: {0xfeb5, 0xfeb6, 0xfeb7, 0xfeb8},
+	0x0635: {0xfeb9, 0xfeba, 0xfebb, 0xfebc},
+	0x0636: {0xfebd, 0xfebe, 0xfebf, 0xfec0},
+	0x0637: {0xfec1, 0xfec2, 0xfec3, 0xfec4},
+	0x0638: {0xfec5, 0xfec6, 0xfec7, 0xfec8},
+	0x0639: {0xfec9, 0xfeca, 0xfecb, 0xfecc},
+	0x063a: {0xfecd, 0xfece, 0xfecf, 0xfed0},
+	// tatweel joins on both sides and looks the same in all of them
+	0x0640: {0x0640, 0x0640, 0x0640, 0x0640},
+	0x0641: {0xfed1, 0xfed2, 0xfed3, 0xfed4},
+	0x0642: {0xfed5, 0xfed6, 0xfed7, 0xfed8},
+	0x0643: {0xfed9, 0xfeda, 0xfedb, 0xfedc},
+	0x0644: {0xfedd, 0xfede, 0xfedf, 0xfee0},
+	0x0645: {0xfee1, 0xfee2, 0xfee3, 0xfee4},
+	0x0646: {0xfee5, 0xfee6, 0xfee7, 0xfee8},
+	0x0647: {0xfee9, 0xfeea, 0xfeeb, 0xfeec},
+	0x0648: {0xfeed, 0xfeee},
+	0x0649: {0xfeef, 0xfef0},
+	0x064a: {0xfef1, 0xfef2, 0xfef3, 0xfef4},
+}
+
+// lam followed by an alef is written as one ligature, isolated and final
+var lamAlef = map[rune][]rune{
+	0x0622: {0xfef5, 0xfef6},
+	0x0623: {0xfef7, 0xfef8},
+	0x0625: {0xfef9, 0xfefa},
+	0x0627: {0xfefb, 0xfefc},
+}
+
+const lam = 0x0644
+
+const (
+	isolated = iota
+	final
+	initial
+	medial
+)
+
+// isHaraka reports whether r is a vowel mark. They are left out, the
+// presentation forms have no room for them and names rarely carry them.
+func isHaraka(r rune) bool {
+	return r >= 0x064b && r <= 0x065f || r == 0x0670
+}
+
+// joinsBoth reports whether the letter connects to the letter after it.
+func joinsBoth(r rune) bool {
+	return len(arabicForms[r]) == 4
+}
+
+// shapeArabic replaces the Arabic letters of text with the presentation
+// form their neighbours call for, still in logical order.
+func shapeArabic(text []rune) []rune {
+	var letters []rune
+	for _, r := range text {
+		if !isHaraka(r) {
+			letters = append(letters, r)
+		}
+	}
+	out := make([]rune, 0, len(letters))
+	for i := 0; i < len(letters); i++ {
+		r := letters[i]
+		forms, ok := arabicForms[r]
+		if !ok {
+			out = append(out, r)
+			continue
+		}
+		// hamza joins neither side
+		joinsPrev := i > 0 && joinsBoth(letters[i-1]) && len(forms) > 1
+		if r == lam && i+1 < len(letters) {
+			if lig, ok := lamAlef[letters[i+1]]; ok {
+				if joinsPrev {
+					out = append(out, lig[final])
+				} else {
+					out = append(out, lig[isolated])
+				}
+				i++
+				continue
+			}
+		}
+		joinsNext := joinsBoth(r) && len(arabicForms[safeAt(letters, i+1)]) > 1
+		form := isolated
+		switch {
+		case joinsPrev && joinsNext:
+			form = medial
+		case joinsPrev:
+			form = final
+		case joinsNext:
+			form = initial
+		}
+		out = append(out, forms[form])
+	}
+	return out
+}
+
+func safeAt(runes []rune, i int) rune {
+	if i < len(runes) {
+		return runes[i]
+	}
+	return 0
+}
+
+// character directions for reordering
+const (
+	neutral = iota
+	ltr
+	rtl
+)
+
+func direction(r rune) int {
+	switch {
+	case r >= 0x0590 && r <= 0x08ff, r >= 0xfb1d && r <= 0xfdff, r >= 0xfe70 && r <= 0xfeff:
+		// the Arabic-Indic digits run left to right like other digits
+		if r >= 0x0660 && r <= 0x0669 || r >= 0x06f0 && r <= 0x06f9 {
+			return ltr
+		}
+		return rtl
+	case unicode.IsLetter(r), unicode.IsDigit(r):
+		return ltr
+	}
+	return neutral
+}
+
+// mirrored brackets face the other way in right to left runs
+var mirrored = map[rune]rune{
+	'(': ')', ')': '(', '[': ']', ']': '[', '{': '}', '}': '{',
+	'<': '>', '>': '<', '«': '»', '»': '«',
+}
+
+// visualOrder lays a line out left to right for drawing. Lines with Arabic
+// in them read right to left, runs of Latin letters and digits inside keep
+// their order. Neutrals between two runs of the same direction take it,
+// others follow the line.
+func visualOrder(text []rune) []rune {
+	dirs := make([]int, len(text))
+	base := ltr
+	for i, r := range text {
+		dirs[i] = direction(r)
+		if dirs[i] == rtl {
+			base = rtl
+		}
+	}
+	if base == ltr {
+		return text
+	}
+	// percent signs stay with the number before them
+	for i := 1; i < len(text); i++ {
+		if (text[i] == '%' || text[i] == '٪') && unicode.IsDigit(text[i-1]) {
+			dirs[i] = ltr
+		}
+	}
+	for i := 0; i < len(dirs); {
+		if dirs[i] != neutral {
+			i++
+			continue
+		}
+		j := i
+		for j < len(dirs) && dirs[j] == neutral {
+			j++
+		}
+		dir := base
+		if i > 0 && j < len(dirs) && dirs[i-1] == dirs[j] {
+			dir = dirs[j]
+		}
+		for k := i; k < j; k++ {
+			dirs[k] = dir
+		}
+		i = j
+	}
+
+	out := make([]rune, len(text))
+	for i, r := range text {
+		if dirs[i] == rtl {
+			if m, ok := mirrored[r]; ok {
+				r = m
+			}
+		}
+		out[len(text)-1-i] = r
+	}
+	// reversing the line turned the left to right runs around too
+	for i := 0; i < len(out); {
+		if dirs[len(text)-1-i] != ltr {
+			i++
+			continue
+		}
+		j := i
+		for j < len(out) && dirs[len(text)-1-j] == ltr {
+			j++
+		}
+		for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+			out[a], out[b] = out[b], out[a]
+		}
+		i = j
+	}
+	return out
+}
@@ -0,0 +1,161 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+
+	"github.com/alghurabi0/rehla/internal/qr"
+)
+
+// A4 landscape in points, the size of certificates
+const (
+	A4Long  = 841.89
+	A4Short = 595.28
+)
+
+// Align places text relative to the x it's drawn at.
+type Align int
+
+const (
+	AlignLeft Align = iota
+	AlignCenter
+	AlignRight
+)
+
+// Document is a pdf drawn from scratch, text is written with one font.
+type Document struct {
+	font  *Font
+	pages []*Page
+	// the glyphs drawn and the text they stand for
+	used map[uint16]rune
+}
+
+func NewDocument(font *Font) *Document {
+	return &Document{font: font, used: map[uint16]rune{}}
+}
+
+// Page is drawn on in points from the bottom left corner.
+type Page struct {
+	doc           *Document
+	width, height float64
+	content       bytes.Buffer
+}
+
+func (d *Document) AddPage(width, height float64) *Page {
+	p := &Page{doc: d, width: width, height: height}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+func rgb(c color.Color) (float64, float64, float64) {
+	r, g, b, _ := c.RGBA()
+	return float64(r) / 0xffff, float64(g) / 0xffff, float64(b) / 0xffff
+}
+
+// Rect fills a rectangle.
+func (p *Page) Rect(x, y, w, h float64, fill color.Color) {
+	r, g, b := rgb(fill)
+	fmt.Fprintf(&p.content, "%.3f %.3f %.3f rg %.2f %.2f %.2f %.2f re f\n", r, g, b, x, y, w, h)
+}
+
+// StrokeRect draws the outline of a rectangle.
+func (p *Page) StrokeRect(x, y, w, h, lineWidth float64, stroke color.Color) {
+	r, g, b := rgb(stroke)
+	fmt.Fprintf(&p.content, "%.3f %.3f %.3f RG %.2f w %.2f %.2f %.2f %.2f re S\n", r, g, b, lineWidth, x, y, w, h)
+}
+
+// glyphs shapes the text and returns its glyphs left to right.
+func (d *Document) glyphs(text string) []uint16 {
+	runes := visualOrder(shapeArabic([]rune(text)))
+	gids := make([]uint16, len(runes))
+	for i, r := range runes {
+		gids[i] = d.font.glyph(r)
+	}
+	for i, gid := range gids {
+		if _, ok := d.used[gid]; !ok {
+			d.used[gid] = runes[i]
+		}
+	}
+	return gids
+}
+
+// TextWidth is how wide the text is drawn at the size.
+func (d *Document) TextWidth(text string, size float64) float64 {
+	var width float64
+	for _, r := range visualOrder(shapeArabic([]rune(text))) {
+		width += d.font.advance(d.font.glyph(r))
+	}
+	return width * size / 1000
+}
+
+// Text writes a line of text with its baseline at y. Arabic is joined and
+// read right to left.
+func (p *Page) Text(x, y, size float64, align Align, fill color.Color, text string) {
+	width := p.doc.TextWidth(text, size)
+	switch align {
+	case AlignCenter:
+		x -= width / 2
+	case AlignRight:
+		x -= width
+	}
+	r, g, b := rgb(fill)
+	fmt.Fprintf(&p.content, "BT %.3f %.3f %.3f rg /F0 %.2f Tf %.2f %.2f Td <", r, g, b, size, x, y)
+	for _, gid := range p.doc.glyphs(text) {
+		fmt.Fprintf(&p.content, "%04x", gid)
+	}
+	p.content.WriteString("> Tj ET\n")
+}
+
+// QR draws the code in a square of the size with its bottom left corner at
+// x, y. The quiet zone around it is left to the caller.
+func (p *Page) QR(x, y, size float64, code *qr.Code, fill color.Color) {
+	module := size / float64(code.Size)
+	r, g, b := rgb(fill)
+	fmt.Fprintf(&p.content, "%.3f %.3f %.3f rg\n", r, g, b)
+	for row := 0; row < code.Size; row++ {
+		// the rows run top down
+		my := y + size - float64(row+1)*module
+		for col := 0; col < code.Size; {
+			if !code.Black(col, row) {
+				col++
+				continue
+			}
+			start := col
+			for col < code.Size && code.Black(col, row) {
+				col++
+			}
+			fmt.Fprintf(&p.content, "%.3f %.3f %.3f %.3f re\n", x+float64(start)*module, my, float64(col-start)*module, module)
+		}
+	}
+	p.content.WriteString("f\n")
+}
+
+// Bytes writes out the document.
+func (d *Document) Bytes() ([]byte, error) {
+	if len(d.pages) == 0 {
+		return nil, ErrNoPages
+	}
+	w := newWriter()
+	pagesId := w.alloc()
+	fontId := w.alloc()
+	var kids array
+	for _, p := range d.pages {
+		content := w.add(&stream{hdr: dict{}, data: p.content.Bytes()})
+		kids = append(kids, w.add(dict{
+			"Type":      name("Page"),
+			"Parent":    pagesId,
+			"MediaBox":  array{0, 0, real(fmt.Sprintf("%.2f", p.width)), real(fmt.Sprintf("%.2f", p.height))},
+			"Resources": dict{"Font": dict{"F0": fontId}},
+			"Contents":  content,
+		}))
+	}
+	d.font.embed(w, fontId, d.used)
+	w.write(pagesId, dict{
+		"Type":  name("Pages"),
+		"Kids":  kids,
+		"Count": len(kids),
+	})
+	root := w.add(dict{"Type": name("Catalog"), "Pages": pagesId})
+	return w.finish(root), nil
+}
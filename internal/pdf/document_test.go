@@ -0,0 +1,138 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/alghurabi0/rehla/internal/assert"
+	"github.com/alghurabi0/rehla/internal/qr"
+)
+
+func hex(runes []rune) string {
+	return fmt.Sprintf("%x", runes)
+}
+
+func TestShapeArabic(t *testing.T) {
+	tests := []struct {
+		name, text string
+		want       []rune
+	}{
+		// beh initial, seen medial, meem final
+		{"joined", "بسم", []rune{0xfe91, 0xfeb4, 0xfee2}},
+		// dal doesn't join the letter after it
+		{"right joining", "درس", []rune{0xfea9, 0xfead, 0xfeb1}},
+		{"lam alef", "سلام", []rune{0xfeb3, 0xfefc, 0xfee1}},
+		{"lam alef alone", "لا", []rune{0xfefb}},
+		{"harakat dropped", "مُحَمَّد", []rune{0xfee3, 0xfea4, 0xfee4, 0xfeaa}},
+		{"hamza", "ماء", []rune{0xfee3, 0xfe8e, 0xfe80}},
+		{"latin", "ab", []rune{'a', 'b'}},
+		{"words", "بب بب", []rune{0xfe91, 0xfe90, ' ', 0xfe91, 0xfe90}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, hex(shapeArabic([]rune(tt.text))), hex(tt.want))
+		})
+	}
+}
+
+func TestVisualOrder(t *testing.T) {
+	tests := []struct {
+		name, text, want string
+	}{
+		{"latin", "abc (1)", "abc (1)"},
+		{"arabic", "ابت", "تبا"},
+		{"numbers keep their order", "اب 2024 ت", "ت 2024 با"},
+		{"latin inside", "ا abc def ب", "ب abc def ا"},
+		{"brackets mirror", "ا (ب)", "(ب) ا"},
+		{"trailing neutral", "اب.", ".با"},
+		{"percent", "بمعدل 87.5%", "87.5% لدعمب"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, string(visualOrder([]rune(tt.text))), tt.want)
+		})
+	}
+}
+
+func loadFont(t *testing.T) *Font {
+	t.Helper()
+	data, err := os.ReadFile("../../ui/fonts/DejaVuSans.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	font, err := ParseFont("DejaVuSans", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return font
+}
+
+func TestParseFont(t *testing.T) {
+	font := loadFont(t)
+	assert.Equal(t, font.glyph('A') != 0, true)
+	assert.Equal(t, font.glyph(0xfefb) != 0, true)
+	// DejaVu Sans has no CJK
+	assert.Equal(t, font.glyph('漢'), uint16(0))
+
+	_, err := ParseFont("junk", []byte("not a font at all"))
+	assert.Equal(t, err, ErrBadFont)
+}
+
+func TestDocument(t *testing.T) {
+	font := loadFont(t)
+	doc := NewDocument(font)
+	page := doc.AddPage(A4Long, A4Short)
+	page.Rect(0, 0, A4Long, A4Short, color.White)
+	page.StrokeRect(20, 20, A4Long-40, A4Short-40, 2, color.Black)
+	page.Text(A4Long/2, 400, 32, AlignCenter, color.Black, "شهادة إتمام")
+	page.Text(40, 40, 10, AlignLeft, color.Black, "ABCD-2345")
+	code, err := qr.Encode("https://www.rehla.live/certificates/ABCD2345EFGH")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page.QR(700, 40, 100, code, color.Black)
+
+	out, err := doc.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := newReader(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages, err := r.pages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(pages), 1)
+
+	// the embedded subset is a font of its own with the drawn glyphs
+	start := bytes.Index(out, []byte("/Length1"))
+	assert.Equal(t, start > 0, true)
+	at := bytes.Index(out[start:], []byte("stream\n")) + start + len("stream\n")
+	zr, err := zlib.NewReader(bytes.NewReader(out[at:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subset, err := ParseFont("subset", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, checksum(file), uint32(0xb1b0afba))
+	assert.Equal(t, len(subset.glyphData(int(font.glyph('A')))) > 0, true)
+	assert.Equal(t, len(subset.glyphData(int(font.glyph(0xfeb7)))) > 0, true)
+	assert.Equal(t, len(subset.glyphData(int(font.glyph('Z')))), 0)
+	assert.Equal(t, len(file) < 80_000, true)
+
+	_, err = NewDocument(font).Bytes()
+	assert.Equal(t, err, ErrNoPages)
+}
@@ -0,0 +1,479 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+)
+
+var ErrBadFont = errors.New("pdf: not a usable TrueType font")
+
+// Font is a TrueType font text is written with. Only the glyphs a document
+// uses are embedded, glyph ids are kept so the widths and the glyph map
+// stay as they are.
+type Font struct {
+	name       string
+	tables     map[string][]byte
+	unitsPerEm int
+	ascent     int
+	descent    int
+	bbox       [4]int
+	longLoca   bool
+	numGlyphs  int
+	advances   []int
+	glyphs     map[rune]uint16
+}
+
+// ParseFont reads a TrueType font, the glyphs have to be TrueType outlines.
+func ParseFont(name string, data []byte) (*Font, error) {
+	if len(data) < 12 {
+		return nil, ErrBadFont
+	}
+	tag := string(data[:4])
+	if tag != "\x00\x01\x00\x00" && tag != "true" {
+		return nil, ErrBadFont
+	}
+	n := int(binary.BigEndian.Uint16(data[4:]))
+	f := &Font{name: name, tables: map[string][]byte{}}
+	for i := 0; i < n; i++ {
+		rec := data[12+16*i:]
+		if len(rec) < 16 {
+			return nil, ErrBadFont
+		}
+		offset := int(binary.BigEndian.Uint32(rec[8:]))
+		length := int(binary.BigEndian.Uint32(rec[12:]))
+		if offset < 0 || length < 0 || offset+length > len(data) {
+			return nil, ErrBadFont
+		}
+		f.tables[string(rec[:4])] = data[offset : offset+length]
+	}
+	for _, required := range []string{"head", "hhea", "hmtx", "maxp", "loca", "glyf", "cmap"} {
+		if _, ok := f.tables[required]; !ok {
+			return nil, fmt.Errorf("%w: no %s table", ErrBadFont, required)
+		}
+	}
+
+	head, hhea, maxp := f.tables["head"], f.tables["hhea"], f.tables["maxp"]
+	if len(head) < 54 || len(hhea) < 36 || len(maxp) < 6 {
+		return nil, ErrBadFont
+	}
+	f.unitsPerEm = int(binary.BigEndian.Uint16(head[18:]))
+	if f.unitsPerEm == 0 {
+		return nil, ErrBadFont
+	}
+	for i := range f.bbox {
+		f.bbox[i] = int(int16(binary.BigEndian.Uint16(head[36+2*i:])))
+	}
+	f.longLoca = binary.BigEndian.Uint16(head[50:]) == 1
+	f.ascent = int(int16(binary.BigEndian.Uint16(hhea[4:])))
+	f.descent = int(int16(binary.BigEndian.Uint16(hhea[6:])))
+	f.numGlyphs = int(binary.BigEndian.Uint16(maxp[4:]))
+
+	metrics := int(binary.BigEndian.Uint16(hhea[34:]))
+	hmtx := f.tables["hmtx"]
+	if metrics == 0 || len(hmtx) < metrics*4 {
+		return nil, ErrBadFont
+	}
+	f.advances = make([]int, f.numGlyphs)
+	for g := range f.advances {
+		// glyphs past the long metrics share the last advance
+		m := min(g, metrics-1)
+		f.advances[g] = int(binary.BigEndian.Uint16(hmtx[m*4:]))
+	}
+	if len(f.tables["loca"]) < f.locaSize() {
+		return nil, ErrBadFont
+	}
+
+	var err error
+	f.glyphs, err = parseCmap(f.tables["cmap"])
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *Font) locaSize() int {
+	if f.longLoca {
+		return (f.numGlyphs + 1) * 4
+	}
+	return (f.numGlyphs + 1) * 2
+}
+
+// parseCmap reads the unicode mapping of the font, the full range one when
+// the font has it.
+func parseCmap(cmap []byte) (map[rune]uint16, error) {
+	if len(cmap) < 4 {
+		return nil, ErrBadFont
+	}
+	var bmp, full []byte
+	n := int(binary.BigEndian.Uint16(cmap[2:]))
+	for i := 0; i < n; i++ {
+		rec := cmap[4+8*i:]
+		if len(rec) < 8 {
+			return nil, ErrBadFont
+		}
+		platform, encoding := binary.BigEndian.Uint16(rec), binary.BigEndian.Uint16(rec[2:])
+		offset := int(binary.BigEndian.Uint32(rec[4:]))
+		if offset+4 > len(cmap) {
+			return nil, ErrBadFont
+		}
+		sub := cmap[offset:]
+		format := binary.BigEndian.Uint16(sub)
+		switch {
+		case format == 12 && (platform == 3 && encoding == 10 || platform == 0):
+			full = sub
+		case format == 4 && (platform == 3 && encoding == 1 || platform == 0):
+			bmp = sub
+		}
+	}
+	glyphs := map[rune]uint16{}
+	switch {
+	case full != nil:
+		if len(full) < 16 {
+			return nil, ErrBadFont
+		}
+		groups := int(binary.BigEndian.Uint32(full[12:]))
+		if len(full) < 16+groups*12 {
+			return nil, ErrBadFont
+		}
+		for i := 0; i < groups; i++ {
+			g := full[16+12*i:]
+			start, end := binary.BigEndian.Uint32(g), binary.BigEndian.Uint32(g[4:])
+			gid := binary.BigEndian.Uint32(g[8:])
+			for c := start; c <= end && c <= 0x10ffff; c++ {
+				glyphs[rune(c)] = uint16(gid + c - start)
+			}
+		}
+	case bmp != nil:
+		if len(bmp) < 14 {
+			return nil, ErrBadFont
+		}
+		segs := int(binary.BigEndian.Uint16(bmp[6:])) / 2
+		ends, starts := 14, 16+segs*2
+		deltas, ranges := starts+segs*2, starts+segs*4
+		if len(bmp) < ranges+segs*2 {
+			return nil, ErrBadFont
+		}
+		for i := 0; i < segs; i++ {
+			end := int(binary.BigEndian.Uint16(bmp[ends+2*i:]))
+			start := int(binary.BigEndian.Uint16(bmp[starts+2*i:]))
+			delta := int(binary.BigEndian.Uint16(bmp[deltas+2*i:]))
+			rangeOffset := int(binary.BigEndian.Uint16(bmp[ranges+2*i:]))
+			for c := start; c <= end && c < 0xffff; c++ {
+				gid := c
+				if rangeOffset != 0 {
+					at := ranges + 2*i + rangeOffset + 2*(c-start)
+					if at+2 > len(bmp) {
+						continue
+					}
+					gid = int(binary.BigEndian.Uint16(bmp[at:]))
+					if gid == 0 {
+						continue
+					}
+				}
+				glyphs[rune(c)] = uint16((gid + delta) & 0xffff)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%w: no unicode cmap", ErrBadFont)
+	}
+	return glyphs, nil
+}
+
+// glyph returns the glyph of the rune, 0 for the missing glyph box.
+func (f *Font) glyph(r rune) uint16 {
+	return f.glyphs[r]
+}
+
+// advance is the width of the glyph in thousandths of the font size.
+func (f *Font) advance(gid uint16) float64 {
+	if int(gid) >= len(f.advances) {
+		return 0
+	}
+	return float64(f.advances[gid]) * 1000 / float64(f.unitsPerEm)
+}
+
+// scale turns font units into thousandths of the font size.
+func (f *Font) scale(v int) int {
+	return v * 1000 / f.unitsPerEm
+}
+
+func (f *Font) glyphData(gid int) []byte {
+	loca, glyf := f.tables["loca"], f.tables["glyf"]
+	var start, end int
+	if f.longLoca {
+		start = int(binary.BigEndian.Uint32(loca[gid*4:]))
+		end = int(binary.BigEndian.Uint32(loca[gid*4+4:]))
+	} else {
+		start = int(binary.BigEndian.Uint16(loca[gid*2:])) * 2
+		end = int(binary.BigEndian.Uint16(loca[gid*2+2:])) * 2
+	}
+	if start >= end || end > len(glyf) {
+		return nil
+	}
+	return glyf[start:end]
+}
+
+// composite glyph flags
+const (
+	argsAreWords    = 0x0001
+	haveScale       = 0x0008
+	moreComponents  = 0x0020
+	haveXYScale     = 0x0040
+	haveTwoByTwo    = 0x0080
+	compositeHeader = 10
+)
+
+// components returns the glyphs a composite glyph is built from.
+func components(data []byte) []uint16 {
+	if len(data) < compositeHeader || int16(binary.BigEndian.Uint16(data)) >= 0 {
+		return nil
+	}
+	var gids []uint16
+	for at := compositeHeader; at+4 <= len(data); {
+		flags := binary.BigEndian.Uint16(data[at:])
+		gids = append(gids, binary.BigEndian.Uint16(data[at+2:]))
+		at += 4
+		if flags&argsAreWords != 0 {
+			at += 4
+		} else {
+			at += 2
+		}
+		switch {
+		case flags&haveScale != 0:
+			at += 2
+		case flags&haveXYScale != 0:
+			at += 4
+		case flags&haveTwoByTwo != 0:
+			at += 8
+		}
+		if flags&moreComponents == 0 {
+			break
+		}
+	}
+	return gids
+}
+
+// subset returns the font file with every glyph but the used ones and
+// their components emptied.
+func (f *Font) subset(used map[uint16]bool) []byte {
+	keep := map[int]bool{}
+	var walk func(gid int)
+	walk = func(gid int) {
+		if gid >= f.numGlyphs || keep[gid] {
+			return
+		}
+		keep[gid] = true
+		for _, c := range components(f.glyphData(gid)) {
+			walk(int(c))
+		}
+	}
+	// the missing glyph box is always there
+	walk(0)
+	for gid := range used {
+		walk(int(gid))
+	}
+
+	var glyf bytes.Buffer
+	offsets := make([]int, f.numGlyphs+1)
+	for gid := 0; gid < f.numGlyphs; gid++ {
+		offsets[gid] = glyf.Len()
+		if keep[gid] {
+			glyf.Write(f.glyphData(gid))
+			for glyf.Len()%4 != 0 {
+				glyf.WriteByte(0)
+			}
+		}
+	}
+	offsets[f.numGlyphs] = glyf.Len()
+	// short offsets are halved, they fit most subsets in half the size
+	long := glyf.Len() >= 0x20000
+	var loca []byte
+	for _, offset := range offsets {
+		if long {
+			loca = binary.BigEndian.AppendUint32(loca, uint32(offset))
+		} else {
+			loca = binary.BigEndian.AppendUint16(loca, uint16(offset/2))
+		}
+	}
+
+	head := append([]byte(nil), f.tables["head"]...)
+	// the checksum adjustment is worked out over the new file
+	binary.BigEndian.PutUint32(head[8:], 0)
+	binary.BigEndian.PutUint16(head[50:], 0)
+	if long {
+		binary.BigEndian.PutUint16(head[50:], 1)
+	}
+
+	tables := map[string][]byte{
+		"head": head,
+		"hhea": f.tables["hhea"],
+		"hmtx": f.tables["hmtx"],
+		"maxp": f.tables["maxp"],
+		"loca": loca,
+		"glyf": glyf.Bytes(),
+	}
+	// hinting, viewers use it at small sizes, and the glyph map for tools
+	// that read the font file
+	for _, tag := range []string{"cvt ", "fpgm", "prep", "cmap"} {
+		if t, ok := f.tables[tag]; ok {
+			tables[tag] = t
+		}
+	}
+	out, at := writeSfnt(tables)
+	binary.BigEndian.PutUint32(out[at["head"]+8:], 0xb1b0afba-checksum(out))
+	return out
+}
+
+func checksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
+
+// writeSfnt lays the tables out in a font file in tag order and returns
+// where each table starts.
+func writeSfnt(tables map[string][]byte) ([]byte, map[string]int) {
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	n := len(tags)
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= n {
+		entrySelector++
+	}
+	searchRange := (1 << entrySelector) * 16
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, []uint32{0x00010000})
+	binary.Write(&buf, binary.BigEndian, []uint16{uint16(n), uint16(searchRange), uint16(entrySelector), uint16(n*16 - searchRange)})
+	offset := 12 + n*16
+	offsets := make(map[string]int, n)
+	for _, tag := range tags {
+		t := tables[tag]
+		offsets[tag] = offset
+		buf.WriteString(tag)
+		binary.Write(&buf, binary.BigEndian, []uint32{checksum(t), uint32(offset), uint32(len(t))})
+		offset += (len(t) + 3) &^ 3
+	}
+	for _, tag := range tags {
+		t := tables[tag]
+		buf.Write(t)
+		buf.Write(make([]byte, (4-len(t)%4)%4))
+	}
+	return buf.Bytes(), offsets
+}
+
+// embed writes the font with the glyphs the document used at id, as a Type0
+// font whose codes are the glyph ids.
+func (f *Font) embed(w *writer, id ref, used map[uint16]rune) {
+	gids := make([]int, 0, len(used))
+	keep := make(map[uint16]bool, len(used))
+	for gid := range used {
+		gids = append(gids, int(gid))
+		keep[gid] = true
+	}
+	sort.Ints(gids)
+
+	// subsets are tagged so viewers don't mix up subsets of the same font
+	h := fnv.New32a()
+	for _, gid := range gids {
+		binary.Write(h, binary.BigEndian, uint16(gid))
+	}
+	sum := h.Sum32()
+	tag := make([]byte, 6)
+	for i := range tag {
+		tag[i] = 'A' + byte(sum%26)
+		sum /= 26
+	}
+	base := name(string(tag) + "+" + f.name)
+
+	file := f.subset(keep)
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write(file)
+	zw.Close()
+	fontFile := w.add(&stream{
+		hdr:  dict{"Length1": len(file), "Filter": name("FlateDecode")},
+		data: z.Bytes(),
+	})
+	descriptor := w.add(dict{
+		"Type":        name("FontDescriptor"),
+		"FontName":    base,
+		"Flags":       32,
+		"FontBBox":    array{f.scale(f.bbox[0]), f.scale(f.bbox[1]), f.scale(f.bbox[2]), f.scale(f.bbox[3])},
+		"ItalicAngle": 0,
+		"Ascent":      f.scale(f.ascent),
+		"Descent":     f.scale(f.descent),
+		"CapHeight":   f.scale(f.ascent),
+		"StemV":       80,
+		"FontFile2":   fontFile,
+	})
+	var widths array
+	for _, gid := range gids {
+		widths = append(widths, gid, array{int(f.advance(uint16(gid)))})
+	}
+	cid := w.add(dict{
+		"Type":     name("Font"),
+		"Subtype":  name("CIDFontType2"),
+		"BaseFont": base,
+		"CIDSystemInfo": dict{
+			"Registry":   pdfString("Adobe"),
+			"Ordering":   pdfString("Identity"),
+			"Supplement": 0,
+		},
+		"FontDescriptor": descriptor,
+		"CIDToGIDMap":    name("Identity"),
+		"W":              widths,
+	})
+	w.write(id, dict{
+		"Type":            name("Font"),
+		"Subtype":         name("Type0"),
+		"BaseFont":        base,
+		"Encoding":        name("Identity-H"),
+		"DescendantFonts": array{cid},
+		"ToUnicode":       w.add(&stream{hdr: dict{}, data: toUnicode(gids, used)}),
+	})
+}
+
+// toUnicode maps the glyphs back to text for copying and searching.
+func toUnicode(gids []int, used map[uint16]rune) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	buf.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+	buf.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+	buf.WriteString("1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	// at most 100 entries a section
+	for i := 0; i < len(gids); i += 100 {
+		chunk := gids[i:min(i+100, len(gids))]
+		fmt.Fprintf(&buf, "%d beginbfchar\n", len(chunk))
+		for _, gid := range chunk {
+			fmt.Fprintf(&buf, "<%04X> <", gid)
+			for _, u := range utf16Units(used[uint16(gid)]) {
+				fmt.Fprintf(&buf, "%04X", u)
+			}
+			buf.WriteString(">\n")
+		}
+		buf.WriteString("endbfchar\n")
+	}
+	buf.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend")
+	return buf.Bytes()
+}
+
+func utf16Units(r rune) []uint16 {
+	if r < 0x10000 {
+		return []uint16{uint16(r)}
+	}
+	r -= 0x10000
+	return []uint16{uint16(0xd800 + r>>10), uint16(0xdc00 + r&0x3ff)}
+}
@@ -0,0 +1,240 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png"
+)
+
+const (
+	// longest side of a page photo, about 170 dpi on A4
+	maxImageSide = 2000
+	jpegQuality  = 75
+	// larger images are refused before decoding them
+	maxImagePixels = 50_000_000
+	// all the photos of one merge together, about twenty phone photos
+	maxMergePixels = 250_000_000
+)
+
+var (
+	ErrImageTooLarge  = errors.New("pdf: image is too large")
+	ErrImagesTooLarge = errors.New("pdf: images are too large together")
+)
+
+// imagePixels returns the pixel count of a JPEG or PNG from its header.
+func imagePixels(data []byte) (int, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	return cfg.Width * cfg.Height, nil
+}
+
+// compressImage decodes a JPEG or PNG, turns it upright using its EXIF
+// orientation, scales it down and encodes it again as JPEG.
+func compressImage(data []byte) ([]byte, int, int, error) {
+	pixels, err := imagePixels(data)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if pixels > maxImagePixels {
+		return nil, 0, 0, ErrImageTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	// transparent PNGs are drawn on white paper
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Over)
+
+	rgba = orient(downscale(rgba, maxImageSide), exifOrientation(data))
+	var buf bytes.Buffer
+	err = jpeg.Encode(&buf, rgba, &jpeg.Options{Quality: jpegQuality})
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return buf.Bytes(), rgba.Rect.Dx(), rgba.Rect.Dy(), nil
+}
+
+// exifOrientation returns the EXIF orientation of a JPEG, 1 when there is
+// none.
+func exifOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return 1
+	}
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xff {
+			return 1
+		}
+		marker := data[pos+1]
+		// start of scan, the metadata is before it
+		if marker == 0xda {
+			return 1
+		}
+		size := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if size < 2 || pos+2+size > len(data) {
+			return 1
+		}
+		segment := data[pos+4 : pos+2+size]
+		if marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		pos += 2 + size
+	}
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			o := int(order.Uint16(tiff[entry+8:]))
+			if o < 1 || o > 8 {
+				return 1
+			}
+			return o
+		}
+	}
+	return 1
+}
+
+// orient applies an EXIF orientation so the image is displayed upright.
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			si := sy*src.Stride + sx*4
+			di := y*dst.Stride + x*4
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+	return dst
+}
+
+// downscale shrinks the image so its longest side is at most maxSide by
+// averaging the source pixels under every destination pixel.
+func downscale(src *image.RGBA, maxSide int) *image.RGBA {
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	if w <= maxSide && h <= maxSide {
+		return src
+	}
+	dw, dh := maxSide, h*maxSide/w
+	if h > w {
+		dw, dh = w*maxSide/h, maxSide
+	}
+	dw, dh = max(dw, 1), max(dh, 1)
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*h/dh, max((y+1)*h/dh, y*h/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*w/dw, max((x+1)*w/dw, x*w/dw+1)
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					sum[0] += int(p[0])
+					sum[1] += int(p[1])
+					sum[2] += int(p[2])
+					sum[3] += int(p[3])
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			di := y*dst.Stride + x*4
+			for i := range sum {
+				dst.Pix[di+i] = uint8(sum[i] / n)
+			}
+		}
+	}
+	return dst
+}
+
+// imagePage writes a page showing the image scaled to fit A4, landscape
+// images get a landscape page.
+func (w *writer) imagePage(data []byte, width, height int, parent ref) ref {
+	pw, ph := 595.28, 841.89
+	if width > height {
+		pw, ph = ph, pw
+	}
+	scale := min(pw/float64(width), ph/float64(height))
+	iw, ih := float64(width)*scale, float64(height)*scale
+
+	img := w.add(&stream{
+		hdr: dict{
+			"Type":             name("XObject"),
+			"Subtype":          name("Image"),
+			"Width":            width,
+			"Height":           height,
+			"ColorSpace":       name("DeviceRGB"),
+			"BitsPerComponent": 8,
+			"Filter":           name("DCTDecode"),
+		},
+		data: data,
+	})
+	content := w.add(&stream{
+		hdr:  dict{},
+		data: []byte(fmt.Sprintf("q %.2f 0 0 %.2f %.2f %.2f cm /Im0 Do Q", iw, ih, (pw-iw)/2, (ph-ih)/2)),
+	})
+	return w.add(dict{
+		"Type":      name("Page"),
+		"Parent":    parent,
+		"MediaBox":  array{0, 0, real(fmt.Sprintf("%.2f", pw)), real(fmt.Sprintf("%.2f", ph))},
+		"Resources": dict{"XObject": dict{"Im0": img}},
+		"Contents":  content,
+	})
+}
@@ -0,0 +1,76 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+var ErrNoParts = errors.New("pdf: nothing to merge")
+
+// IsPDF reports whether data looks like a pdf document, some writers put
+// junk before the header.
+func IsPDF(data []byte) bool {
+	return bytes.Contains(data[:min(len(data), 1024)], []byte("%PDF-"))
+}
+
+// Merge returns one document with the pages of every part in order. Parts
+// are JPEG or PNG photos, which get a page each, or pdf documents whose
+// pages are copied.
+func Merge(parts [][]byte) ([]byte, error) {
+	if len(parts) == 0 {
+		return nil, ErrNoParts
+	}
+	// every photo is decoded whole, so they share one budget checked
+	// before decoding any of them
+	pixels := 0
+	for i, part := range parts {
+		if IsPDF(part) {
+			continue
+		}
+		n, err := imagePixels(part)
+		if err != nil {
+			return nil, fmt.Errorf("part %d: %w", i+1, err)
+		}
+		pixels += n
+		if pixels > maxMergePixels {
+			return nil, fmt.Errorf("part %d: %w", i+1, ErrImagesTooLarge)
+		}
+	}
+	w := newWriter()
+	pagesId := w.alloc()
+	var kids array
+	for i, part := range parts {
+		if IsPDF(part) {
+			r, err := newReader(part)
+			if err != nil {
+				return nil, fmt.Errorf("part %d: %w", i+1, err)
+			}
+			pages, err := r.pages()
+			if err != nil {
+				return nil, fmt.Errorf("part %d: %w", i+1, err)
+			}
+			c := newCopier(r, w)
+			for _, page := range pages {
+				id, err := c.page(page, pagesId)
+				if err != nil {
+					return nil, fmt.Errorf("part %d: %w", i+1, err)
+				}
+				kids = append(kids, id)
+			}
+			continue
+		}
+		data, width, height, err := compressImage(part)
+		if err != nil {
+			return nil, fmt.Errorf("part %d: %w", i+1, err)
+		}
+		kids = append(kids, w.imagePage(data, width, height, pagesId))
+	}
+	w.write(pagesId, dict{
+		"Type":  name("Pages"),
+		"Kids":  kids,
+		"Count": len(kids),
+	})
+	root := w.add(dict{"Type": name("Catalog"), "Pages": pagesId})
+	return w.finish(root), nil
+}
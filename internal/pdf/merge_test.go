@@ -0,0 +1,146 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/alghurabi0/rehla/internal/assert"
+)
+
+// photo returns a JPEG with an EXIF orientation like the ones phones take.
+func photo(t testing.TB, w, h, orientation int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// tiff header with a single entry in IFD0
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	entry := make([]byte, 12)
+	binary.BigEndian.PutUint16(entry[0:], 0x0112)
+	binary.BigEndian.PutUint16(entry[2:], 3)
+	binary.BigEndian.PutUint32(entry[4:], 1)
+	binary.BigEndian.PutUint16(entry[8:], uint16(orientation))
+	tiff = append(append(tiff, entry...), 0, 0, 0, 0)
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+
+	out := []byte{0xff, 0xd8, 0xff, 0xe1, 0, 0}
+	binary.BigEndian.PutUint16(out[4:], uint16(len(segment)+2))
+	out = append(out, segment...)
+	return append(out, buf.Bytes()[2:]...)
+}
+
+func TestExifOrientation(t *testing.T) {
+	assert.Equal(t, exifOrientation(photo(t, 4, 2, 6)), 6)
+	assert.Equal(t, exifOrientation(photo(t, 4, 2, 1)), 1)
+	assert.Equal(t, exifOrientation([]byte("not a jpeg")), 1)
+}
+
+func TestOrient(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	src.Set(0, 0, color.RGBA{255, 0, 0, 255})
+	src.Set(1, 0, color.RGBA{0, 0, 255, 255})
+
+	// rotated clockwise the left pixel ends up on top
+	dst := orient(src, 6)
+	assert.Equal(t, dst.Rect.Dx(), 1)
+	assert.Equal(t, dst.Rect.Dy(), 2)
+	assert.Equal(t, dst.RGBAAt(0, 0), color.RGBA{255, 0, 0, 255})
+	assert.Equal(t, dst.RGBAAt(0, 1), color.RGBA{0, 0, 255, 255})
+
+	dst = orient(src, 3)
+	assert.Equal(t, dst.RGBAAt(0, 0), color.RGBA{0, 0, 255, 255})
+}
+
+func TestDownscale(t *testing.T) {
+	dst := downscale(image.NewRGBA(image.Rect(0, 0, 300, 100)), 150)
+	assert.Equal(t, dst.Rect.Dx(), 150)
+	assert.Equal(t, dst.Rect.Dy(), 50)
+}
+
+func TestMerge(t *testing.T) {
+	var pngBuf bytes.Buffer
+	err := png.Encode(&pngBuf, image.NewRGBA(image.Rect(0, 0, 30, 20)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := Merge([][]byte{photo(t, 40, 30, 6), pngBuf.Bytes()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged, err := Merge([][]byte{first, photo(t, 40, 30, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := newReader(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages, err := r.pages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(pages), 3)
+	// the rotated photo is portrait, the others are landscape
+	box, _ := pages[0]["MediaBox"].(array)
+	assert.Equal(t, box[3], object(real("841.89")))
+	box, _ = pages[1]["MediaBox"].(array)
+	assert.Equal(t, box[3], object(real("595.28")))
+}
+
+func TestMergeRejectsBadParts(t *testing.T) {
+	_, err := Merge(nil)
+	assert.Equal(t, err, ErrNoParts)
+	_, err = Merge([][]byte{[]byte("%PDF-1.4 garbage")})
+	if err == nil {
+		t.Error("expected an error for a broken pdf")
+	}
+}
+
+func TestMergeRejectsTooManyPixels(t *testing.T) {
+	// only the headers are read, so the photos can claim any size
+	var huge bytes.Buffer
+	err := png.Encode(&huge, image.NewGray(image.Rect(0, 0, 1, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	big := huge.Bytes()
+	resize := func(w, h uint32) {
+		// IHDR width and height, then its checksum
+		binary.BigEndian.PutUint32(big[16:], w)
+		binary.BigEndian.PutUint32(big[20:], h)
+		binary.BigEndian.PutUint32(big[29:], crc32.ChecksumIEEE(big[12:29]))
+	}
+	resize(7000, 7500)
+	_, err = Merge([][]byte{big})
+	assert.Equal(t, errors.Is(err, ErrImageTooLarge), true)
+
+	resize(6000, 6000)
+	_, err = Merge([][]byte{big, big, big, big, big, big, big, big})
+	assert.Equal(t, errors.Is(err, ErrImagesTooLarge), true)
+}
+
+func FuzzMerge(f *testing.F) {
+	doc, err := Merge([][]byte{photo(f, 20, 10, 1)})
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(doc)
+	f.Add([]byte("%PDF-1.4\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\ntrailer << /Root 1 0 R >>"))
+	f.Add([]byte("%PDF-1.4\n1 0 obj\n<< /Type /Catalog /Pages " + strings.Repeat("[", 2*maxDepth) + "\nendobj\n"))
+	f.Add([]byte("%PDF-1.4\nxref\n0 99999999\ntrailer << /Size -1 /Root 1 0 R >>\nstartxref\n9\n%%EOF"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// broken input may fail but must never panic or hang
+		Merge([][]byte{data})
+	})
+}
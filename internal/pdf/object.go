@@ -0,0 +1,136 @@
+// Package pdf builds the single document a corrector reads from the pages a
+// student uploads: photos are turned upright, compressed and placed on A4
+// pages, and the pages of uploaded PDFs are copied over as they are. It also
+// draws documents from scratch like certificates, with Arabic text in an
+// embedded TrueType font.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// pdf objects as they are read and written, numbers are int64 or real and
+// null is nil.
+type (
+	name      string
+	real      string
+	pdfString []byte
+	array     []object
+	dict      map[name]object
+	object    any
+)
+
+type ref struct {
+	num, gen int
+}
+
+// stream keeps its data encoded, pages are copied without decoding them.
+type stream struct {
+	hdr  dict
+	data []byte
+}
+
+func writeObject(buf *bytes.Buffer, obj object) {
+	switch v := obj.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case int:
+		buf.WriteString(strconv.Itoa(v))
+	case int64:
+		buf.WriteString(strconv.FormatInt(v, 10))
+	case float64:
+		buf.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+	case real:
+		buf.WriteString(string(v))
+	case name:
+		writeName(buf, v)
+	case pdfString:
+		fmt.Fprintf(buf, "<%x>", []byte(v))
+	case array:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			writeObject(buf, item)
+		}
+		buf.WriteByte(']')
+	case dict:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+		buf.WriteString("<<")
+		for _, k := range keys {
+			writeName(buf, name(k))
+			buf.WriteByte(' ')
+			writeObject(buf, v[name(k)])
+		}
+		buf.WriteString(">>")
+	case ref:
+		fmt.Fprintf(buf, "%d %d R", v.num, v.gen)
+	case *stream:
+		hdr := make(dict, len(v.hdr)+1)
+		for k, item := range v.hdr {
+			hdr[k] = item
+		}
+		hdr["Length"] = len(v.data)
+		writeObject(buf, hdr)
+		buf.WriteString("\nstream\n")
+		buf.Write(v.data)
+		buf.WriteString("\nendstream")
+	default:
+		panic(fmt.Sprintf("pdf: can't write %T", obj))
+	}
+}
+
+func writeName(buf *bytes.Buffer, n name) {
+	buf.WriteByte('/')
+	for i := 0; i < len(n); i++ {
+		c := n[i]
+		if c <= ' ' || c >= 0x7f || c == '#' || isDelimiter(c) {
+			fmt.Fprintf(buf, "#%02x", c)
+			continue
+		}
+		buf.WriteByte(c)
+	}
+}
+
+func isDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+func isSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0:
+		return true
+	}
+	return false
+}
+
+// toInt returns the value of an integer object, reals are truncated.
+func toInt(obj object) (int, bool) {
+	switch v := obj.(type) {
+	case int64:
+		return int(v), true
+	case int:
+		return v, true
+	case real:
+		f, err := strconv.ParseFloat(string(v), 64)
+		if err != nil {
+			return 0, false
+		}
+		return int(f), true
+	}
+	return 0, false
+}
@@ -0,0 +1,360 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var errSyntax = errors.New("pdf: syntax error")
+
+// maxDepth bounds how deep arrays and dictionaries nest, real documents
+// stay within a few levels and each level is a call of object.
+const maxDepth = 100
+
+// parser reads objects from a pdf file, resolve is used for stream lengths
+// stored as indirect objects.
+type parser struct {
+	data    []byte
+	pos     int
+	resolve func(ref) (object, error)
+	// arrays and dictionaries open around the current position
+	depth int
+}
+
+// enter opens an array or dictionary, leave closes it.
+func (p *parser) enter() error {
+	if p.depth >= maxDepth {
+		return fmt.Errorf("%w: objects nested too deep at %d", errSyntax, p.pos)
+	}
+	p.depth++
+	return nil
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if c == '%' {
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		}
+		if !isSpace(c) {
+			return
+		}
+		p.pos++
+	}
+}
+
+// keyword reads a run of regular characters, such as a number, obj or R.
+func (p *parser) keyword() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if isSpace(c) || isDelimiter(c) {
+			break
+		}
+		p.pos++
+	}
+	return string(p.data[start:p.pos])
+}
+
+func (p *parser) expect(kw string) error {
+	if got := p.keyword(); got != kw {
+		return fmt.Errorf("%w: expected %q at %d, got %q", errSyntax, kw, p.pos, got)
+	}
+	return nil
+}
+
+func (p *parser) object() (object, error) {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, fmt.Errorf("%w: unexpected end of file", errSyntax)
+	}
+	switch c := p.data[p.pos]; c {
+	case '/':
+		p.pos++
+		return p.name(), nil
+	case '(':
+		p.pos++
+		return p.literalString()
+	case '[':
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		p.pos++
+		var arr array
+		for {
+			p.skipSpace()
+			if p.pos >= len(p.data) {
+				return nil, fmt.Errorf("%w: unterminated array", errSyntax)
+			}
+			if p.data[p.pos] == ']' {
+				p.pos++
+				return arr, nil
+			}
+			item, err := p.object()
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, item)
+		}
+	case '<':
+		if p.pos+1 < len(p.data) && p.data[p.pos+1] == '<' {
+			p.pos += 2
+			return p.dict()
+		}
+		p.pos++
+		return p.hexString()
+	case ')', ']', '>', '{', '}':
+		return nil, fmt.Errorf("%w: unexpected %q at %d", errSyntax, c, p.pos)
+	}
+
+	kw := p.keyword()
+	switch kw {
+	case "":
+		return nil, fmt.Errorf("%w: unexpected %q at %d", errSyntax, p.data[p.pos], p.pos)
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	n, err := strconv.ParseInt(kw, 10, 64)
+	if err != nil {
+		if _, err := strconv.ParseFloat(kw, 64); err != nil {
+			return nil, fmt.Errorf("%w: unknown keyword %q at %d", errSyntax, kw, p.pos)
+		}
+		return real(kw), nil
+	}
+	// an integer could start a reference: num gen R
+	save := p.pos
+	gen, err := strconv.ParseInt(p.keyword(), 10, 64)
+	if err == nil && n >= 0 && gen >= 0 && p.keyword() == "R" {
+		return ref{int(n), int(gen)}, nil
+	}
+	p.pos = save
+	return n, nil
+}
+
+func (p *parser) name() name {
+	var buf []byte
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if isSpace(c) || isDelimiter(c) {
+			break
+		}
+		if c == '#' && p.pos+2 < len(p.data) {
+			if b, err := strconv.ParseUint(string(p.data[p.pos+1:p.pos+3]), 16, 8); err == nil {
+				buf = append(buf, byte(b))
+				p.pos += 3
+				continue
+			}
+		}
+		buf = append(buf, c)
+		p.pos++
+	}
+	return name(buf)
+}
+
+func (p *parser) literalString() (object, error) {
+	var buf []byte
+	depth := 1
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return pdfString(buf), nil
+			}
+		case '\\':
+			if p.pos >= len(p.data) {
+				continue
+			}
+			c = p.data[p.pos]
+			p.pos++
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				// line continuation
+				if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+					p.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					v := int(c - '0')
+					for i := 0; i < 2 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; i++ {
+						v = v*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					c = byte(v)
+				}
+			}
+		}
+		buf = append(buf, c)
+	}
+	return nil, fmt.Errorf("%w: unterminated string", errSyntax)
+}
+
+func (p *parser) hexString() (object, error) {
+	var buf []byte
+	var digits []byte
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		if c == '>' {
+			if len(digits)%2 == 1 {
+				digits = append(digits, '0')
+			}
+			for i := 0; i < len(digits); i += 2 {
+				b, _ := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+				buf = append(buf, byte(b))
+			}
+			return pdfString(buf), nil
+		}
+		if isSpace(c) {
+			continue
+		}
+		if _, err := strconv.ParseUint(string(c), 16, 8); err != nil {
+			return nil, fmt.Errorf("%w: bad hex string", errSyntax)
+		}
+		digits = append(digits, c)
+	}
+	return nil, fmt.Errorf("%w: unterminated hex string", errSyntax)
+}
+
+func (p *parser) dict() (object, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	d := dict{}
+	for {
+		p.skipSpace()
+		if p.pos+1 >= len(p.data) {
+			return nil, fmt.Errorf("%w: unterminated dictionary", errSyntax)
+		}
+		if p.data[p.pos] == '>' && p.data[p.pos+1] == '>' {
+			p.pos += 2
+			return d, nil
+		}
+		if p.data[p.pos] != '/' {
+			return nil, fmt.Errorf("%w: dictionary key is not a name at %d", errSyntax, p.pos)
+		}
+		p.pos++
+		key := p.name()
+		value, err := p.object()
+		if err != nil {
+			return nil, err
+		}
+		// a null value is the same as a missing key
+		if value != nil {
+			d[key] = value
+		}
+	}
+}
+
+// indirect reads "num gen obj ... endobj" at the current position.
+func (p *parser) indirect() (ref, object, error) {
+	num, err := strconv.Atoi(p.keyword())
+	if err != nil {
+		return ref{}, nil, fmt.Errorf("%w: expected object number at %d", errSyntax, p.pos)
+	}
+	gen, err := strconv.Atoi(p.keyword())
+	if err != nil {
+		return ref{}, nil, fmt.Errorf("%w: expected generation at %d", errSyntax, p.pos)
+	}
+	if err := p.expect("obj"); err != nil {
+		return ref{}, nil, err
+	}
+	obj, err := p.object()
+	if err != nil {
+		return ref{}, nil, err
+	}
+	id := ref{num, gen}
+	hdr, ok := obj.(dict)
+	if !ok {
+		return id, obj, nil
+	}
+	save := p.pos
+	if p.keyword() != "stream" {
+		p.pos = save
+		return id, obj, nil
+	}
+	// the data starts after CRLF or LF
+	if p.pos < len(p.data) && p.data[p.pos] == '\r' {
+		p.pos++
+	}
+	if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+		p.pos++
+	}
+	data, err := p.streamData(hdr)
+	if err != nil {
+		return ref{}, nil, err
+	}
+	return id, &stream{hdr: hdr, data: data}, nil
+}
+
+// streamData trusts /Length when endstream follows it and searches for
+// endstream otherwise, lengths are often wrong in files from phone apps.
+func (p *parser) streamData(hdr dict) ([]byte, error) {
+	start := p.pos
+	length := -1
+	switch v := hdr["Length"].(type) {
+	case ref:
+		if p.resolve != nil {
+			if obj, err := p.resolve(v); err == nil {
+				if n, ok := toInt(obj); ok {
+					length = n
+				}
+			}
+		}
+	default:
+		if n, ok := toInt(v); ok {
+			length = n
+		}
+	}
+	if length >= 0 && start+length <= len(p.data) {
+		rest := bytes.TrimLeft(p.data[start+length:min(start+length+16, len(p.data))], " \t\r\n\f\x00")
+		if bytes.HasPrefix(rest, []byte("endstream")) {
+			p.pos = start + length
+			p.expect("endstream")
+			return p.data[start : start+length], nil
+		}
+	}
+	end := bytes.Index(p.data[start:], []byte("endstream"))
+	if end < 0 {
+		return nil, fmt.Errorf("%w: missing endstream", errSyntax)
+	}
+	data := p.data[start : start+end]
+	p.pos = start + end + len("endstream")
+	// the end of line before endstream isn't part of the data
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	data = bytes.TrimSuffix(data, []byte("\r"))
+	return data, nil
+}
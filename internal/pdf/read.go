@@ -0,0 +1,551 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+var (
+	ErrEncrypted  = errors.New("pdf: encrypted documents are not supported")
+	ErrNoPages    = errors.New("pdf: document has no pages")
+	ErrStreamSize = errors.New("pdf: stream is too big")
+)
+
+// maxStreamSize bounds what a compressed stream may inflate to, as much as
+// the biggest answer upload, so a small upload can't inflate into gigabytes.
+const maxStreamSize = 60 << 20
+
+// xrefEntry locates an object either at an offset in the file or inside an
+// object stream.
+type xrefEntry struct {
+	offset    int
+	inStream  bool
+	streamNum int
+	index     int
+}
+
+type reader struct {
+	data    []byte
+	xref    map[int]xrefEntry
+	trailer dict
+	cache   map[int]object
+	// object streams already split into their objects
+	objStms map[int][]object
+}
+
+func newReader(data []byte) (*reader, error) {
+	r := &reader{
+		data:    data,
+		xref:    map[int]xrefEntry{},
+		cache:   map[int]object{},
+		objStms: map[int][]object{},
+	}
+	err := r.readXref()
+	if err != nil {
+		// damaged cross reference tables are common enough to rebuild them
+		r.xref = map[int]xrefEntry{}
+		r.trailer = nil
+		if err := r.rebuildXref(); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := r.trailer["Encrypt"]; ok {
+		return nil, ErrEncrypted
+	}
+	return r, nil
+}
+
+func (r *reader) readXref() error {
+	i := bytes.LastIndex(r.data, []byte("startxref"))
+	if i < 0 {
+		return fmt.Errorf("%w: missing startxref", errSyntax)
+	}
+	p := &parser{data: r.data, pos: i + len("startxref")}
+	offset, err := strconv.Atoi(p.keyword())
+	if err != nil {
+		return fmt.Errorf("%w: bad startxref", errSyntax)
+	}
+	seen := map[int]bool{}
+	for {
+		if offset <= 0 || offset >= len(r.data) || seen[offset] {
+			return fmt.Errorf("%w: bad xref offset %d", errSyntax, offset)
+		}
+		seen[offset] = true
+		trailer, err := r.readXrefSection(offset)
+		if err != nil {
+			return err
+		}
+		if r.trailer == nil {
+			r.trailer = trailer
+		}
+		// hybrid files keep the newer objects in an xref stream
+		if stm, ok := toInt(trailer["XRefStm"]); ok && !seen[stm] {
+			seen[stm] = true
+			if _, err := r.readXrefSection(stm); err != nil {
+				return err
+			}
+		}
+		prev, ok := toInt(trailer["Prev"])
+		if !ok {
+			break
+		}
+		offset = prev
+	}
+	if _, ok := r.trailer["Root"].(ref); !ok {
+		return fmt.Errorf("%w: trailer has no root", errSyntax)
+	}
+	return nil
+}
+
+// readXrefSection reads a table or an xref stream, entries already known
+// come from a newer update and are kept.
+func (r *reader) readXrefSection(offset int) (dict, error) {
+	p := &parser{data: r.data, pos: offset}
+	save := p.pos
+	if p.keyword() != "xref" {
+		p.pos = save
+		return r.readXrefStream(p)
+	}
+	for {
+		kw := p.keyword()
+		if kw == "trailer" {
+			break
+		}
+		start, err := strconv.Atoi(kw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: bad xref subsection", errSyntax)
+		}
+		count, err := strconv.Atoi(p.keyword())
+		if err != nil {
+			return nil, fmt.Errorf("%w: bad xref subsection", errSyntax)
+		}
+		for i := 0; i < count; i++ {
+			off, err1 := strconv.Atoi(p.keyword())
+			_, err2 := strconv.Atoi(p.keyword())
+			kind := p.keyword()
+			if err1 != nil || err2 != nil || (kind != "n" && kind != "f") {
+				return nil, fmt.Errorf("%w: bad xref entry", errSyntax)
+			}
+			if _, ok := r.xref[start+i]; ok {
+				continue
+			}
+			if kind == "n" {
+				r.xref[start+i] = xrefEntry{offset: off}
+			} else {
+				r.xref[start+i] = xrefEntry{offset: -1}
+			}
+		}
+	}
+	obj, err := p.object()
+	if err != nil {
+		return nil, err
+	}
+	trailer, ok := obj.(dict)
+	if !ok {
+		return nil, fmt.Errorf("%w: trailer is not a dictionary", errSyntax)
+	}
+	return trailer, nil
+}
+
+func (r *reader) readXrefStream(p *parser) (dict, error) {
+	_, obj, err := p.indirect()
+	if err != nil {
+		return nil, err
+	}
+	s, ok := obj.(*stream)
+	if !ok || s.hdr["Type"] != name("XRef") {
+		return nil, fmt.Errorf("%w: expected xref stream", errSyntax)
+	}
+	data, err := decodeStream(s)
+	if err != nil {
+		return nil, err
+	}
+	w, _ := s.hdr["W"].(array)
+	if len(w) != 3 {
+		return nil, fmt.Errorf("%w: bad xref stream widths", errSyntax)
+	}
+	var widths [3]int
+	for i := range widths {
+		n, ok := toInt(w[i])
+		if !ok || n < 0 || n > 8 {
+			return nil, fmt.Errorf("%w: bad xref stream widths", errSyntax)
+		}
+		widths[i] = n
+	}
+	rowLen := widths[0] + widths[1] + widths[2]
+	if rowLen == 0 {
+		return nil, fmt.Errorf("%w: bad xref stream widths", errSyntax)
+	}
+	index, _ := s.hdr["Index"].(array)
+	if index == nil {
+		size, _ := toInt(s.hdr["Size"])
+		index = array{int64(0), int64(size)}
+	}
+	pos := 0
+	for i := 0; i+1 < len(index); i += 2 {
+		start, _ := toInt(index[i])
+		count, _ := toInt(index[i+1])
+		for j := 0; j < count; j++ {
+			if pos+rowLen > len(data) {
+				return s.hdr, nil
+			}
+			row := data[pos : pos+rowLen]
+			pos += rowLen
+			field := func(k int) int {
+				off := 0
+				for m := 0; m < k; m++ {
+					off += widths[m]
+				}
+				v := 0
+				for _, b := range row[off : off+widths[k]] {
+					v = v<<8 | int(b)
+				}
+				return v
+			}
+			kind := 1
+			if widths[0] > 0 {
+				kind = field(0)
+			}
+			num := start + j
+			if _, ok := r.xref[num]; ok {
+				continue
+			}
+			switch kind {
+			case 0:
+				r.xref[num] = xrefEntry{offset: -1}
+			case 1:
+				r.xref[num] = xrefEntry{offset: field(1)}
+			case 2:
+				r.xref[num] = xrefEntry{inStream: true, streamNum: field(1), index: field(2)}
+			}
+		}
+	}
+	return s.hdr, nil
+}
+
+var objHeader = regexp.MustCompile(`(?m)(?:^|[\r\n\s])(\d+)\s+(\d+)\s+obj\b`)
+
+// rebuildXref finds objects by scanning the whole file.
+func (r *reader) rebuildXref() error {
+	for _, m := range objHeader.FindAllSubmatchIndex(r.data, -1) {
+		num, err := strconv.Atoi(string(r.data[m[2]:m[3]]))
+		if err != nil {
+			continue
+		}
+		// later definitions replace earlier ones
+		r.xref[num] = xrefEntry{offset: m[2]}
+	}
+	if i := bytes.LastIndex(r.data, []byte("trailer")); i >= 0 {
+		p := &parser{data: r.data, pos: i + len("trailer")}
+		if obj, err := p.object(); err == nil {
+			r.trailer, _ = obj.(dict)
+		}
+	}
+	if _, ok := r.trailer["Root"].(ref); ok {
+		return nil
+	}
+	// files with xref streams have no trailer, look for the catalog instead
+	for num := range r.xref {
+		obj, err := r.load(num)
+		if err != nil {
+			continue
+		}
+		if s, ok := obj.(*stream); ok && s.hdr["Type"] == name("XRef") {
+			if root, ok := s.hdr["Root"].(ref); ok {
+				r.trailer = s.hdr
+				r.trailer["Root"] = root
+				return nil
+			}
+		}
+		if d, ok := obj.(dict); ok && d["Type"] == name("Catalog") {
+			r.trailer = dict{"Root": ref{num, 0}}
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: can't find the document catalog", errSyntax)
+}
+
+func (r *reader) resolve(obj object) (object, error) {
+	id, ok := obj.(ref)
+	if !ok {
+		return obj, nil
+	}
+	return r.load(id.num)
+}
+
+// load returns the object with the given number, missing objects are null.
+func (r *reader) load(num int) (object, error) {
+	if obj, ok := r.cache[num]; ok {
+		return obj, nil
+	}
+	entry, ok := r.xref[num]
+	if !ok || (!entry.inStream && entry.offset < 0) {
+		return nil, nil
+	}
+	if !entry.inStream && entry.offset >= len(r.data) {
+		return nil, fmt.Errorf("%w: object %d is out of range", errSyntax, num)
+	}
+	// guard against loops through stream lengths
+	r.cache[num] = nil
+	var obj object
+	var err error
+	if entry.inStream {
+		obj, err = r.loadFromStream(entry)
+	} else {
+		p := &parser{data: r.data, pos: entry.offset, resolve: func(id ref) (object, error) {
+			return r.load(id.num)
+		}}
+		_, obj, err = p.indirect()
+	}
+	if err != nil {
+		delete(r.cache, num)
+		return nil, fmt.Errorf("object %d: %w", num, err)
+	}
+	r.cache[num] = obj
+	return obj, nil
+}
+
+func (r *reader) loadFromStream(entry xrefEntry) (object, error) {
+	objs, ok := r.objStms[entry.streamNum]
+	if !ok {
+		obj, err := r.load(entry.streamNum)
+		if err != nil {
+			return nil, err
+		}
+		s, ok := obj.(*stream)
+		if !ok {
+			return nil, fmt.Errorf("%w: object stream %d is not a stream", errSyntax, entry.streamNum)
+		}
+		data, err := decodeStream(s)
+		if err != nil {
+			return nil, err
+		}
+		n, _ := toInt(s.hdr["N"])
+		first, _ := toInt(s.hdr["First"])
+		if first < 0 || first > len(data) {
+			return nil, fmt.Errorf("%w: bad object stream", errSyntax)
+		}
+		// every entry takes at least two bytes of the header
+		if n < 0 || n > first/2 {
+			return nil, fmt.Errorf("%w: bad object stream", errSyntax)
+		}
+		header := &parser{data: data[:first]}
+		offsets := make([]int, 0, n)
+		for i := 0; i < n; i++ {
+			header.keyword()
+			off, err := strconv.Atoi(header.keyword())
+			if err != nil || off < 0 {
+				return nil, fmt.Errorf("%w: bad object stream header", errSyntax)
+			}
+			offsets = append(offsets, off)
+		}
+		objs = make([]object, len(offsets))
+		for i, off := range offsets {
+			p := &parser{data: data, pos: first + off}
+			if p.pos >= len(data) {
+				continue
+			}
+			objs[i], err = p.object()
+			if err != nil {
+				return nil, err
+			}
+		}
+		r.objStms[entry.streamNum] = objs
+	}
+	if entry.index < 0 || entry.index >= len(objs) {
+		return nil, nil
+	}
+	return objs[entry.index], nil
+}
+
+// decodeStream decodes the filters used by xref and object streams.
+func decodeStream(s *stream) ([]byte, error) {
+	filter := s.hdr["Filter"]
+	params := s.hdr["DecodeParms"]
+	if arr, ok := filter.(array); ok {
+		if len(arr) > 1 {
+			return nil, fmt.Errorf("pdf: unsupported filter chain %v", arr)
+		}
+		filter = nil
+		if len(arr) == 1 {
+			filter = arr[0]
+		}
+		if p, ok := params.(array); ok && len(p) > 0 {
+			params = p[0]
+		}
+	}
+	switch filter {
+	case nil:
+		return s.data, nil
+	case name("FlateDecode"):
+		zr, err := zlib.NewReader(bytes.NewReader(s.data))
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(io.LimitReader(zr, maxStreamSize+1))
+		if len(data) > maxStreamSize {
+			return nil, ErrStreamSize
+		}
+		// some writers leave out the checksum
+		if err != nil && len(data) == 0 {
+			return nil, err
+		}
+		p, _ := params.(dict)
+		return unpredict(data, p)
+	default:
+		return nil, fmt.Errorf("pdf: unsupported filter %v", filter)
+	}
+}
+
+// unpredict reverses the png predictors xref streams are usually written
+// with.
+func unpredict(data []byte, params dict) ([]byte, error) {
+	predictor, _ := toInt(params["Predictor"])
+	if predictor < 10 {
+		return data, nil
+	}
+	columns, ok := toInt(params["Columns"])
+	if !ok {
+		columns = 1
+	}
+	colors, ok := toInt(params["Colors"])
+	if !ok {
+		colors = 1
+	}
+	bpc, ok := toInt(params["BitsPerComponent"])
+	if !ok {
+		bpc = 8
+	}
+	bpp := max((colors*bpc+7)/8, 1)
+	rowLen := (columns*colors*bpc + 7) / 8
+	if rowLen <= 0 {
+		return nil, errors.New("pdf: bad predictor parameters")
+	}
+	out := make([]byte, 0, len(data))
+	prev := make([]byte, rowLen)
+	for pos := 0; pos+1+rowLen <= len(data); pos += rowLen + 1 {
+		kind := data[pos]
+		row := append([]byte(nil), data[pos+1:pos+1+rowLen]...)
+		for i := range row {
+			var left, up, upLeft byte
+			if i >= bpp {
+				left = row[i-bpp]
+				upLeft = prev[i-bpp]
+			}
+			up = prev[i]
+			switch kind {
+			case 0:
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			default:
+				return nil, fmt.Errorf("pdf: bad png predictor %d", kind)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// inherited page attributes that have to be copied to each page.
+var inheritable = []name{"Resources", "MediaBox", "CropBox", "Rotate"}
+
+// pages returns the page dictionaries in order with the inherited
+// attributes filled in.
+func (r *reader) pages() ([]dict, error) {
+	root, err := r.resolve(r.trailer["Root"])
+	if err != nil {
+		return nil, err
+	}
+	catalog, ok := root.(dict)
+	if !ok {
+		return nil, fmt.Errorf("%w: catalog is not a dictionary", errSyntax)
+	}
+	var pages []dict
+	seen := map[ref]bool{}
+	var walk func(node object, inherited dict) error
+	walk = func(node object, inherited dict) error {
+		if id, ok := node.(ref); ok {
+			if seen[id] {
+				return fmt.Errorf("%w: loop in page tree", errSyntax)
+			}
+			seen[id] = true
+		}
+		obj, err := r.resolve(node)
+		if err != nil {
+			return err
+		}
+		d, ok := obj.(dict)
+		if !ok {
+			return nil
+		}
+		attrs := dict{}
+		for k, v := range inherited {
+			attrs[k] = v
+		}
+		for _, k := range inheritable {
+			if v, ok := d[k]; ok {
+				attrs[k] = v
+			}
+		}
+		kids, err := r.resolve(d["Kids"])
+		if err != nil {
+			return err
+		}
+		if d["Type"] == name("Pages") || kids != nil {
+			arr, _ := kids.(array)
+			for _, kid := range arr {
+				if err := walk(kid, attrs); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		page := dict{}
+		for k, v := range d {
+			page[k] = v
+		}
+		for k, v := range attrs {
+			page[k] = v
+		}
+		pages = append(pages, page)
+		return nil
+	}
+	if err := walk(catalog["Pages"], dict{}); err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, ErrNoPages
+	}
+	return pages, nil
+}
@@ -0,0 +1,80 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/alghurabi0/rehla/internal/assert"
+)
+
+func deflate(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	_, err := zw.Write(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = zw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeStream(t *testing.T) {
+	hdr := dict{"Filter": name("FlateDecode")}
+	data, err := decodeStream(&stream{hdr: hdr, data: deflate(t, []byte("1 0 obj"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(data), "1 0 obj")
+
+	// a deflate bomb, a small stream that inflates past the limit
+	bomb := deflate(t, make([]byte, maxStreamSize+1))
+	_, err = decodeStream(&stream{hdr: hdr, data: bomb})
+	assert.Equal(t, err, ErrStreamSize)
+}
+
+func TestLoadFromStreamRejectsBadHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+	}{
+		{"huge count", "/N 1000000000 /First 4"},
+		{"negative count", "/N -1 /First 4"},
+		{"negative offset", "/N 1 /First 8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := "3 -100 << >>"
+			doc := "%PDF-1.4\n1 0 obj\n<< /Type /Catalog >>\nendobj\n" +
+				"2 0 obj\n<< /Type /ObjStm " + tt.header + " /Length " + strconv.Itoa(len(body)) + " >>\nstream\n" +
+				body + "\nendstream\nendobj\n"
+			r, err := newReader([]byte(doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = r.loadFromStream(xrefEntry{inStream: true, streamNum: 2})
+			if !errors.Is(err, errSyntax) {
+				t.Errorf("got %v; want a syntax error", err)
+			}
+		})
+	}
+}
+
+func TestParserNestingLimit(t *testing.T) {
+	p := &parser{data: []byte(strings.Repeat("[", maxDepth) + strings.Repeat("]", maxDepth))}
+	_, err := p.object()
+	assert.Equal(t, err, nil)
+
+	p = &parser{data: []byte(strings.Repeat("[<< /A ", 1<<20))}
+	_, err = p.object()
+	if !errors.Is(err, errSyntax) {
+		t.Errorf("got %v; want a syntax error", err)
+	}
+}
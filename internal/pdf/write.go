@@ -0,0 +1,163 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+)
+
+type writer struct {
+	buf     bytes.Buffer
+	offsets map[int]int
+	next    int
+}
+
+func newWriter() *writer {
+	w := &writer{offsets: map[int]int{}, next: 1}
+	// the binary comment marks the file as binary for transfer tools
+	w.buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	return w
+}
+
+func (w *writer) alloc() ref {
+	id := ref{w.next, 0}
+	w.next++
+	return id
+}
+
+func (w *writer) write(id ref, obj object) {
+	w.offsets[id.num] = w.buf.Len()
+	fmt.Fprintf(&w.buf, "%d 0 obj\n", id.num)
+	writeObject(&w.buf, obj)
+	w.buf.WriteString("\nendobj\n")
+}
+
+func (w *writer) add(obj object) ref {
+	id := w.alloc()
+	w.write(id, obj)
+	return id
+}
+
+// finish writes the cross reference table and returns the file, objects
+// that were allocated but never written are written as null.
+func (w *writer) finish(root ref) []byte {
+	for num := 1; num < w.next; num++ {
+		if _, ok := w.offsets[num]; !ok {
+			w.write(ref{num, 0}, nil)
+		}
+	}
+	start := w.buf.Len()
+	fmt.Fprintf(&w.buf, "xref\n0 %d\n0000000000 65535 f\r\n", w.next)
+	for num := 1; num < w.next; num++ {
+		fmt.Fprintf(&w.buf, "%010d 00000 n\r\n", w.offsets[num])
+	}
+	w.buf.WriteString("trailer\n")
+	writeObject(&w.buf, dict{"Size": w.next, "Root": root})
+	fmt.Fprintf(&w.buf, "\nstartxref\n%d\n%%%%EOF\n", start)
+	return w.buf.Bytes()
+}
+
+// copier copies objects from a document being merged, each source object
+// is written once no matter how many pages share it.
+type copier struct {
+	r       *reader
+	w       *writer
+	refs    map[int]ref
+	pending []int
+}
+
+func newCopier(r *reader, w *writer) *copier {
+	return &copier{r: r, w: w, refs: map[int]ref{}}
+}
+
+// page copies a page and everything it uses under the new page tree.
+// Annotations are left out since they can point to the rest of the source
+// document.
+func (c *copier) page(page dict, parent ref) (ref, error) {
+	out := dict{}
+	for k, v := range page {
+		switch k {
+		case "Parent", "Annots", "B", "StructParents":
+			continue
+		}
+		copied, err := c.copy(v)
+		if err != nil {
+			return ref{}, err
+		}
+		out[k] = copied
+	}
+	out["Type"] = name("Page")
+	out["Parent"] = parent
+	if _, ok := out["MediaBox"]; !ok {
+		out["MediaBox"] = array{0, 0, 612, 792}
+	}
+	id := c.w.add(out)
+	return id, c.flush()
+}
+
+// copy returns obj with its references renumbered for the new file, the
+// referenced objects are written by flush.
+func (c *copier) copy(obj object) (object, error) {
+	switch v := obj.(type) {
+	case ref:
+		if id, ok := c.refs[v.num]; ok {
+			return id, nil
+		}
+		id := c.w.alloc()
+		c.refs[v.num] = id
+		c.pending = append(c.pending, v.num)
+		return id, nil
+	case array:
+		out := make(array, len(v))
+		for i, item := range v {
+			copied, err := c.copy(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = copied
+		}
+		return out, nil
+	case dict:
+		out := make(dict, len(v))
+		for k, item := range v {
+			copied, err := c.copy(item)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = copied
+		}
+		return out, nil
+	case *stream:
+		hdr := make(dict, len(v.hdr))
+		for k, item := range v.hdr {
+			// the length is written again from the data
+			if k == "Length" {
+				continue
+			}
+			copied, err := c.copy(item)
+			if err != nil {
+				return nil, err
+			}
+			hdr[k] = copied
+		}
+		return &stream{hdr: hdr, data: v.data}, nil
+	default:
+		return obj, nil
+	}
+}
+
+func (c *copier) flush() error {
+	for len(c.pending) > 0 {
+		num := c.pending[0]
+		c.pending = c.pending[1:]
+		obj, err := c.r.load(num)
+		if err != nil {
+			return err
+		}
+		copied, err := c.copy(obj)
+		if err != nil {
+			return err
+		}
+		c.w.write(c.refs[num], copied)
+	}
+	return nil
+}
@@ -0,0 +1,454 @@
+// Package qr encodes short texts like links as QR codes. It only writes
+// byte mode at the medium error correction level, enough for the links
+// printed on certificates.
+package qr
+
+import "errors"
+
+var ErrTooLong = errors.New("qr: text is too long")
+
+// block layout of each version at the medium error correction level
+type version struct {
+	ecPerBlock int
+	// data codewords of each block, the blocks of the second group hold one
+	// more than the first
+	blocks []int
+	// centers of the alignment patterns on each axis
+	align []int
+}
+
+var versions = []version{
+	1:  {10, []int{16}, nil},
+	2:  {16, []int{28}, []int{6, 18}},
+	3:  {26, []int{44}, []int{6, 22}},
+	4:  {18, []int{32, 32}, []int{6, 26}},
+	5:  {24, []int{43, 43}, []int{6, 30}},
+	6:  {16, []int{27, 27, 27, 27}, []int{6, 34}},
+	7:  {18, []int{31, 31, 31, 31}, []int{6, 22, 38}},
+	8:  {22, []int{38, 38, 39, 39}, []int{6, 24, 42}},
+	9:  {22, []int{36, 36, 36, 37, 37}, []int{6, 26, 46}},
+	10: {26, []int{43, 43, 43, 43, 44}, []int{6, 28, 50}},
+}
+
+func (v version) dataCodewords() int {
+	n := 0
+	for _, b := range v.blocks {
+		n += b
+	}
+	return n
+}
+
+// Code is the grid of modules of a QR code, without the quiet zone around
+// it.
+type Code struct {
+	Size    int
+	modules []bool
+}
+
+// Black reports whether the module in column x and row y is dark.
+func (c *Code) Black(x, y int) bool {
+	return c.modules[y*c.Size+x]
+}
+
+// Encode returns the smallest code that holds text.
+func Encode(text string) (*Code, error) {
+	for v := 1; v < len(versions); v++ {
+		data, ok := encodeData([]byte(text), v)
+		if !ok {
+			continue
+		}
+		return build(v, interleave(data, versions[v])), nil
+	}
+	return nil, ErrTooLong
+}
+
+type bitWriter struct {
+	bytes []byte
+	n     int
+}
+
+func (w *bitWriter) write(value, bits int) {
+	for i := bits - 1; i >= 0; i-- {
+		if w.n%8 == 0 {
+			w.bytes = append(w.bytes, 0)
+		}
+		if value>>i&1 == 1 {
+			w.bytes[w.n/8] |= 0x80 >> (w.n % 8)
+		}
+		w.n++
+	}
+}
+
+// encodeData writes text in byte mode and pads it to the data capacity of
+// the version, false when it doesn't fit.
+func encodeData(text []byte, v int) ([]byte, bool) {
+	capacity := versions[v].dataCodewords()
+	countBits := 8
+	if v >= 10 {
+		countBits = 16
+	}
+	if 4+countBits+len(text)*8 > capacity*8 {
+		return nil, false
+	}
+	var w bitWriter
+	w.write(0b0100, 4)
+	w.write(len(text), countBits)
+	for _, b := range text {
+		w.write(int(b), 8)
+	}
+	// the terminator is cut short when the text nearly fills the code
+	w.write(0, min(4, capacity*8-w.n))
+	if w.n%8 != 0 {
+		w.write(0, 8-w.n%8)
+	}
+	for pad := 0xec; len(w.bytes) < capacity; pad ^= 0xec ^ 0x11 {
+		w.bytes = append(w.bytes, byte(pad))
+	}
+	return w.bytes, true
+}
+
+// interleave splits the data in blocks, adds the error correction of each
+// and takes the codewords of the blocks in turn.
+func interleave(data []byte, v version) []byte {
+	var blocks, ecs [][]byte
+	for _, n := range v.blocks {
+		blocks = append(blocks, data[:n])
+		ecs = append(ecs, reedSolomon(data[:n], v.ecPerBlock))
+		data = data[n:]
+	}
+	var out []byte
+	for i := 0; i < v.blocks[len(v.blocks)-1]; i++ {
+		for _, b := range blocks {
+			if i < len(b) {
+				out = append(out, b[i])
+			}
+		}
+	}
+	for i := 0; i < v.ecPerBlock; i++ {
+		for _, ec := range ecs {
+			out = append(out, ec[i])
+		}
+	}
+	return out
+}
+
+// gfMul multiplies in GF(256) modulo x^8+x^4+x^3+x^2+1.
+func gfMul(a, b byte) byte {
+	var p byte
+	for b > 0 {
+		if b&1 == 1 {
+			p ^= a
+		}
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 0x1d
+		}
+		b >>= 1
+	}
+	return p
+}
+
+// reedSolomon returns the n error correction codewords of data.
+func reedSolomon(data []byte, n int) []byte {
+	// generator (x - 2^0)(x - 2^1)...(x - 2^(n-1)), highest power left out
+	gen := make([]byte, n)
+	gen[n-1] = 1
+	var root byte = 1
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			gen[j] = gfMul(gen[j], root)
+			if j+1 < n {
+				gen[j] ^= gen[j+1]
+			}
+		}
+		root = gfMul(root, 2)
+	}
+	rem := make([]byte, n)
+	for _, b := range data {
+		factor := b ^ rem[0]
+		copy(rem, rem[1:])
+		rem[n-1] = 0
+		for j := range rem {
+			rem[j] ^= gfMul(gen[j], factor)
+		}
+	}
+	return rem
+}
+
+type grid struct {
+	size     int
+	modules  []bool
+	function []bool
+}
+
+func (g *grid) set(x, y int, black bool) {
+	g.modules[y*g.size+x] = black
+}
+
+func (g *grid) setFunction(x, y int, black bool) {
+	g.set(x, y, black)
+	g.function[y*g.size+x] = true
+}
+
+func (g *grid) get(x, y int) bool {
+	return g.modules[y*g.size+x]
+}
+
+// build lays out the function patterns and codewords and applies the mask
+// with the lowest penalty.
+func build(v int, codewords []byte) *Code {
+	size := v*4 + 17
+	g := &grid{size: size, modules: make([]bool, size*size), function: make([]bool, size*size)}
+	g.drawFunctionPatterns(v)
+	g.drawCodewords(codewords)
+
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		g.applyMask(mask)
+		g.drawFormat(mask)
+		penalty := g.penalty()
+		if bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		// masking twice undoes it
+		g.applyMask(mask)
+	}
+	g.applyMask(best)
+	g.drawFormat(best)
+	return &Code{Size: size, modules: g.modules}
+}
+
+func (g *grid) drawFunctionPatterns(v int) {
+	for i := 0; i < g.size; i++ {
+		g.setFunction(6, i, i%2 == 0)
+		g.setFunction(i, 6, i%2 == 0)
+	}
+	g.drawFinder(3, 3)
+	g.drawFinder(g.size-4, 3)
+	g.drawFinder(3, g.size-4)
+
+	align := versions[v].align
+	last := len(align) - 1
+	for i, x := range align {
+		for j, y := range align {
+			// the corners with finder patterns
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					g.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// reserve the format areas, they're drawn with the mask
+	g.drawFormat(0)
+	if v >= 7 {
+		bits := versionBits(v)
+		for i := 0; i < 18; i++ {
+			black := bits>>i&1 == 1
+			a, b := g.size-11+i%3, i/3
+			g.setFunction(a, b, black)
+			g.setFunction(b, a, black)
+		}
+	}
+}
+
+// versionBits returns the 18 version bits that codes from version 7 on
+// carry.
+func versionBits(v int) int {
+	rem := v
+	for i := 0; i < 12; i++ {
+		rem = rem<<1 ^ (rem>>11)*0x1f25
+	}
+	return v<<12 | rem
+}
+
+// drawFinder draws the finder pattern centered on x, y with its separator.
+func (g *grid) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || xx >= g.size || yy < 0 || yy >= g.size {
+				continue
+			}
+			d := max(abs(dx), abs(dy))
+			g.setFunction(xx, yy, d != 2 && d != 4)
+		}
+	}
+}
+
+// formatBits returns the 15 format bits of the medium level and the mask.
+func formatBits(mask int) int {
+	// the medium level is 00
+	data := mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+func (g *grid) drawFormat(mask int) {
+	bits := formatBits(mask)
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+	for i := 0; i <= 5; i++ {
+		g.setFunction(8, i, bit(i))
+	}
+	g.setFunction(8, 7, bit(6))
+	g.setFunction(8, 8, bit(7))
+	g.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		g.setFunction(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		g.setFunction(g.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		g.setFunction(8, g.size-15+i, bit(i))
+	}
+	// always dark
+	g.setFunction(8, g.size-8, true)
+}
+
+// drawCodewords fills the modules left by the function patterns in the
+// zigzag of two columns from the bottom right.
+func (g *grid) drawCodewords(data []byte) {
+	i := 0
+	for right := g.size - 1; right >= 1; right -= 2 {
+		// the vertical timing pattern
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < g.size; vert++ {
+			y := vert
+			if upward {
+				y = g.size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if g.function[y*g.size+x] {
+					continue
+				}
+				// the remainder bits are left light
+				if i < len(data)*8 {
+					g.set(x, y, data[i/8]>>(7-i%8)&1 == 1)
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (g *grid) applyMask(mask int) {
+	for y := 0; y < g.size; y++ {
+		for x := 0; x < g.size; x++ {
+			if g.function[y*g.size+x] {
+				continue
+			}
+			var flip bool
+			switch mask {
+			case 0:
+				flip = (x+y)%2 == 0
+			case 1:
+				flip = y%2 == 0
+			case 2:
+				flip = x%3 == 0
+			case 3:
+				flip = (x+y)%3 == 0
+			case 4:
+				flip = (x/3+y/2)%2 == 0
+			case 5:
+				flip = x*y%2+x*y%3 == 0
+			case 6:
+				flip = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				flip = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if flip {
+				g.set(x, y, !g.get(x, y))
+			}
+		}
+	}
+}
+
+// penalty scores how hard the code is to scan: long runs, 2x2 blocks,
+// patterns that look like finders and an uneven share of dark modules.
+func (g *grid) penalty() int {
+	penalty := 0
+	line := make([]bool, g.size)
+	for _, vertical := range []bool{false, true} {
+		for a := 0; a < g.size; a++ {
+			for b := 0; b < g.size; b++ {
+				if vertical {
+					line[b] = g.get(a, b)
+				} else {
+					line[b] = g.get(b, a)
+				}
+			}
+			penalty += linePenalty(line)
+		}
+	}
+	dark := 0
+	for y := 0; y < g.size; y++ {
+		for x := 0; x < g.size; x++ {
+			c := g.get(x, y)
+			if c {
+				dark++
+			}
+			if x+1 < g.size && y+1 < g.size && c == g.get(x+1, y) && c == g.get(x, y+1) && c == g.get(x+1, y+1) {
+				penalty += 3
+			}
+		}
+	}
+	// 10 for every 5% away from half dark
+	total := g.size * g.size
+	penalty += ((abs(dark*20-total*10)+total-1)/total - 1) * 10
+	return penalty
+}
+
+var finderLike = [][]bool{
+	{true, false, true, true, true, false, true, false, false, false, false},
+	{false, false, false, false, true, false, true, true, true, false, true},
+}
+
+func linePenalty(line []bool) int {
+	penalty := 0
+	run := 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			penalty += run - 2
+		}
+		run = 1
+	}
+	for i := 0; i+11 <= len(line); i++ {
+		for _, pattern := range finderLike {
+			match := true
+			for j, black := range pattern {
+				if line[i+j] != black {
+					match = false
+					break
+				}
+			}
+			if match {
+				penalty += 40
+			}
+		}
+	}
+	return penalty
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
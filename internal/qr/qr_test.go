@@ -0,0 +1,158 @@
+package qr
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/alghurabi0/rehla/internal/assert"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// HELLO WORLD at version 1-M
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	assert.Equal(t, fmt.Sprint(reedSolomon(data, 10)), "[196 35 39 119 235 215 231 226 93 23]")
+}
+
+func TestFormatBits(t *testing.T) {
+	assert.Equal(t, fmt.Sprintf("%015b", formatBits(0)), "101010000010010")
+	assert.Equal(t, fmt.Sprintf("%015b", formatBits(5)), "100000011001110")
+	assert.Equal(t, fmt.Sprintf("%018b", versionBits(7)), "000111110010010100")
+}
+
+func TestEncodeData(t *testing.T) {
+	data, ok := encodeData([]byte("hi"), 1)
+	assert.Equal(t, ok, true)
+	assert.Equal(t, fmt.Sprintf("% x", data), "40 26 86 90 ec 11 ec 11 ec 11 ec 11 ec 11 ec 11")
+
+	// 14 bytes fill version 1 up to the terminator
+	_, ok = encodeData([]byte(strings.Repeat("a", 14)), 1)
+	assert.Equal(t, ok, true)
+	_, ok = encodeData([]byte(strings.Repeat("a", 15)), 1)
+	assert.Equal(t, ok, false)
+}
+
+// read decodes the code back to its text the way a scanner would.
+func read(t *testing.T, c *Code) string {
+	t.Helper()
+	v := (c.Size - 17) / 4
+
+	// the format bits around the top left finder
+	var bits int
+	for i := 0; i <= 5; i++ {
+		bits |= b2i(c.Black(8, i)) << i
+	}
+	bits |= b2i(c.Black(8, 7))<<6 | b2i(c.Black(8, 8))<<7 | b2i(c.Black(7, 8))<<8
+	for i := 9; i < 15; i++ {
+		bits |= b2i(c.Black(14-i, 8)) << i
+	}
+	mask := -1
+	for m := 0; m < 8; m++ {
+		if formatBits(m) == bits {
+			mask = m
+		}
+	}
+	if mask < 0 {
+		t.Fatalf("bad format bits %015b", bits)
+	}
+
+	g := &grid{size: c.Size, modules: make([]bool, len(c.modules)), function: make([]bool, len(c.modules))}
+	g.drawFunctionPatterns(v)
+	copy(g.modules, c.modules)
+	g.applyMask(mask)
+
+	var codewords []byte
+	n := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			y := c.Size - 1 - vert
+			if (right+1)&2 != 0 {
+				y = vert
+			}
+			for x := right; x >= right-1; x-- {
+				if g.function[y*c.Size+x] {
+					continue
+				}
+				if n%8 == 0 {
+					codewords = append(codewords, 0)
+				}
+				codewords[n/8] |= byte(b2i(g.get(x, y)) << (7 - n%8))
+				n++
+			}
+		}
+	}
+
+	ver := versions[v]
+	blocks := make([][]byte, len(ver.blocks))
+	i := 0
+	for col := 0; col < ver.blocks[len(ver.blocks)-1]; col++ {
+		for b, size := range ver.blocks {
+			if col < size {
+				blocks[b] = append(blocks[b], codewords[i])
+				i++
+			}
+		}
+	}
+	ecs := make([][]byte, len(ver.blocks))
+	for col := 0; col < ver.ecPerBlock; col++ {
+		for b := range ecs {
+			ecs[b] = append(ecs[b], codewords[i])
+			i++
+		}
+	}
+	var data []byte
+	for b := range blocks {
+		assert.Equal(t, fmt.Sprint(reedSolomon(blocks[b], ver.ecPerBlock)), fmt.Sprint(ecs[b]))
+		data = append(data, blocks[b]...)
+	}
+
+	assert.Equal(t, data[0]>>4, byte(0b0100))
+	length, start := int(data[0]&0xf)<<4|int(data[1]>>4), 1
+	if v >= 10 {
+		length, start = int(data[0]&0xf)<<12|int(data[1])<<4|int(data[2]>>4), 2
+	}
+	text := make([]byte, length)
+	for j := range text {
+		text[j] = data[start+j]<<4 | data[start+j+1]>>4
+	}
+	return string(text)
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		text    string
+		version int
+	}{
+		{"hi", 1},
+		{"https://www.rehla.live/certificates/ABCD2345EFGH", 4},
+		{strings.Repeat("x", 150), 8},
+		{strings.Repeat("y", 200), 10},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.version), func(t *testing.T) {
+			c, err := Encode(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, c.Size, tt.version*4+17)
+			assert.Equal(t, read(t, c), tt.text)
+			// the dark module and the corner of a finder
+			assert.Equal(t, c.Black(8, c.Size-8), true)
+			assert.Equal(t, c.Black(0, 0), true)
+			assert.Equal(t, c.Black(7, 7), false)
+		})
+	}
+
+	_, err := Encode(strings.Repeat("z", 300))
+	assert.Equal(t, err, ErrTooLong)
+}
@@ -0,0 +1,184 @@
+// Package watch buffers lecture watch progress in redis. Players send
+// heartbeats every few seconds, they land in redis and the worker flushes
+// the lectures that changed to firestore in bulk.
+package watch
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alghurabi0/rehla/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// redis keys, every lecture a student plays has a hash with the position
+// and duration and a bitmap of the buckets they watched. dirtyKey is the
+// set of lectures changed since the last flush.
+const (
+	dirtyKey = "watch:dirty"
+	// buffered progress outlives a stopped worker for a while
+	bufferTTL = 7 * 24 * time.Hour
+	// lectures flushed per firestore bulk write
+	flushBatch = 200
+)
+
+func progressKey(userId, courseId, lecId string) string {
+	return fmt.Sprintf("watch:%s:%s:%s", userId, courseId, lecId)
+}
+
+func seenKey(userId, courseId, lecId string) string {
+	return progressKey(userId, courseId, lecId) + ":seen"
+}
+
+// firestore ids have no slashes
+func member(userId, courseId, lecId string) string {
+	return userId + "/" + courseId + "/" + lecId
+}
+
+type Buffer struct {
+	Redis *redis.Client
+}
+
+// Record adds a batch of heartbeats to the student's progress. load fetches
+// the flushed progress when the lecture isn't buffered, so the buckets
+// watched before carry over.
+func (b *Buffer) Record(ctx context.Context, userId, courseId, lecId string, beats []models.Heartbeat, load func() (*models.WatchProgress, error)) error {
+	if len(beats) == 0 {
+		return nil
+	}
+	key, seen := progressKey(userId, courseId, lecId), seenKey(userId, courseId, lecId)
+	n, err := b.Redis.Exists(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		p, err := load()
+		if err != nil {
+			return err
+		}
+		if p != nil {
+			// another request may have buffered the lecture meanwhile, its
+			// values are newer
+			pipe := b.Redis.TxPipeline()
+			pipe.HSetNX(ctx, key, "position", p.Position)
+			pipe.HSetNX(ctx, key, "duration", p.Duration)
+			pipe.HSetNX(ctx, key, "updated", p.UpdatedAt.Unix())
+			pipe.SetNX(ctx, seen, p.Watched, bufferTTL)
+			_, err = pipe.Exec(ctx)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	last := beats[len(beats)-1]
+	pipe := b.Redis.TxPipeline()
+	for _, bucket := range models.WatchedBuckets(beats) {
+		pipe.SetBit(ctx, seen, int64(bucket), 1)
+	}
+	pipe.HSet(ctx, key, "position", last.Position, "updated", time.Now().Unix())
+	if last.Duration > 0 {
+		pipe.HSet(ctx, key, "duration", last.Duration)
+	}
+	pipe.Expire(ctx, key, bufferTTL)
+	pipe.Expire(ctx, seen, bufferTTL)
+	pipe.SAdd(ctx, dirtyKey, member(userId, courseId, lecId))
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// Get returns the buffered progress in the lecture, nil when it isn't
+// buffered.
+func (b *Buffer) Get(ctx context.Context, userId, courseId, lecId string) (*models.WatchProgress, error) {
+	progress, err := b.getAll(ctx, userId, courseId, []string{lecId})
+	if err != nil || len(progress) == 0 {
+		return nil, err
+	}
+	return &progress[0], nil
+}
+
+// GetCourse returns the buffered progress in the given lectures of the
+// course, lectures that aren't buffered are left out.
+func (b *Buffer) GetCourse(ctx context.Context, userId, courseId string, lecIds []string) ([]models.WatchProgress, error) {
+	return b.getAll(ctx, userId, courseId, lecIds)
+}
+
+func (b *Buffer) getAll(ctx context.Context, userId, courseId string, lecIds []string) ([]models.WatchProgress, error) {
+	if len(lecIds) == 0 {
+		return nil, nil
+	}
+	pipe := b.Redis.Pipeline()
+	hashes := make([]*redis.MapStringStringCmd, len(lecIds))
+	bitmaps := make([]*redis.StringCmd, len(lecIds))
+	for i, lecId := range lecIds {
+		hashes[i] = pipe.HGetAll(ctx, progressKey(userId, courseId, lecId))
+		bitmaps[i] = pipe.Get(ctx, seenKey(userId, courseId, lecId))
+	}
+	_, err := pipe.Exec(ctx)
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	var progress []models.WatchProgress
+	for i, lecId := range lecIds {
+		fields := hashes[i].Val()
+		if len(fields) == 0 {
+			continue
+		}
+		p := models.WatchProgress{UserId: userId, CourseId: courseId, LecId: lecId}
+		p.Position, _ = strconv.ParseFloat(fields["position"], 64)
+		p.Duration, _ = strconv.ParseFloat(fields["duration"], 64)
+		updated, _ := strconv.ParseInt(fields["updated"], 10, 64)
+		p.UpdatedAt = time.Unix(updated, 0)
+		watched, err := bitmaps[i].Bytes()
+		if err != nil && err != redis.Nil {
+			return nil, err
+		}
+		p.Watched = watched
+		progress = append(progress, p)
+	}
+	return progress, nil
+}
+
+// Flush saves the lectures changed since the last flush and returns how many
+// it saved. Lectures that fail to save stay dirty for the next flush.
+func (b *Buffer) Flush(ctx context.Context, save func([]models.WatchProgress) error) (int, error) {
+	flushed := 0
+	for {
+		members, err := b.Redis.SPopN(ctx, dirtyKey, flushBatch).Result()
+		if err != nil {
+			return flushed, err
+		}
+		if len(members) == 0 {
+			return flushed, nil
+		}
+		var progress []models.WatchProgress
+		for _, m := range members {
+			ids := strings.SplitN(m, "/", 3)
+			if len(ids) != 3 {
+				continue
+			}
+			p, err := b.Get(ctx, ids[0], ids[1], ids[2])
+			if err != nil {
+				b.Redis.SAdd(ctx, dirtyKey, members)
+				return flushed, err
+			}
+			// expired before it was flushed
+			if p == nil {
+				continue
+			}
+			progress = append(progress, *p)
+		}
+		err = save(progress)
+		if err != nil {
+			b.Redis.SAdd(ctx, dirtyKey, members)
+			return flushed, err
+		}
+		flushed += len(progress)
+		if len(members) < flushBatch {
+			return flushed, nil
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/alghurabi0/rehla/internal/assert"
+)
+
+func TestColumnName(t *testing.T) {
+	assert.Equal(t, ColumnName(0), "A")
+	assert.Equal(t, ColumnName(25), "Z")
+	assert.Equal(t, ColumnName(26), "AA")
+	assert.Equal(t, ColumnName(701), "ZZ")
+	assert.Equal(t, ColumnName(702), "AAA")
+}
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, "Grades: 2024/25", [][]any{
+		{"Student", "Exam <1>"},
+		{"Amal", 7, 8.5, nil, "x"},
+	})
+	assert.Equal(t, err, nil)
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.Equal(t, err, nil)
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		assert.Equal(t, err, nil)
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[f.Name] = string(body)
+	}
+	assert.Equal(t, len(parts), 5)
+	assert.Equal(t, strings.Contains(parts["xl/workbook.xml"], `name="Grades 202425"`), true)
+
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	assert.Equal(t, strings.Contains(sheet, `Exam &lt;1&gt;`), true)
+	assert.Equal(t, strings.Contains(sheet, `<c r="B2"><v>7</v></c>`), true)
+	assert.Equal(t, strings.Contains(sheet, `<c r="C2"><v>8.5</v></c>`), true)
+	assert.Equal(t, strings.Contains(sheet, `r="D2"`), false)
+	assert.Equal(t, strings.Contains(sheet, `r="E2" t="inlineStr"`), true)
+}
@@ -0,0 +1,46 @@
+// Rows of a .sortable list are moved by dragging them. Once one is dropped
+// the list fires an "end" event, forms around it send the new order with
+// hx-trigger="end". The listeners are on the document so lists htmx swaps
+// in work too.
+let dragged = null;
+
+function sortableRow(target) {
+  if (!(target instanceof Element)) {
+    return null;
+  }
+  return target.closest(".sortable > [draggable]");
+}
+
+document.addEventListener("dragstart", (e) => {
+  const row = sortableRow(e.target);
+  if (!row) {
+    return;
+  }
+  dragged = row;
+  row.style.opacity = "0.5";
+  e.dataTransfer.effectAllowed = "move";
+});
+
+document.addEventListener("dragover", (e) => {
+  const row = sortableRow(e.target);
+  if (!dragged || !row || row.parentNode !== dragged.parentNode) {
+    return;
+  }
+  e.preventDefault();
+  if (row === dragged) {
+    return;
+  }
+  const box = row.getBoundingClientRect();
+  const after = e.clientY > box.top + box.height / 2;
+  row.parentNode.insertBefore(dragged, after ? row.nextSibling : row);
+});
+
+document.addEventListener("dragend", () => {
+  if (!dragged) {
+    return;
+  }
+  const list = dragged.parentNode;
+  dragged.style.opacity = "";
+  dragged = null;
+  list.dispatchEvent(new Event("end", { bubbles: true }));
+});
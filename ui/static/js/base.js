@@ -76,8 +76,10 @@ function requestPermission() {
 function sendTokenToServer(currentToken) {
   if (!isTokenSentToServer()) {
     console.log("Sending token to server...", currentToken);
-    fetch(`/${currentToken}`);
-    setTokenSentToServer(true);
+    fetch("/devices", {
+      method: "POST",
+      body: new URLSearchParams({ token: currentToken, device_id: deviceId() }),
+    }).then((response) => setTokenSentToServer(response.ok));
   } else {
     console.log(
       "Token already sent to server so won't send it again unless it changes",
@@ -85,6 +87,16 @@ function sendTokenToServer(currentToken) {
   }
 }
 
+// deviceId identifies this browser so a refreshed token replaces the old one
+function deviceId() {
+  let id = window.localStorage.getItem("deviceId");
+  if (!id) {
+    id = crypto.randomUUID();
+    window.localStorage.setItem("deviceId", id);
+  }
+  return id;
+}
+
 function isTokenSentToServer() {
   return window.localStorage.getItem("sentToServer") === "1";
 }
@@ -0,0 +1,71 @@
+import {
+  getAuth,
+  RecaptchaVerifier,
+  signInWithPhoneNumber,
+} from "https://www.gstatic.com/firebasejs/10.11.1/firebase-auth.js";
+import { app } from "/static/js/base.js";
+
+const auth = getAuth(app);
+window.recaptchaVerifier = new RecaptchaVerifier(
+  auth,
+  "recaptcha_container",
+  {},
+);
+recaptchaVerifier.render();
+
+const parent_form = document.getElementById("parent_form");
+const verify_button = document.getElementById("verify_button");
+const errors = document.querySelector(".errors");
+
+async function sendOTP(event) {
+  if (event) event.preventDefault();
+  const phone_number = document.getElementById("parent_phone_number").value;
+  if (!/^07[0-9]{9}$/.test(phone_number)) {
+    errors.textContent = "يرجى ادخال رقم هاتف صحيح";
+    return;
+  }
+  const phone = "+964" + phone_number.slice(1);
+  try {
+    window.confirmationResult = await signInWithPhoneNumber(
+      auth,
+      phone,
+      window.recaptchaVerifier,
+    );
+    parent_form.classList.remove("grid");
+    parent_form.classList.add("hidden");
+    document.getElementById("verify_form").classList.remove("hidden");
+    document.getElementById("verify_form").classList.add("grid");
+  } catch (error) {
+    console.log("firebase error", error);
+  }
+}
+
+async function verifyOTP() {
+  const otp = document.getElementById("otp").value;
+  if (!otp) {
+    alert("يرجى ادخال رمز التأكيد");
+    return;
+  }
+  try {
+    const result = await window.confirmationResult.confirm(otp);
+    const idToken = await result.user.getIdToken();
+    const response = await fetch("/parent/login", {
+      method: "POST",
+      body: new URLSearchParams({ id_token: idToken }),
+    });
+    if (response.status === 200) {
+      window.location.href = "/parent";
+    } else {
+      errors.textContent = await response.text();
+    }
+  } catch (error) {
+    console.log("Fetch error or unknown error", error);
+  }
+}
+
+if (parent_form) {
+  parent_form.addEventListener("submit", sendOTP);
+}
+if (verify_button) {
+  verify_button.addEventListener("click", verifyOTP);
+}